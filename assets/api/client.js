@@ -16,8 +16,15 @@ let isHandlingAuthError = false;
 
 // Request interceptor
 apiClient.interceptors.request.use(
-  (config) => {
-    // Could add loading indicators here
+  async (config) => {
+    // Scope activity and stats requests to the selected baby
+    if (/^\/(activities|stats)/.test(config.url)) {
+      const { useAuthStore } = await import("@/stores/auth");
+      const babyId = useAuthStore().currentBaby?.id;
+      if (babyId) {
+        config.params = { baby_id: babyId, ...config.params };
+      }
+    }
     return config;
   },
   (error) => {
@@ -16,6 +16,12 @@ const router = createRouter({
       component: () => import("../views/UserLogin.vue"),
       meta: { requiresAuth: false },
     },
+    {
+      path: "/invite/:token",
+      name: "invite",
+      component: () => import("../views/AcceptInvite.vue"),
+      meta: { requiresAuth: false, title: "Invitation" },
+    },
     {
       path: "/",
       name: "activity",
@@ -24,13 +24,14 @@ export const useAuthStore = defineStore("auth", () => {
     error.value = null;
 
     try {
-      await apiClient.post("/auth/login", credentials);
-      await checkAuth(); // Get full user data and babies
+      const response = await apiClient.post("/auth/login", credentials);
 
-      // Initialize timers after successful auth
-      await initializeUserSession();
+      // Accounts with two-factor authentication need a code as well
+      if (response.data?.two_factor_required) {
+        return { success: false, twoFactorRequired: true };
+      }
 
-      router.push("/");
+      await completeLogin();
       return { success: true };
     } catch (err) {
       error.value = err.message;
@@ -40,6 +41,32 @@ export const useAuthStore = defineStore("auth", () => {
     }
   }
 
+  // Second login step, with a TOTP or recovery code
+  async function verifyTwoFactor(code) {
+    loading.value = true;
+    error.value = null;
+
+    try {
+      await apiClient.post("/auth/login/2fa", { code });
+      await completeLogin();
+      return { success: true };
+    } catch (err) {
+      error.value = err.message;
+      return { success: false, error: err.message };
+    } finally {
+      loading.value = false;
+    }
+  }
+
+  async function completeLogin() {
+    await checkAuth(); // Get full user data and babies
+
+    // Initialize timers after successful auth
+    await initializeUserSession();
+
+    router.push("/");
+  }
+
   async function logout() {
     loading.value = true;
 
@@ -208,6 +235,7 @@ export const useAuthStore = defineStore("auth", () => {
     hasBaby,
     // Actions
     login,
+    verifyTwoFactor,
     logout,
     checkAuth,
     initializeAuth,
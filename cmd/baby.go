@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+var babyCmd = &cobra.Command{
+	Use:   "baby",
+	Short: "Baby profile management commands",
+	Long:  `Commands for adding, editing, listing and archiving baby profiles.`,
+}
+
+var babyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List baby profiles",
+	Long:  `Lists the baby profiles a user is a member of, along with their role.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+		all, _ := cmd.Flags().GetBool("all")
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		query := db.Preload("Baby").Where("user_id = ?", user.ID)
+
+		var members []models.BabyMember
+		if err := query.Find(&members).Error; err != nil {
+			log.Fatalf("Failed to fetch babies: %v", err)
+		}
+
+		sort.Slice(members, func(i, j int) bool {
+			return members[i].Baby.CreatedAt.Before(members[j].Baby.CreatedAt)
+		})
+
+		shown := 0
+		for _, member := range members {
+			baby := member.Baby
+			if baby.IsArchived() && !all {
+				continue
+			}
+			status := ""
+			if baby.IsArchived() {
+				status = " [archived]"
+			}
+			fmt.Printf("%s  %-20s born %s  %-9s%s\n",
+				baby.ID, baby.Name, baby.BirthDate.Format("2006-01-02"), member.Role, status)
+			shown++
+		}
+
+		if shown == 0 {
+			fmt.Printf("No babies found for '%s'\n", user.Username)
+		}
+	},
+}
+
+var babyAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a baby profile",
+	Long:  `Adds a new baby profile for an existing user.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+		name, _ := cmd.Flags().GetString("name")
+		birthDateStr, _ := cmd.Flags().GetString("birth-date")
+		trackSleep, _ := cmd.Flags().GetBool("track-sleep")
+
+		birthDate, err := time.Parse("2006-01-02", birthDateStr)
+		if err != nil {
+			log.Fatalf("Invalid birth date format. Use YYYY-MM-DD: %v", err)
+		}
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		baby := models.Baby{
+			UserID:    user.ID,
+			Name:      name,
+			BirthDate: birthDate,
+		}
+		if cmd.Flags().Changed("birth-weight") {
+			weight, _ := cmd.Flags().GetFloat64("birth-weight")
+			baby.BirthWeight = &weight
+		}
+		if cmd.Flags().Changed("birth-height") {
+			height, _ := cmd.Flags().GetFloat64("birth-height")
+			baby.BirthHeight = &height
+		}
+
+		if err := db.Create(&baby).Error; err != nil {
+			log.Fatalf("Failed to create baby profile: %v", err)
+		}
+
+		// GORM applies the column default to a false TrackSleep on insert
+		if !trackSleep {
+			if err := db.Model(&baby).Update("track_sleep", false).Error; err != nil {
+				log.Fatalf("Failed to update baby profile: %v", err)
+			}
+		}
+
+		fmt.Printf("✅ Baby profile '%s' created for '%s'\n", baby.Name, user.Username)
+		fmt.Printf("   ID: %s\n", baby.ID)
+		fmt.Printf("   Birth date: %s\n", baby.BirthDate.Format("2006-01-02"))
+	},
+}
+
+var babyEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit a baby profile",
+	Long:  `Updates the name, birth date, birth measurements or sleep tracking of a baby profile.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+
+		_, db := connectDatabase()
+		baby := mustFindBaby(db, id)
+
+		updates := map[string]interface{}{}
+		if cmd.Flags().Changed("name") {
+			name, _ := cmd.Flags().GetString("name")
+			if name == "" {
+				log.Fatal("Name cannot be empty")
+			}
+			updates["name"] = name
+		}
+		if cmd.Flags().Changed("birth-date") {
+			birthDateStr, _ := cmd.Flags().GetString("birth-date")
+			birthDate, err := time.Parse("2006-01-02", birthDateStr)
+			if err != nil {
+				log.Fatalf("Invalid birth date format. Use YYYY-MM-DD: %v", err)
+			}
+			updates["birth_date"] = birthDate
+		}
+		if cmd.Flags().Changed("birth-weight") {
+			weight, _ := cmd.Flags().GetFloat64("birth-weight")
+			updates["birth_weight"] = weight
+		}
+		if cmd.Flags().Changed("birth-height") {
+			height, _ := cmd.Flags().GetFloat64("birth-height")
+			updates["birth_height"] = height
+		}
+		if cmd.Flags().Changed("track-sleep") {
+			trackSleep, _ := cmd.Flags().GetBool("track-sleep")
+			updates["track_sleep"] = trackSleep
+		}
+
+		if len(updates) == 0 {
+			log.Fatal("Nothing to update. Pass at least one of --name, --birth-date, --birth-weight, --birth-height or --track-sleep")
+		}
+
+		if err := db.Model(baby).Updates(updates).Error; err != nil {
+			log.Fatalf("Failed to update baby profile: %v", err)
+		}
+
+		fmt.Printf("✅ Baby profile '%s' updated\n", baby.Name)
+	},
+}
+
+var babyArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Archive a baby profile",
+	Long: `Archives a baby profile. Archived babies keep their history but are hidden
+from the app. Use --restore to bring an archived baby back.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		restore, _ := cmd.Flags().GetBool("restore")
+
+		_, db := connectDatabase()
+		baby := mustFindBaby(db, id)
+
+		var archivedAt *time.Time
+		if !restore {
+			now := time.Now()
+			archivedAt = &now
+		}
+
+		if err := db.Model(baby).Update("archived_at", archivedAt).Error; err != nil {
+			log.Fatalf("Failed to update baby profile: %v", err)
+		}
+
+		if restore {
+			fmt.Printf("✅ Baby profile '%s' restored\n", baby.Name)
+		} else {
+			fmt.Printf("✅ Baby profile '%s' archived\n", baby.Name)
+		}
+	},
+}
+
+var babyShareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Share a baby profile with another user",
+	Long: `Grants a user access to a baby profile with the given role, or changes the
+role of an existing member. Roles are owner, caregiver and viewer.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		username, _ := cmd.Flags().GetString("username")
+		roleStr, _ := cmd.Flags().GetString("role")
+
+		role := models.BabyRole(roleStr)
+		if !role.Valid() {
+			log.Fatalf("Invalid role '%s'. Use owner, caregiver or viewer", roleStr)
+		}
+
+		_, db := connectDatabase()
+		baby := mustFindBaby(db, id)
+		user := mustFindUser(db, username)
+
+		var member models.BabyMember
+		err := db.Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).First(&member).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			member = models.BabyMember{BabyID: baby.ID, UserID: user.ID, Role: role}
+			if err := db.Create(&member).Error; err != nil {
+				log.Fatalf("Failed to share baby profile: %v", err)
+			}
+		case err != nil:
+			log.Fatalf("Failed to fetch membership: %v", err)
+		default:
+			if member.Role == models.BabyRoleOwner && role != models.BabyRoleOwner {
+				mustHaveAnotherOwner(db, baby)
+			}
+			// The role set here takes over from the OpenID Connect role mapping
+			if err := db.Model(&member).Updates(map[string]interface{}{
+				"role":            role,
+				"managed_by_oidc": false,
+			}).Error; err != nil {
+				log.Fatalf("Failed to update membership: %v", err)
+			}
+		}
+
+		fmt.Printf("✅ '%s' is now a %s of '%s'\n", user.Username, role, baby.Name)
+	},
+}
+
+var babyUnshareCmd = &cobra.Command{
+	Use:   "unshare",
+	Short: "Revoke a user's access to a baby profile",
+	Long:  `Removes a user's membership of a baby profile. The last owner cannot be removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		username, _ := cmd.Flags().GetString("username")
+
+		_, db := connectDatabase()
+		baby := mustFindBaby(db, id)
+		user := mustFindUser(db, username)
+
+		var member models.BabyMember
+		if err := db.Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).First(&member).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				log.Fatalf("'%s' is not a member of '%s'", user.Username, baby.Name)
+			}
+			log.Fatalf("Failed to fetch membership: %v", err)
+		}
+
+		if member.Role == models.BabyRoleOwner {
+			mustHaveAnotherOwner(db, baby)
+		}
+
+		if err := db.Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).
+			Delete(&models.BabyMember{}).Error; err != nil {
+			log.Fatalf("Failed to remove membership: %v", err)
+		}
+
+		fmt.Printf("✅ '%s' no longer has access to '%s'\n", user.Username, baby.Name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(babyCmd)
+	babyCmd.AddCommand(babyListCmd)
+	babyCmd.AddCommand(babyAddCmd)
+	babyCmd.AddCommand(babyEditCmd)
+	babyCmd.AddCommand(babyArchiveCmd)
+	babyCmd.AddCommand(babyShareCmd)
+	babyCmd.AddCommand(babyUnshareCmd)
+
+	babyListCmd.Flags().StringP("username", "u", "", "Username of the parent (required)")
+	babyListCmd.Flags().BoolP("all", "a", false, "Include archived babies")
+	babyListCmd.MarkFlagRequired("username")
+
+	babyAddCmd.Flags().StringP("username", "u", "", "Username of the parent (required)")
+	babyAddCmd.Flags().StringP("name", "n", "", "Name of the baby (required)")
+	babyAddCmd.Flags().StringP("birth-date", "d", "", "Birth date (YYYY-MM-DD) (required)")
+	babyAddCmd.Flags().Float64("birth-weight", 0, "Birth weight in kg")
+	babyAddCmd.Flags().Float64("birth-height", 0, "Birth height in cm")
+	babyAddCmd.Flags().Bool("track-sleep", true, "Track sleep for this baby")
+	babyAddCmd.MarkFlagRequired("username")
+	babyAddCmd.MarkFlagRequired("name")
+	babyAddCmd.MarkFlagRequired("birth-date")
+
+	babyEditCmd.Flags().String("id", "", "ID of the baby (required)")
+	babyEditCmd.Flags().StringP("name", "n", "", "New name of the baby")
+	babyEditCmd.Flags().StringP("birth-date", "d", "", "New birth date (YYYY-MM-DD)")
+	babyEditCmd.Flags().Float64("birth-weight", 0, "Birth weight in kg")
+	babyEditCmd.Flags().Float64("birth-height", 0, "Birth height in cm")
+	babyEditCmd.Flags().Bool("track-sleep", true, "Track sleep for this baby")
+	babyEditCmd.MarkFlagRequired("id")
+
+	babyArchiveCmd.Flags().String("id", "", "ID of the baby (required)")
+	babyArchiveCmd.Flags().Bool("restore", false, "Restore an archived baby instead")
+	babyArchiveCmd.MarkFlagRequired("id")
+
+	babyShareCmd.Flags().String("id", "", "ID of the baby (required)")
+	babyShareCmd.Flags().StringP("username", "u", "", "Username to share with (required)")
+	babyShareCmd.Flags().StringP("role", "r", string(models.BabyRoleCaregiver), "Role: owner, caregiver or viewer")
+	babyShareCmd.MarkFlagRequired("id")
+	babyShareCmd.MarkFlagRequired("username")
+
+	babyUnshareCmd.Flags().String("id", "", "ID of the baby (required)")
+	babyUnshareCmd.Flags().StringP("username", "u", "", "Username to remove (required)")
+	babyUnshareCmd.MarkFlagRequired("id")
+	babyUnshareCmd.MarkFlagRequired("username")
+}
+
+// mustFindUser looks up a user by username, exiting if it does not exist
+func mustFindUser(db *gorm.DB, username string) *models.User {
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Fatalf("User '%s' not found", username)
+		}
+		log.Fatalf("Failed to fetch user: %v", err)
+	}
+	return &user
+}
+
+// mustFindBaby looks up a baby by ID, exiting if it does not exist
+func mustFindBaby(db *gorm.DB, id string) *models.Baby {
+	var baby models.Baby
+	if err := db.Where("id = ?", id).First(&baby).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Fatalf("Baby '%s' not found", id)
+		}
+		log.Fatalf("Failed to fetch baby: %v", err)
+	}
+	return &baby
+}
+
+// mustHaveAnotherOwner exits unless the baby has more than one owner
+func mustHaveAnotherOwner(db *gorm.DB, baby *models.Baby) {
+	var owners int64
+	if err := db.Model(&models.BabyMember{}).
+		Where("baby_id = ? AND role = ?", baby.ID, models.BabyRoleOwner).
+		Count(&owners).Error; err != nil {
+		log.Fatalf("Failed to count owners: %v", err)
+	}
+	if owners <= 1 {
+		log.Fatalf("'%s' must keep at least one owner", baby.Name)
+	}
+}
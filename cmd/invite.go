@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+var inviteCmd = &cobra.Command{
+	Use:   "invite",
+	Short: "Caregiver invitation commands",
+	Long:  `Commands for creating and listing single-use caregiver invitation links.`,
+}
+
+var inviteCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an invitation link",
+	Long: `Creates a single-use, expiring invitation for a baby. The invitee chooses
+their own username and password when redeeming it and is granted the given role
+on that baby only.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		username, _ := cmd.Flags().GetString("username")
+		roleStr, _ := cmd.Flags().GetString("role")
+		expires, _ := cmd.Flags().GetDuration("expires")
+
+		role := models.BabyRole(roleStr)
+		if !role.Valid() {
+			log.Fatalf("Invalid role '%s'. Use owner, caregiver or viewer", roleStr)
+		}
+		if expires <= 0 {
+			log.Fatal("Expiry must be a positive duration, e.g. 48h")
+		}
+
+		_, db := connectDatabase()
+		baby := mustFindBaby(db, id)
+		user := mustFindUser(db, username)
+
+		token, err := utils.GenerateToken(utils.DefaultTokenLength)
+		if err != nil {
+			log.Fatalf("Failed to generate invite token: %v", err)
+		}
+
+		invite := models.Invite{
+			BabyID:      baby.ID,
+			CreatedByID: user.ID,
+			TokenHash:   utils.HashToken(token),
+			Role:        role,
+			ExpiresAt:   time.Now().Add(expires),
+		}
+		if err := db.Create(&invite).Error; err != nil {
+			log.Fatalf("Failed to create invite: %v", err)
+		}
+
+		fmt.Printf("✅ Invite created for '%s' as %s\n", baby.Name, role)
+		fmt.Printf("   Token: %s\n", token)
+		fmt.Printf("   Link: /invite/%s\n", token)
+		fmt.Printf("   Expires: %s\n", invite.ExpiresAt.Format(time.RFC3339))
+	},
+}
+
+var inviteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List invitations for a baby",
+	Long:  `Lists the invitations created for a baby and whether they have been used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+
+		_, db := connectDatabase()
+		baby := mustFindBaby(db, id)
+
+		var invites []models.Invite
+		if err := db.Preload("UsedBy").
+			Where("baby_id = ?", baby.ID).
+			Order("created_at DESC").
+			Find(&invites).Error; err != nil {
+			log.Fatalf("Failed to fetch invites: %v", err)
+		}
+
+		if len(invites) == 0 {
+			fmt.Printf("No invites found for '%s'\n", baby.Name)
+			return
+		}
+
+		now := time.Now()
+		for _, invite := range invites {
+			var status string
+			switch {
+			case invite.UsedAt != nil && invite.UsedBy != nil:
+				status = "used by " + invite.UsedBy.Username
+			case invite.UsedAt != nil:
+				status = "used"
+			case !invite.IsRedeemable(now):
+				status = "expired"
+			default:
+				status = "pending"
+			}
+			fmt.Printf("%s  %-9s expires %s  %s\n",
+				invite.ID, invite.Role, invite.ExpiresAt.Format("2006-01-02 15:04"), status)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inviteCmd)
+	inviteCmd.AddCommand(inviteCreateCmd)
+	inviteCmd.AddCommand(inviteListCmd)
+
+	inviteCreateCmd.Flags().String("id", "", "ID of the baby (required)")
+	inviteCreateCmd.Flags().StringP("username", "u", "", "Username of the owner creating the invite (required)")
+	inviteCreateCmd.Flags().StringP("role", "r", string(models.BabyRoleCaregiver), "Role: owner, caregiver or viewer")
+	inviteCreateCmd.Flags().Duration("expires", 48*time.Hour, "How long the invite stays valid")
+	inviteCreateCmd.MarkFlagRequired("id")
+	inviteCreateCmd.MarkFlagRequired("username")
+
+	inviteListCmd.Flags().String("id", "", "ID of the baby (required)")
+	inviteListCmd.MarkFlagRequired("id")
+}
@@ -2,9 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/database"
+	"github.com/engineervix/bambino/internal/utils"
 )
 
 var rootCmd = &cobra.Command{
@@ -24,3 +31,40 @@ func Execute() {
 func init() {
 	// Add global flags here if needed
 }
+
+// connectDatabase loads the configuration and opens the database connection
+// used by the management commands, exiting on failure
+func connectDatabase() (*config.Config, *gorm.DB) {
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		// Only log in development - production uses Docker env vars
+		if os.Getenv("ENV") != "production" {
+			log.Println("No .env file found")
+		}
+	}
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	// Hash passwords with the configured Argon2 costs
+	configurePasswordHashing(cfg)
+
+	// Connect to database
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	return cfg, db
+}
+
+// configurePasswordHashing applies the configured Argon2 costs to new password
+// hashes
+func configurePasswordHashing(cfg *config.Config) {
+	utils.DefaultArgon2Params = utils.NewArgon2Params(cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+}
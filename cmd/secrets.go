@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/notify"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Secret and key commands",
+	Long:  `Commands for generating the keys that sign and encrypt session cookies and Web Push notifications.`,
+}
+
+var secretsGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a session key pair",
+	Long: `Generates a new signing and encryption key pair for SESSION_SECRETS.
+
+To rotate secrets, put the new pair first and keep the old ones after it until
+existing sessions have expired:
+
+  SESSION_SECRETS=<new pair>,<old pair>
+
+With --rotate, the new pair is prepended to the current SESSION_SECRETS.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rotate, _ := cmd.Flags().GetBool("rotate")
+
+		pair, err := config.GenerateSessionKeyPair()
+		if err != nil {
+			log.Fatalf("Failed to generate key pair: %v", err)
+		}
+
+		if !rotate {
+			fmt.Println(pair)
+			return
+		}
+
+		// Pick up SESSION_SECRETS from .env too, if there is one
+		_ = godotenv.Load()
+		current := strings.TrimSpace(os.Getenv("SESSION_SECRETS"))
+		if current == "" {
+			fmt.Printf("SESSION_SECRETS=%s\n", pair)
+			return
+		}
+		fmt.Printf("SESSION_SECRETS=%s,%s\n", pair, current)
+	},
+}
+
+var secretsVAPIDCmd = &cobra.Command{
+	Use:   "vapid",
+	Short: "Generate a VAPID key pair for Web Push",
+	Long: `Generates the key pair that signs Web Push notifications, for
+VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY.
+
+Browsers subscribe with the public key, so changing the keys stops Web Push
+notifications until each browser subscribes again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		publicKey, privateKey, err := notify.GenerateVAPIDKeys()
+		if err != nil {
+			log.Fatalf("Failed to generate key pair: %v", err)
+		}
+
+		fmt.Printf("VAPID_PUBLIC_KEY=%s\n", publicKey)
+		fmt.Printf("VAPID_PRIVATE_KEY=%s\n", privateKey)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsGenerateCmd)
+	secretsCmd.AddCommand(secretsVAPIDCmd)
+
+	secretsGenerateCmd.Flags().Bool("rotate", false, "Print SESSION_SECRETS with the new pair prepended to the current value")
+}
@@ -48,6 +48,9 @@ func seedDatabase() {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	// Hash passwords with the configured Argon2 costs
+	configurePasswordHashing(cfg)
+
 	// Connect to database
 	db, err := database.Connect(cfg)
 	if err != nil {
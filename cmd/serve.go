@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/engineervix/bambino/internal/assets"
@@ -20,9 +25,14 @@ import (
 
 	"github.com/engineervix/bambino/internal/config"
 	"github.com/engineervix/bambino/internal/database"
+	"github.com/engineervix/bambino/internal/events"
 	"github.com/engineervix/bambino/internal/handlers"
 	authMiddleware "github.com/engineervix/bambino/internal/middleware"
+	"github.com/engineervix/bambino/internal/mqtt"
+	"github.com/engineervix/bambino/internal/notify"
+	"github.com/engineervix/bambino/internal/reminders"
 	"github.com/engineervix/bambino/internal/utils"
+	"github.com/engineervix/bambino/internal/webhooks"
 )
 
 var serveCmd = &cobra.Command{
@@ -36,6 +46,9 @@ var serveCmd = &cobra.Command{
 	},
 }
 
+// loginHistoryRetention is how long login attempts are kept
+const loginHistoryRetention = 90 * 24 * time.Hour
+
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
@@ -60,6 +73,9 @@ func runServer(overridePort string) {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	// Hash passwords with the configured Argon2 costs
+	configurePasswordHashing(cfg)
+
 	// Initialize Sentry
 	if cfg.SentryDSN != "" {
 		err := sentry.Init(sentry.ClientOptions{
@@ -103,28 +119,14 @@ func runServer(overridePort string) {
 	// Create Echo instance
 	e := echo.New()
 
-	// Configure IP extraction for Cloudflare + Traefik setup
-	// Cloudflare sends real IP in CF-Connecting-IP, fallback to X-Forwarded-For
-	e.IPExtractor = func(req *http.Request) string {
-		// First check CF-Connecting-IP (Cloudflare's real IP header)
-		if cfIP := req.Header.Get("CF-Connecting-IP"); cfIP != "" {
-			return cfIP
-		}
-
-		// Also check X-Real-IP as another common header
-		if realIP := req.Header.Get("X-Real-IP"); realIP != "" {
-			return realIP
-		}
-
-		// Fallback to X-Forwarded-For, trusting all private networks and Cloudflare
-		// Since Traefik is configured to trust Cloudflare IPs, we can trust private networks
-		extractor := echo.ExtractIPFromXFFHeader(
-			echo.TrustLoopback(true),   // Trust loopback addresses
-			echo.TrustLinkLocal(true),  // Trust link-local addresses
-			echo.TrustPrivateNet(true), // Trust private network addresses (includes Docker networks)
-		)
-		return extractor(req)
+	// Configure IP extraction for Cloudflare + Traefik setup. The client IP
+	// headers are only read from requests sent by a proxy, which includes
+	// those trusted for forward authentication.
+	trustedProxies, err := cfg.AuthProxyNetworks()
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
 	}
+	e.IPExtractor = utils.ClientIPExtractor(trustedProxies)
 
 	// Configure structured logging with proper log levels using RequestLogger
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
@@ -199,26 +201,105 @@ func runServer(overridePort string) {
 	// Configure CORS
 	corsConfig := middleware.CORSConfig{
 		AllowOrigins:     strings.Split(cfg.AllowedOrigins, ","),
-		AllowMethods:     []string{echo.GET, echo.PUT, echo.POST, echo.DELETE},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
+		AllowMethods:     []string{echo.GET, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "If-Match", authMiddleware.IdempotencyKeyHeader},
+		ExposeHeaders:    []string{"ETag", authMiddleware.IdempotentReplayedHeader},
 		AllowCredentials: true, // Important for session cookies
 	}
 	e.Use(middleware.CORSWithConfig(corsConfig))
 
-	// Session middleware
+	// Session middleware. Sessions are kept in the database so they can be
+	// listed and revoked; the cookie only carries an opaque session key.
+	sessionKeys, err := cfg.SessionKeyPairs()
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
 	sessionStore := utils.CreateSessionStore(utils.SessionConfig{
-		Secret:   cfg.SessionSecret,
-		MaxAge:   cfg.SessionMaxAge,
-		HttpOnly: true,
-		Secure:   cfg.Env == "production", // Only secure in production
+		KeyPairs:    sessionKeys,
+		MaxAge:      cfg.SessionMaxAge,
+		HttpOnly:    true,
+		Secure:      cfg.Env == "production", // Only secure in production
+		DB:          db,
+		IPExtractor: e.IPExtractor,
 	})
 	e.Use(session.Middleware(sessionStore))
 
-	// Store config and db in context for handlers
+	// Periodically clear out expired sessions, old login history, idempotency
+	// keys, the webhook delivery log and the activity trash
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if store, ok := sessionStore.(*utils.DBStore); ok {
+				if err := store.PurgeExpired(); err != nil {
+					log.Printf("Failed to purge expired sessions: %v", err)
+				}
+			}
+			if err := utils.PurgeLoginAttempts(db, time.Now().Add(-loginHistoryRetention)); err != nil {
+				log.Printf("Failed to purge login history: %v", err)
+			}
+			if err := utils.PurgeIdempotencyKeys(db, time.Now().Add(-utils.IdempotencyKeyRetention)); err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			}
+			if err := webhooks.PurgeDeliveries(db, time.Now().Add(-webhooks.DeliveryRetention)); err != nil {
+				log.Printf("Failed to purge webhook deliveries: %v", err)
+			}
+			if days := cfg.ActivityTrashRetentionDays; days > 0 {
+				if err := utils.PurgeTrashedActivities(db, time.Now().AddDate(0, 0, -days)); err != nil {
+					log.Printf("Failed to purge activity trash: %v", err)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Background work stops when the server is asked to shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var workers sync.WaitGroup
+
+	// Live events, shared with other instances if configured
+	broker := events.NewBroker()
+	if cfg.EventsPostgresNotify {
+		broker.UsePostgres(ctx, db, cfg.PostgresDSN())
+	}
+
+	// Send queued webhook deliveries in the background
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		webhooks.NewDispatcher(db, cfg).Run(ctx)
+	}()
+
+	// Publish baby state over MQTT if configured
+	if cfg.MQTTEnabled() {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := mqtt.New(db, cfg, broker).Run(ctx); err != nil {
+				log.Printf("MQTT publishing stopped: %v", err)
+			}
+		}()
+	}
+
+	// Notifications go out through the channels the config allows
+	notifier := notify.New(db, cfg)
+
+	// Send reminders in the background as they fall due
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		reminders.NewScheduler(db, notifier).Run(ctx)
+	}()
+
+	// Store config, db, the event broker and the notifier in context for
+	// handlers
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			c.Set("db", db)
 			c.Set("config", cfg)
+			c.Set("events", broker)
+			c.Set("notifier", notifier)
 			return next(c)
 		}
 	})
@@ -234,28 +315,115 @@ func runServer(overridePort string) {
 	// Auth routes (public)
 	auth := e.Group("/api/auth")
 	auth.POST("/login", handlers.Login)
+	auth.POST("/login/2fa", handlers.VerifyTwoFactorLogin)
 	auth.POST("/logout", handlers.Logout)
 	auth.GET("/check", handlers.CheckAuth)
+	auth.GET("/oidc", handlers.GetOIDCStatus)
+	auth.GET("/oidc/login", handlers.OIDCLogin)
+	auth.GET("/oidc/callback", handlers.OIDCCallback)
+
+	// Invite redemption routes (public)
+	invites := e.Group("/api/invites")
+	invites.GET("/:token", handlers.GetInvite)
+	invites.POST("/:token/accept", handlers.AcceptInvite)
+
+	// Read-only share link routes (public, authorized by a signed token)
+	share := e.Group("/api/share/:token")
+	share.Use(authMiddleware.RequireShareToken())
+	share.GET("", handlers.GetSharedBaby)
+	share.GET("/activities", handlers.GetSharedActivities)
+	share.GET("/growth", handlers.GetSharedGrowth)
+	share.GET("/stats", handlers.GetSharedStats)
 
 	// Auth routes (protected)
 	authProtected := e.Group("/api/auth")
 	authProtected.Use(authMiddleware.RequireAuthJSON())
 	authProtected.GET("/me", handlers.GetCurrentUser)
+	authProtected.PUT("/password", handlers.ChangePassword)
+	authProtected.GET("/logins", handlers.GetLoginHistory)
+	authProtected.GET("/2fa", handlers.GetTwoFactorStatus)
+	authProtected.POST("/2fa/setup", handlers.SetupTwoFactor)
+	authProtected.POST("/2fa/enable", handlers.EnableTwoFactor)
+	authProtected.POST("/2fa/disable", handlers.DisableTwoFactor)
+	authProtected.POST("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+	authProtected.GET("/tokens", handlers.GetAPITokens)
+	authProtected.POST("/tokens", handlers.CreateAPIToken)
+	authProtected.DELETE("/tokens/:id", handlers.RevokeAPIToken)
+	authProtected.GET("/sessions", handlers.GetSessions)
+	authProtected.DELETE("/sessions", handlers.DeleteAllSessions)
+	authProtected.DELETE("/sessions/:id", handlers.DeleteSession)
+	authProtected.GET("/oidc/identities", handlers.GetOIDCIdentities)
+	authProtected.DELETE("/oidc/identities/:id", handlers.DeleteOIDCIdentity)
 
 	// Protected API routes
 	api := e.Group("/api")
 	api.Use(authMiddleware.RequireAuthJSON())
+	api.Use(authMiddleware.Idempotency())
 
 	// Baby routes
 	api.GET("/babies", handlers.GetBabies)
+	api.POST("/babies", handlers.CreateBaby)
 	api.PUT("/babies/:baby_id", handlers.UpdateBaby)
+	api.DELETE("/babies/:baby_id", handlers.ArchiveBaby)
+	api.POST("/babies/:baby_id/unarchive", handlers.UnarchiveBaby)
+
+	// Baby member routes
+	api.GET("/babies/:baby_id/members", handlers.GetBabyMembers)
+	api.POST("/babies/:baby_id/members", handlers.AddBabyMember)
+	api.PUT("/babies/:baby_id/members/:user_id", handlers.UpdateBabyMember)
+	api.DELETE("/babies/:baby_id/members/:user_id", handlers.RemoveBabyMember)
+
+	// Baby invite routes
+	api.GET("/babies/:baby_id/invites", handlers.GetInvites)
+	api.POST("/babies/:baby_id/invites", handlers.CreateInvite)
+	api.DELETE("/babies/:baby_id/invites/:id", handlers.RevokeInvite)
+
+	// Baby share link routes
+	api.GET("/babies/:baby_id/shares", handlers.GetShareLinks)
+	api.POST("/babies/:baby_id/shares", handlers.CreateShareLink)
+	api.DELETE("/babies/:baby_id/shares/:id", handlers.RevokeShareLink)
+	api.GET("/babies/:baby_id/shares/:id/accesses", handlers.GetShareLinkAccesses)
 
 	// Activity routes
 	api.GET("/activities", handlers.GetActivities)
 	api.POST("/activities", handlers.CreateActivity)
+	api.GET("/activities/trash", handlers.GetActivityTrash)
 	api.GET("/activities/:id", handlers.GetActivity)
 	api.PUT("/activities/:id", handlers.UpdateActivity)
+	api.PATCH("/activities/:id", handlers.PatchActivity)
 	api.DELETE("/activities/:id", handlers.DeleteActivity)
+	api.POST("/activities/:id/restore", handlers.RestoreActivity)
+	api.GET("/activities/:id/history", handlers.GetActivityHistory)
+	api.GET("/audit", handlers.GetAuditLog)
+
+	// Offline sync
+	api.POST("/sync", handlers.Sync)
+
+	// Live updates
+	api.GET("/events", handlers.GetEvents)
+
+	// Webhook routes
+	api.GET("/webhooks", handlers.GetWebhooks)
+	api.POST("/webhooks", handlers.CreateWebhook)
+	api.PUT("/webhooks/:id", handlers.UpdateWebhook)
+	api.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+	api.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+
+	// Notification routes
+	api.GET("/notifications", handlers.GetNotificationSetup)
+	api.GET("/notifications/channels", handlers.GetNotificationChannels)
+	api.POST("/notifications/channels", handlers.CreateNotificationChannel)
+	api.PUT("/notifications/channels/:id", handlers.UpdateNotificationChannel)
+	api.DELETE("/notifications/channels/:id", handlers.DeleteNotificationChannel)
+	api.POST("/notifications/channels/:id/test", handlers.TestNotificationChannel)
+
+	// Reminder routes
+	api.GET("/reminders", handlers.GetReminders)
+	api.POST("/reminders", handlers.CreateReminder)
+	api.PUT("/reminders/:id", handlers.UpdateReminder)
+	api.DELETE("/reminders/:id", handlers.DeleteReminder)
+	api.POST("/reminders/:id/ack", handlers.AcknowledgeReminder)
+	api.POST("/reminders/:id/snooze", handlers.SnoozeReminder)
 
 	// Timer endpoints
 	api.POST("/activities/timer/start", handlers.StartActivityTimer)
@@ -266,6 +434,27 @@ func runServer(overridePort string) {
 	api.GET("/stats/recent", handlers.GetRecentStats)
 	api.GET("/stats/weekly", handlers.GetWeeklyStats)
 
+	// Baby-scoped routes. These mirror the routes above for an explicit baby;
+	// the unscoped routes also accept a baby_id query param and otherwise
+	// fall back to the most recently created baby.
+	babyScoped := api.Group("/babies/:baby_id")
+	babyScoped.GET("/activities", handlers.GetActivities)
+	babyScoped.POST("/activities", handlers.CreateActivity)
+	babyScoped.GET("/activities/trash", handlers.GetActivityTrash)
+	babyScoped.GET("/activities/:id", handlers.GetActivity)
+	babyScoped.PUT("/activities/:id", handlers.UpdateActivity)
+	babyScoped.PATCH("/activities/:id", handlers.PatchActivity)
+	babyScoped.DELETE("/activities/:id", handlers.DeleteActivity)
+	babyScoped.POST("/activities/:id/restore", handlers.RestoreActivity)
+	babyScoped.GET("/activities/:id/history", handlers.GetActivityHistory)
+	babyScoped.POST("/sync", handlers.Sync)
+	babyScoped.GET("/events", handlers.GetEvents)
+	babyScoped.POST("/activities/timer/start", handlers.StartActivityTimer)
+	babyScoped.PUT("/activities/timer/:id/stop", handlers.StopActivityTimer)
+	babyScoped.GET("/stats/daily", handlers.GetDailyStats)
+	babyScoped.GET("/stats/recent", handlers.GetRecentStats)
+	babyScoped.GET("/stats/weekly", handlers.GetWeeklyStats)
+
 	// Serve static files in production
 	if cfg.Env == "production" {
 		web, err := fs.Sub(assets.Assets, "dist")
@@ -286,8 +475,25 @@ func runServer(overridePort string) {
 		port = overridePort
 	}
 
+	// Requests share the shutdown context, so that live event streams end
+	// rather than holding the server open
+	e.Server.BaseContext = func(net.Listener) context.Context { return ctx }
+
 	log.Printf("Starting server on port %s in %s mode", port, cfg.Env)
-	if err := e.Start(":" + port); err != nil {
-		log.Fatal(err)
+	go func() {
+		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM, let requests in flight and the background work
+	// finish before exiting
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the server: %v", err)
 	}
+	workers.Wait()
 }
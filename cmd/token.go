@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Personal API token commands",
+	Long: `Commands for creating, listing and revoking personal API tokens. Tokens are
+sent as "Authorization: Bearer <token>" by scripts and home automation.`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API token",
+	Long: `Creates a personal API token for a user. The token is only shown once.
+Use --scope read for tokens that only need to read data, and --baby to limit
+a token to a single baby.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+		name, _ := cmd.Flags().GetString("name")
+		scopeStr, _ := cmd.Flags().GetString("scope")
+		babyID, _ := cmd.Flags().GetString("baby")
+		expiresDays, _ := cmd.Flags().GetInt("expires-days")
+
+		scope := models.APITokenScope(scopeStr)
+		if !scope.Valid() {
+			log.Fatalf("Invalid scope '%s'. Use read or write", scopeStr)
+		}
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		token := models.APIToken{
+			UserID: user.ID,
+			Name:   name,
+			Scope:  scope,
+		}
+
+		if babyID != "" {
+			baby := mustFindBaby(db, babyID)
+			var count int64
+			if err := db.Model(&models.BabyMember{}).
+				Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).
+				Count(&count).Error; err != nil {
+				log.Fatalf("Failed to fetch membership: %v", err)
+			}
+			if count == 0 {
+				log.Fatalf("'%s' is not a member of '%s'", user.Username, baby.Name)
+			}
+			token.BabyID = &baby.ID
+		}
+
+		if expiresDays > 0 {
+			expiresAt := time.Now().AddDate(0, 0, expiresDays)
+			token.ExpiresAt = &expiresAt
+		}
+
+		raw, err := utils.GenerateAPIToken()
+		if err != nil {
+			log.Fatalf("Failed to generate API token: %v", err)
+		}
+		token.TokenHash = utils.HashToken(raw)
+		token.Prefix = raw[:12]
+
+		if err := db.Create(&token).Error; err != nil {
+			log.Fatalf("Failed to create API token: %v", err)
+		}
+
+		fmt.Printf("✅ API token '%s' created for '%s' (%s)\n", token.Name, user.Username, token.Scope)
+		fmt.Printf("   ID: %s\n", token.ID)
+		fmt.Printf("   Token: %s\n", raw)
+		fmt.Println("   Store it somewhere safe, it will not be shown again.")
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List a user's API tokens",
+	Long:  `Lists the personal API tokens belonging to a user.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		var tokens []models.APIToken
+		if err := db.Where("user_id = ?", user.ID).
+			Order("created_at DESC").
+			Find(&tokens).Error; err != nil {
+			log.Fatalf("Failed to fetch API tokens: %v", err)
+		}
+
+		if len(tokens) == 0 {
+			fmt.Printf("No API tokens found for '%s'\n", user.Username)
+			return
+		}
+
+		now := time.Now()
+		for _, token := range tokens {
+			lastUsed := "never used"
+			if token.LastUsedAt != nil {
+				lastUsed = "last used " + token.LastUsedAt.Format("2006-01-02 15:04")
+			}
+			status := ""
+			if token.IsExpired(now) {
+				status = " [expired]"
+			}
+			fmt.Printf("%s  %-20s %s…  %-5s  %s%s\n",
+				token.ID, token.Name, token.Prefix, token.Scope, lastUsed, status)
+		}
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke an API token",
+	Long:  `Deletes a personal API token so it can no longer be used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+
+		_, db := connectDatabase()
+
+		result := db.Where("id = ?", id).Delete(&models.APIToken{})
+		if result.Error != nil {
+			log.Fatalf("Failed to revoke API token: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			log.Fatalf("API token '%s' not found", id)
+		}
+
+		fmt.Println("✅ API token revoked")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	tokenCreateCmd.Flags().StringP("username", "u", "", "Username the token belongs to (required)")
+	tokenCreateCmd.Flags().StringP("name", "n", "", "Name describing what the token is for (required)")
+	tokenCreateCmd.Flags().StringP("scope", "s", string(models.APITokenScopeWrite), "Scope: read or write")
+	tokenCreateCmd.Flags().String("baby", "", "Limit the token to this baby ID")
+	tokenCreateCmd.Flags().Int("expires-days", 0, "Expire the token after this many days (default never)")
+	tokenCreateCmd.MarkFlagRequired("username")
+	tokenCreateCmd.MarkFlagRequired("name")
+
+	tokenListCmd.Flags().StringP("username", "u", "", "Username whose tokens to list (required)")
+	tokenListCmd.MarkFlagRequired("username")
+
+	tokenRevokeCmd.Flags().String("id", "", "ID of the token (required)")
+	tokenRevokeCmd.MarkFlagRequired("id")
+}
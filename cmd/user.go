@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
+	"gorm.io/gorm"
 
 	"github.com/engineervix/bambino/internal/config"
 	"github.com/engineervix/bambino/internal/database"
@@ -44,36 +49,219 @@ var createUserCmd = &cobra.Command{
 			fmt.Printf("No birth date provided, using default: %s\n", birthDate.Format("2006-01-02"))
 		}
 
-		// Prompt for password
-		fmt.Print("Enter password: ")
-		password, err := term.ReadPassword(int(syscall.Stdin))
+		password := readPassword(cmd)
+
+		createUser(username, password, babyName, birthDate)
+	},
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "User management commands",
+	Long:  `Commands for listing, renaming and deleting users and resetting their passwords.`,
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Long:  `Lists all users with the number of babies they can access.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := connectDatabase()
+
+		var users []models.User
+		if err := db.Order("username").Find(&users).Error; err != nil {
+			log.Fatalf("Failed to fetch users: %v", err)
+		}
+
+		if len(users) == 0 {
+			fmt.Println("No users found")
+			return
+		}
+
+		for _, user := range users {
+			var babies int64
+			if err := db.Model(&models.BabyMember{}).
+				Where("user_id = ?", user.ID).
+				Count(&babies).Error; err != nil {
+				log.Fatalf("Failed to count babies: %v", err)
+			}
+			twoFactor := ""
+			if user.TOTPEnabled() {
+				twoFactor = "  [2FA]"
+			}
+			fmt.Printf("%s  %-20s  created %s  %d baby(ies)%s\n",
+				user.ID, user.Username, user.CreatedAt.Format("2006-01-02"), babies, twoFactor)
+		}
+	},
+}
+
+var userResetPasswordCmd = &cobra.Command{
+	Use:   "reset-password",
+	Short: "Reset a user's password",
+	Long: `Sets a new password for a user and logs them out of every session.
+Use --password-stdin to read the password from standard input.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		password := readPassword(cmd)
+
+		hash, err := utils.HashPassword(password)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("Failed to hash password: %v", err)
+		}
+
+		if err := db.Model(user).Update("password_hash", hash).Error; err != nil {
+			log.Fatalf("Failed to update password: %v", err)
 		}
-		fmt.Println()
 
-		fmt.Print("Confirm password: ")
-		confirmPassword, err := term.ReadPassword(int(syscall.Stdin))
+		if err := utils.DeleteUserSessions(db, user.ID, ""); err != nil {
+			log.Fatalf("Failed to revoke sessions: %v", err)
+		}
+
+		fmt.Printf("✅ Password for '%s' reset\n", user.Username)
+		fmt.Println("   Password hashed with Argon2id; all sessions logged out")
+	},
+}
+
+var userRenameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Rename a user",
+	Long: `Changes a user's username. The user is logged out of every session, since
+sessions carry the old username.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+		newName, _ := cmd.Flags().GetString("new-username")
+
+		newName = strings.TrimSpace(newName)
+		if newName == "" {
+			log.Fatal("New username is required")
+		}
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		var count int64
+		if err := db.Model(&models.User{}).Where("username = ?", newName).Count(&count).Error; err != nil {
+			log.Fatalf("Failed to check username: %v", err)
+		}
+		if count > 0 {
+			log.Fatalf("Username '%s' is already taken", newName)
+		}
+
+		if err := db.Model(user).Update("username", newName).Error; err != nil {
+			log.Fatalf("Failed to rename user: %v", err)
+		}
+
+		if err := utils.DeleteUserSessions(db, user.ID, ""); err != nil {
+			log.Fatalf("Failed to revoke sessions: %v", err)
+		}
+
+		fmt.Printf("✅ User '%s' renamed to '%s'\n", username, newName)
+	},
+}
+
+var userDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a user",
+	Long: `Deletes a user along with their sessions, API tokens, invites and share
+links. Babies they created are handed over to another owner. A user who is the
+only owner of a baby others can access must share ownership first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		if !yes {
+			fmt.Printf("Delete user '%s'? Babies only they can access are deleted too. [y/N]: ", user.Username)
+			var answer string
+			fmt.Scanln(&answer)
+			if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+				fmt.Println("Aborted")
+				return
+			}
+		}
+
+		deleted, err := deleteUser(db, user)
 		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Println()
 
-		if string(password) != string(confirmPassword) {
-			log.Fatal("Passwords do not match")
+		fmt.Printf("✅ User '%s' deleted\n", user.Username)
+		for _, name := range deleted {
+			fmt.Printf("   Baby '%s' deleted\n", name)
+		}
+	},
+}
+
+var userDisable2FACmd = &cobra.Command{
+	Use:   "disable-2fa",
+	Short: "Turn off a user's two-factor authentication",
+	Long: `Turns off two-factor authentication for a user who has lost their
+authenticator app and recovery codes, and deletes their recovery codes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("username")
+
+		_, db := connectDatabase()
+		user := mustFindUser(db, username)
+
+		if user.TOTPSecret == "" {
+			fmt.Printf("Two-factor authentication is not enabled for '%s'\n", user.Username)
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Updates(map[string]interface{}{
+				"totp_secret":     "",
+				"totp_enabled_at": nil,
+				"totp_last_step":  0,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+		})
+		if err != nil {
+			log.Fatalf("Failed to disable two-factor authentication: %v", err)
 		}
 
-		createUser(username, string(password), babyName, birthDate)
+		fmt.Printf("✅ Two-factor authentication disabled for '%s'\n", user.Username)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(createUserCmd)
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userListCmd)
+	userCmd.AddCommand(userResetPasswordCmd)
+	userCmd.AddCommand(userRenameCmd)
+	userCmd.AddCommand(userDeleteCmd)
+	userCmd.AddCommand(userDisable2FACmd)
 
 	createUserCmd.Flags().StringP("username", "u", "", "Username for the new user (required)")
 	createUserCmd.Flags().StringP("baby-name", "b", "Baby", "Name of the baby")
 	createUserCmd.Flags().StringP("birth-date", "d", "", "Birth date (YYYY-MM-DD). Defaults to 1 week ago")
+	createUserCmd.Flags().Bool("password-stdin", false, "Read the password from standard input")
 	createUserCmd.MarkFlagRequired("username")
+
+	userResetPasswordCmd.Flags().StringP("username", "u", "", "Username of the user (required)")
+	userResetPasswordCmd.Flags().Bool("password-stdin", false, "Read the password from standard input")
+	userResetPasswordCmd.MarkFlagRequired("username")
+
+	userRenameCmd.Flags().StringP("username", "u", "", "Current username (required)")
+	userRenameCmd.Flags().StringP("new-username", "n", "", "New username (required)")
+	userRenameCmd.MarkFlagRequired("username")
+	userRenameCmd.MarkFlagRequired("new-username")
+
+	userDeleteCmd.Flags().StringP("username", "u", "", "Username of the user (required)")
+	userDeleteCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+	userDeleteCmd.MarkFlagRequired("username")
+
+	userDisable2FACmd.Flags().StringP("username", "u", "", "Username of the user (required)")
+	userDisable2FACmd.MarkFlagRequired("username")
 }
 
 func createUser(username, password, babyName string, birthDate time.Time) {
@@ -93,6 +281,9 @@ func createUser(username, password, babyName string, birthDate time.Time) {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	// Hash passwords with the configured Argon2 costs
+	configurePasswordHashing(cfg)
+
 	// Connect to database
 	db, err := database.Connect(cfg)
 	if err != nil {
@@ -134,3 +325,128 @@ func createUser(username, password, babyName string, birthDate time.Time) {
 		int(time.Since(birthDate).Hours()/24))
 	fmt.Printf("   Password hashed with Argon2id\n")
 }
+
+// readPassword reads a new password, either from standard input when
+// --password-stdin is set or by prompting twice on the terminal
+func readPassword(cmd *cobra.Command) string {
+	fromStdin, _ := cmd.Flags().GetBool("password-stdin")
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Fatalf("Failed to read password: %v", err)
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			log.Fatal("Password is required")
+		}
+		return password
+	}
+
+	// Prompt for password
+	fmt.Print("Enter password: ")
+	password, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println()
+
+	fmt.Print("Confirm password: ")
+	confirmPassword, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println()
+
+	if string(password) != string(confirmPassword) {
+		log.Fatal("Passwords do not match")
+	}
+
+	return string(password)
+}
+
+// deleteUser removes a user, returning the names of babies deleted with them.
+// Babies the user created but others own are handed over first, since the
+// babies table cascades on its creator. That holds even if the creator is no
+// longer a member of the baby.
+func deleteUser(db *gorm.DB, user *models.User) ([]string, error) {
+	var memberships []models.BabyMember
+	if err := db.Preload("Baby").Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch memberships: %w", err)
+	}
+
+	// Don't leave a baby that others can see without an owner
+	for _, m := range memberships {
+		if m.Role != models.BabyRoleOwner {
+			continue
+		}
+		newOwner, others, err := remainingMembers(db, m.BabyID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if newOwner == nil && others > 0 {
+			return nil, fmt.Errorf("'%s' is the only owner of '%s'; make someone else an owner with 'bambino baby share' first",
+				user.Username, m.Baby.Name)
+		}
+	}
+
+	var created []models.Baby
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&created).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch babies: %w", err)
+	}
+
+	// Work out what happens to each baby before changing anything
+	var orphaned []string
+	handover := map[uuid.UUID]uuid.UUID{}
+	for _, baby := range created {
+		newOwner, others, err := remainingMembers(db, baby.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case newOwner != nil:
+			handover[baby.ID] = newOwner.UserID
+		case others > 0:
+			return nil, fmt.Errorf("'%s' created '%s', which has no other owner; make someone else an owner with 'bambino baby share' first",
+				user.Username, baby.Name)
+		default:
+			orphaned = append(orphaned, baby.Name)
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for babyID, ownerID := range handover {
+			if err := tx.Model(&models.Baby{}).Where("id = ?", babyID).
+				UpdateColumn("user_id", ownerID).Error; err != nil {
+				return fmt.Errorf("failed to hand over baby: %w", err)
+			}
+		}
+
+		// Memberships, sessions, tokens, invites and share links cascade
+		if err := tx.Delete(user).Error; err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orphaned, nil
+}
+
+// remainingMembers returns the longest-standing owner of a baby other than
+// the user, if there is one, and how many other members it has
+func remainingMembers(db *gorm.DB, babyID, userID uuid.UUID) (*models.BabyMember, int, error) {
+	var others []models.BabyMember
+	if err := db.Where("baby_id = ? AND user_id <> ?", babyID, userID).
+		Order("created_at").
+		Find(&others).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch members: %w", err)
+	}
+	for i := range others {
+		if others[i].Role == models.BabyRoleOwner {
+			return &others[i], len(others), nil
+		}
+	}
+	return nil, len(others), nil
+}
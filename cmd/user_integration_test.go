@@ -158,6 +158,86 @@ func TestUserBabyRelationship_Integration(t *testing.T) {
 	assert.Equal(t, int64(0), babyCount)
 }
 
+func TestDeleteUser_Integration(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	newUser := func(username string) *models.User {
+		user := &models.User{Username: username, PasswordHash: "$2a$10$test"}
+		require.NoError(t, db.Create(user).Error)
+		return user
+	}
+	newBaby := func(owner *models.User, name string) *models.Baby {
+		baby := &models.Baby{UserID: owner.ID, Name: name, BirthDate: time.Now().AddDate(0, -1, 0)}
+		require.NoError(t, db.Create(baby).Error)
+		return baby
+	}
+	share := func(baby *models.Baby, user *models.User, role models.BabyRole) {
+		require.NoError(t, db.Create(&models.BabyMember{BabyID: baby.ID, UserID: user.ID, Role: role}).Error)
+	}
+
+	t.Run("refuses to delete the only owner of a shared baby", func(t *testing.T) {
+		owner := newUser("owner1")
+		nanny := newUser("nanny1")
+		baby := newBaby(owner, "Shared Baby")
+		share(baby, nanny, models.BabyRoleCaregiver)
+
+		_, err := deleteUser(db, owner)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only owner")
+
+		var count int64
+		db.Model(&models.User{}).Where("id = ?", owner.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("hands babies over to another owner", func(t *testing.T) {
+		parent := newUser("parent1")
+		partner := newUser("partner1")
+		baby := newBaby(parent, "Twin A")
+		share(baby, partner, models.BabyRoleOwner)
+		solo := newBaby(parent, "Solo Baby")
+
+		deleted, err := deleteUser(db, parent)
+		require.NoError(t, err)
+		assert.Equal(t, []string{solo.Name}, deleted)
+
+		var loaded models.Baby
+		require.NoError(t, db.First(&loaded, "id = ?", baby.ID).Error)
+		assert.Equal(t, partner.ID, loaded.UserID)
+
+		var count int64
+		db.Model(&models.Baby{}).Where("id = ?", solo.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.Model(&models.BabyMember{}).Where("user_id = ?", parent.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("hands over babies the user is no longer a member of", func(t *testing.T) {
+		parent := newUser("parent2")
+		partner := newUser("partner2")
+		baby := newBaby(parent, "Twin B")
+		share(baby, partner, models.BabyRoleOwner)
+		activity := &models.Activity{BabyID: baby.ID, Type: models.ActivityTypeDiaper, StartTime: time.Now()}
+		require.NoError(t, db.Create(activity).Error)
+
+		// The partner removed the creator from the baby
+		require.NoError(t, db.Where("baby_id = ? AND user_id = ?", baby.ID, parent.ID).Delete(&models.BabyMember{}).Error)
+
+		deleted, err := deleteUser(db, parent)
+		require.NoError(t, err)
+		assert.Empty(t, deleted)
+
+		var loaded models.Baby
+		require.NoError(t, db.First(&loaded, "id = ?", baby.ID).Error)
+		assert.Equal(t, partner.ID, loaded.UserID)
+
+		var count int64
+		db.Model(&models.Activity{}).Where("id = ?", activity.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
 func TestDatabaseMigration_Integration(t *testing.T) {
 	// This tests that migrations can run multiple times without error
 	tmpfile, err := os.CreateTemp("", "test-*.db")
@@ -2,8 +2,12 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/mail"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -18,32 +22,136 @@ type Config struct {
 	DBPassword             string
 	DBSSLMode              string
 	SessionSecret          string
+	SessionSecrets         string
 	SessionMaxAge          int
 	AllowedOrigins         string
 	SentryDSN              string
 	SentryTracesSampleRate float64
+	// Argon2 cost parameters for password hashes. Existing hashes made with
+	// weaker parameters are upgraded when their users next log in.
+	Argon2Memory      uint32 // in KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	// AuthProxyHeader enables forward authentication: requests from
+	// AuthProxyTrustedProxies are logged in as the user named in this header
+	AuthProxyHeader         string
+	AuthProxyTrustedProxies string
+	AuthProxyAutoProvision  bool
+	// OIDCIssuerURL enables login through an OpenID Connect provider
+	OIDCIssuerURL     string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string // defaults to /api/auth/oidc/callback on the request's host
+	OIDCScopes        string
+	OIDCUsernameClaim string
+	// OIDCRoleClaim names a claim whose values are mapped to baby roles by
+	// OIDCRoleMapping, e.g. "parents=owner,family=caregiver"
+	OIDCRoleClaim   string
+	OIDCRoleMapping string
+	// OIDCRoleBabies lists the IDs of the babies the mapped role is granted
+	// on; babies not listed are left to their owners
+	OIDCRoleBabies    string
+	OIDCAutoProvision bool
+	// ActivityTrashRetentionDays is how long deleted activities can be
+	// restored before they are purged; 0 keeps them forever
+	ActivityTrashRetentionDays int
+	// EventsPostgresNotify shares live events between instances through
+	// Postgres LISTEN/NOTIFY, for when several run against one database
+	EventsPostgresNotify bool
+	// MQTTBrokerURL enables publishing baby state to an MQTT broker, e.g.
+	// "tcp://localhost:1883". Only one instance should have it set.
+	MQTTBrokerURL   string
+	MQTTUsername    string
+	MQTTPassword    string
+	MQTTClientID    string
+	MQTTTopicPrefix string
+	// MQTTDiscovery announces babies to Home Assistant through MQTT discovery
+	MQTTDiscovery       bool
+	MQTTDiscoveryPrefix string
+	// MQTTUser is the user whose babies are published, and who commands
+	// received over MQTT are carried out as
+	MQTTUser string
+	// SMTPHost enables email notifications, sent through this server
+	SMTPHost     string
+	SMTPPort     string // 465 uses implicit TLS, other ports STARTTLS if offered
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// VAPIDPublicKey and VAPIDPrivateKey enable Web Push notifications.
+	// VAPIDSubject is a mailto: or https: URL push services can contact.
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	VAPIDSubject    string
+	// OutboundAllowedNetworks lists loopback, private or link-local networks
+	// that webhooks and notification channels may still be sent to, such as
+	// an ntfy server on the local network
+	OutboundAllowedNetworks string
 }
 
 func Load() *Config {
 	maxAge, _ := strconv.Atoi(getEnv("SESSION_MAX_AGE", "86400"))
 	tracesSampleRate, _ := strconv.ParseFloat(getEnv("SENTRY_TRACES_SAMPLE_RATE", "0.1"), 64)
+	argon2Memory, _ := strconv.ParseUint(getEnv("ARGON2_MEMORY", "65536"), 10, 32)
+	argon2Iterations, _ := strconv.ParseUint(getEnv("ARGON2_ITERATIONS", "3"), 10, 32)
+	argon2Parallelism, _ := strconv.ParseUint(getEnv("ARGON2_PARALLELISM", "2"), 10, 8)
+	authProxyAutoProvision, _ := strconv.ParseBool(getEnv("AUTH_PROXY_AUTO_PROVISION", "true"))
+	oidcAutoProvision, _ := strconv.ParseBool(getEnv("OIDC_AUTO_PROVISION", "true"))
+	trashRetentionDays, _ := strconv.Atoi(getEnv("ACTIVITY_TRASH_RETENTION_DAYS", "30"))
+	eventsPostgresNotify, _ := strconv.ParseBool(getEnv("EVENTS_POSTGRES_NOTIFY", "false"))
+	mqttDiscovery, _ := strconv.ParseBool(getEnv("MQTT_DISCOVERY", "true"))
 
 	return &Config{
-		Port:                   getEnv("PORT", "8080"),
-		Env:                    getEnv("ENV", "development"),
-		DBType:                 getEnv("DB_TYPE", "sqlite"),
-		DBPath:                 getEnv("DB_PATH", "./bambino.db"),
-		DBHost:                 getEnv("DB_HOST", "localhost"),
-		DBPort:                 getEnv("DB_PORT", "5432"),
-		DBName:                 getEnv("DB_NAME", "baby"),
-		DBUser:                 getEnv("DB_USER", "postgres"),
-		DBPassword:             getEnv("DB_PASSWORD", ""),
-		DBSSLMode:              getEnv("DB_SSLMODE", "disable"),
-		SessionSecret:          getEnv("SESSION_SECRET", "change-me"),
-		SessionMaxAge:          maxAge,
-		AllowedOrigins:         getEnv("ALLOWED_ORIGINS", "http://localhost:5173"),
-		SentryDSN:              getEnv("SENTRY_DSN", ""),
-		SentryTracesSampleRate: tracesSampleRate,
+		Port:                       getEnv("PORT", "8080"),
+		Env:                        getEnv("ENV", "development"),
+		DBType:                     getEnv("DB_TYPE", "sqlite"),
+		DBPath:                     getEnv("DB_PATH", "./bambino.db"),
+		DBHost:                     getEnv("DB_HOST", "localhost"),
+		DBPort:                     getEnv("DB_PORT", "5432"),
+		DBName:                     getEnv("DB_NAME", "baby"),
+		DBUser:                     getEnv("DB_USER", "postgres"),
+		DBPassword:                 getEnv("DB_PASSWORD", ""),
+		DBSSLMode:                  getEnv("DB_SSLMODE", "disable"),
+		SessionSecret:              getEnv("SESSION_SECRET", "change-me"),
+		SessionSecrets:             getEnv("SESSION_SECRETS", ""),
+		SessionMaxAge:              maxAge,
+		AllowedOrigins:             getEnv("ALLOWED_ORIGINS", "http://localhost:5173"),
+		SentryDSN:                  getEnv("SENTRY_DSN", ""),
+		SentryTracesSampleRate:     tracesSampleRate,
+		Argon2Memory:               uint32(argon2Memory),
+		Argon2Iterations:           uint32(argon2Iterations),
+		Argon2Parallelism:          uint8(argon2Parallelism),
+		AuthProxyHeader:            getEnv("AUTH_PROXY_HEADER", ""),
+		AuthProxyTrustedProxies:    getEnv("AUTH_PROXY_TRUSTED_PROXIES", ""),
+		AuthProxyAutoProvision:     authProxyAutoProvision,
+		OIDCIssuerURL:              getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:               getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:           getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:            getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:                 getEnv("OIDC_SCOPES", "openid profile email"),
+		OIDCUsernameClaim:          getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCRoleClaim:              getEnv("OIDC_ROLE_CLAIM", ""),
+		OIDCRoleMapping:            getEnv("OIDC_ROLE_MAPPING", ""),
+		OIDCRoleBabies:             getEnv("OIDC_ROLE_BABIES", ""),
+		OIDCAutoProvision:          oidcAutoProvision,
+		ActivityTrashRetentionDays: trashRetentionDays,
+		EventsPostgresNotify:       eventsPostgresNotify,
+		MQTTBrokerURL:              getEnv("MQTT_BROKER_URL", ""),
+		MQTTUsername:               getEnv("MQTT_USERNAME", ""),
+		MQTTPassword:               getEnv("MQTT_PASSWORD", ""),
+		MQTTClientID:               getEnv("MQTT_CLIENT_ID", "bambino"),
+		MQTTTopicPrefix:            getEnv("MQTT_TOPIC_PREFIX", "bambino"),
+		MQTTDiscovery:              mqttDiscovery,
+		MQTTDiscoveryPrefix:        getEnv("MQTT_DISCOVERY_PREFIX", "homeassistant"),
+		MQTTUser:                   getEnv("MQTT_USER", ""),
+		SMTPHost:                   getEnv("SMTP_HOST", ""),
+		SMTPPort:                   getEnv("SMTP_PORT", "587"),
+		SMTPUsername:               getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:               getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:                   getEnv("SMTP_FROM", ""),
+		VAPIDPublicKey:             getEnv("VAPID_PUBLIC_KEY", ""),
+		VAPIDPrivateKey:            getEnv("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:               getEnv("VAPID_SUBJECT", ""),
+		OutboundAllowedNetworks:    getEnv("OUTBOUND_ALLOWED_NETWORKS", ""),
 	}
 }
 
@@ -56,8 +164,12 @@ func getEnv(key, defaultValue string) string {
 
 // Validate ensures required configuration values are properly set
 func (c *Config) Validate() error {
-	if c.SessionSecret == "change-me" && c.Env == "production" {
-		return errors.New("SESSION_SECRET must be set to a secure value in production")
+	if c.SessionSecrets != "" {
+		if _, err := c.SessionKeyPairs(); err != nil {
+			return err
+		}
+	} else if c.SessionSecret == "change-me" && c.Env == "production" {
+		return errors.New("SESSION_SECRET must be set to a secure value in production (or set SESSION_SECRETS)")
 	}
 
 	if c.DBType == "postgres" && c.DBPassword == "" && c.Env == "production" {
@@ -75,5 +187,139 @@ func (c *Config) Validate() error {
 		return errors.New("DB_SSLMODE must be one of: disable, require, verify-ca, verify-full")
 	}
 
+	// Argon2 needs at least 8 KiB of memory per lane
+	if c.Argon2Memory != 0 && c.Argon2Memory < 8*uint32(c.Argon2Parallelism) {
+		return errors.New("ARGON2_MEMORY must be at least 8 times ARGON2_PARALLELISM")
+	}
+
+	if c.AuthProxyHeader != "" {
+		networks, err := c.AuthProxyNetworks()
+		if err != nil {
+			return err
+		}
+		if len(networks) == 0 {
+			return errors.New("AUTH_PROXY_TRUSTED_PROXIES must be set when AUTH_PROXY_HEADER is set")
+		}
+	}
+
+	if c.ActivityTrashRetentionDays < 0 {
+		return errors.New("ACTIVITY_TRASH_RETENTION_DAYS must not be negative")
+	}
+
+	if c.EventsPostgresNotify && c.DBType != "postgres" {
+		return errors.New("EVENTS_POSTGRES_NOTIFY requires DB_TYPE=postgres")
+	}
+
+	if c.MQTTEnabled() {
+		if c.MQTTUser == "" {
+			return errors.New("MQTT_USER must be set when MQTT_BROKER_URL is set")
+		}
+		if c.MQTTTopicPrefix == "" || strings.ContainsAny(c.MQTTTopicPrefix, "+#") {
+			return errors.New("MQTT_TOPIC_PREFIX must be set and must not contain wildcards")
+		}
+	}
+
+	if c.SMTPEnabled() {
+		if c.SMTPFrom == "" {
+			return errors.New("SMTP_FROM must be set when SMTP_HOST is set")
+		}
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			return fmt.Errorf("invalid SMTP_FROM: %w", err)
+		}
+	}
+
+	if (c.VAPIDPublicKey == "") != (c.VAPIDPrivateKey == "") {
+		return errors.New("VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY must be set together")
+	}
+	if c.WebPushEnabled() && !strings.HasPrefix(c.VAPIDSubject, "mailto:") && !strings.HasPrefix(c.VAPIDSubject, "https://") {
+		return errors.New("VAPID_SUBJECT must be a mailto: or https:// URL when VAPID keys are set")
+	}
+
+	if _, err := c.OutboundNetworks(); err != nil {
+		return err
+	}
+
+	if c.OIDCEnabled() {
+		if c.OIDCClientID == "" {
+			return errors.New("OIDC_CLIENT_ID must be set when OIDC_ISSUER_URL is set")
+		}
+		if c.OIDCRoleClaim != "" {
+			mapping, err := c.OIDCRoles()
+			if err != nil {
+				return err
+			}
+			if len(mapping) == 0 {
+				return errors.New("OIDC_ROLE_MAPPING must be set when OIDC_ROLE_CLAIM is set")
+			}
+			if _, err := c.OIDCRoleBabyIDs(); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
+
+// MQTTEnabled reports whether baby state is published over MQTT
+func (c *Config) MQTTEnabled() bool {
+	return c.MQTTBrokerURL != ""
+}
+
+// SMTPEnabled reports whether email notifications can be sent
+func (c *Config) SMTPEnabled() bool {
+	return c.SMTPHost != ""
+}
+
+// WebPushEnabled reports whether Web Push notifications can be sent
+func (c *Config) WebPushEnabled() bool {
+	return c.VAPIDPublicKey != "" && c.VAPIDPrivateKey != ""
+}
+
+// PostgresDSN returns the connection string for the PostgreSQL database
+func (c *Config) PostgresDSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		c.DBHost, c.DBUser, c.DBPassword, c.DBName, c.DBPort, c.DBSSLMode)
+}
+
+// AuthProxyNetworks parses AUTH_PROXY_TRUSTED_PROXIES, a comma-separated list
+// of CIDRs or single IP addresses
+func (c *Config) AuthProxyNetworks() ([]*net.IPNet, error) {
+	return parseNetworks("AUTH_PROXY_TRUSTED_PROXIES", c.AuthProxyTrustedProxies)
+}
+
+// OutboundNetworks parses OUTBOUND_ALLOWED_NETWORKS, a comma-separated list
+// of CIDRs or single IP addresses
+func (c *Config) OutboundNetworks() ([]*net.IPNet, error) {
+	return parseNetworks("OUTBOUND_ALLOWED_NETWORKS", c.OutboundAllowedNetworks)
+}
+
+// parseNetworks reads a comma-separated list of CIDRs or single IP
+// addresses from the named setting
+func parseNetworks(name, value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%s: invalid address %q", name, entry)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid CIDR %q", name, entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
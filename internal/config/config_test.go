@@ -28,21 +28,34 @@ func TestLoad(t *testing.T) {
 			name:    "default values",
 			envVars: map[string]string{},
 			expected: &Config{
-				Port:                   "8080",
-				Env:                    "development",
-				DBType:                 "sqlite",
-				DBPath:                 "./bambino.db",
-				DBHost:                 "localhost",
-				DBPort:                 "5432",
-				DBName:                 "baby",
-				DBUser:                 "postgres",
-				DBPassword:             "",
-				DBSSLMode:              "disable",
-				SessionSecret:          "change-me",
-				SessionMaxAge:          86400,
-				AllowedOrigins:         "http://localhost:5173",
-				SentryDSN:              "",
-				SentryTracesSampleRate: 0.1,
+				Port:                       "8080",
+				Env:                        "development",
+				DBType:                     "sqlite",
+				DBPath:                     "./bambino.db",
+				DBHost:                     "localhost",
+				DBPort:                     "5432",
+				DBName:                     "baby",
+				DBUser:                     "postgres",
+				DBPassword:                 "",
+				DBSSLMode:                  "disable",
+				SessionSecret:              "change-me",
+				SessionMaxAge:              86400,
+				AllowedOrigins:             "http://localhost:5173",
+				SentryDSN:                  "",
+				SentryTracesSampleRate:     0.1,
+				Argon2Memory:               65536,
+				Argon2Iterations:           3,
+				Argon2Parallelism:          2,
+				AuthProxyAutoProvision:     true,
+				OIDCScopes:                 "openid profile email",
+				OIDCUsernameClaim:          "preferred_username",
+				OIDCAutoProvision:          true,
+				ActivityTrashRetentionDays: 30,
+				MQTTClientID:               "bambino",
+				MQTTTopicPrefix:            "bambino",
+				MQTTDiscovery:              true,
+				MQTTDiscoveryPrefix:        "homeassistant",
+				SMTPPort:                   "587",
 			},
 		},
 		{
@@ -59,21 +72,34 @@ func TestLoad(t *testing.T) {
 				"SESSION_MAX_AGE": "3600",
 			},
 			expected: &Config{
-				Port:                   "3000",
-				Env:                    "production",
-				DBType:                 "postgres",
-				DBPath:                 "./bambino.db",
-				DBHost:                 "db.example.com",
-				DBPort:                 "5433",
-				DBName:                 "baby",
-				DBUser:                 "postgres",
-				DBPassword:             "secret",
-				DBSSLMode:              "require",
-				SessionSecret:          "very-secret-key",
-				SessionMaxAge:          3600,
-				AllowedOrigins:         "http://localhost:5173",
-				SentryDSN:              "",
-				SentryTracesSampleRate: 0.1,
+				Port:                       "3000",
+				Env:                        "production",
+				DBType:                     "postgres",
+				DBPath:                     "./bambino.db",
+				DBHost:                     "db.example.com",
+				DBPort:                     "5433",
+				DBName:                     "baby",
+				DBUser:                     "postgres",
+				DBPassword:                 "secret",
+				DBSSLMode:                  "require",
+				SessionSecret:              "very-secret-key",
+				SessionMaxAge:              3600,
+				AllowedOrigins:             "http://localhost:5173",
+				SentryDSN:                  "",
+				SentryTracesSampleRate:     0.1,
+				Argon2Memory:               65536,
+				Argon2Iterations:           3,
+				Argon2Parallelism:          2,
+				AuthProxyAutoProvision:     true,
+				OIDCScopes:                 "openid profile email",
+				OIDCUsernameClaim:          "preferred_username",
+				OIDCAutoProvision:          true,
+				ActivityTrashRetentionDays: 30,
+				MQTTClientID:               "bambino",
+				MQTTTopicPrefix:            "bambino",
+				MQTTDiscovery:              true,
+				MQTTDiscoveryPrefix:        "homeassistant",
+				SMTPPort:                   "587",
 			},
 		},
 		{
@@ -83,21 +109,112 @@ func TestLoad(t *testing.T) {
 				"SENTRY_TRACES_SAMPLE_RATE": "0.5",
 			},
 			expected: &Config{
-				Port:                   "8080",
-				Env:                    "development",
-				DBType:                 "sqlite",
-				DBPath:                 "./bambino.db",
-				DBHost:                 "localhost",
-				DBPort:                 "5432",
-				DBName:                 "baby",
-				DBUser:                 "postgres",
-				DBPassword:             "",
-				DBSSLMode:              "disable",
-				SessionSecret:          "change-me",
-				SessionMaxAge:          86400,
-				AllowedOrigins:         "http://localhost:5173",
-				SentryDSN:              "https://example@sentry.io/123",
-				SentryTracesSampleRate: 0.5,
+				Port:                       "8080",
+				Env:                        "development",
+				DBType:                     "sqlite",
+				DBPath:                     "./bambino.db",
+				DBHost:                     "localhost",
+				DBPort:                     "5432",
+				DBName:                     "baby",
+				DBUser:                     "postgres",
+				DBPassword:                 "",
+				DBSSLMode:                  "disable",
+				SessionSecret:              "change-me",
+				SessionMaxAge:              86400,
+				AllowedOrigins:             "http://localhost:5173",
+				SentryDSN:                  "https://example@sentry.io/123",
+				SentryTracesSampleRate:     0.5,
+				Argon2Memory:               65536,
+				Argon2Iterations:           3,
+				Argon2Parallelism:          2,
+				AuthProxyAutoProvision:     true,
+				OIDCScopes:                 "openid profile email",
+				OIDCUsernameClaim:          "preferred_username",
+				OIDCAutoProvision:          true,
+				ActivityTrashRetentionDays: 30,
+				MQTTClientID:               "bambino",
+				MQTTTopicPrefix:            "bambino",
+				MQTTDiscovery:              true,
+				MQTTDiscoveryPrefix:        "homeassistant",
+				SMTPPort:                   "587",
+			},
+		},
+		{
+			name: "argon2 configuration",
+			envVars: map[string]string{
+				"ARGON2_MEMORY":      "131072",
+				"ARGON2_ITERATIONS":  "4",
+				"ARGON2_PARALLELISM": "4",
+			},
+			expected: &Config{
+				Port:                       "8080",
+				Env:                        "development",
+				DBType:                     "sqlite",
+				DBPath:                     "./bambino.db",
+				DBHost:                     "localhost",
+				DBPort:                     "5432",
+				DBName:                     "baby",
+				DBUser:                     "postgres",
+				DBPassword:                 "",
+				DBSSLMode:                  "disable",
+				SessionSecret:              "change-me",
+				SessionMaxAge:              86400,
+				AllowedOrigins:             "http://localhost:5173",
+				SentryDSN:                  "",
+				SentryTracesSampleRate:     0.1,
+				Argon2Memory:               131072,
+				Argon2Iterations:           4,
+				Argon2Parallelism:          4,
+				AuthProxyAutoProvision:     true,
+				OIDCScopes:                 "openid profile email",
+				OIDCUsernameClaim:          "preferred_username",
+				OIDCAutoProvision:          true,
+				ActivityTrashRetentionDays: 30,
+				MQTTClientID:               "bambino",
+				MQTTTopicPrefix:            "bambino",
+				MQTTDiscovery:              true,
+				MQTTDiscoveryPrefix:        "homeassistant",
+				SMTPPort:                   "587",
+			},
+		},
+		{
+			name: "auth proxy configuration",
+			envVars: map[string]string{
+				"AUTH_PROXY_HEADER":          "Remote-User",
+				"AUTH_PROXY_TRUSTED_PROXIES": "10.0.0.0/8",
+				"AUTH_PROXY_AUTO_PROVISION":  "false",
+			},
+			expected: &Config{
+				Port:                       "8080",
+				Env:                        "development",
+				DBType:                     "sqlite",
+				DBPath:                     "./bambino.db",
+				DBHost:                     "localhost",
+				DBPort:                     "5432",
+				DBName:                     "baby",
+				DBUser:                     "postgres",
+				DBPassword:                 "",
+				DBSSLMode:                  "disable",
+				SessionSecret:              "change-me",
+				SessionMaxAge:              86400,
+				AllowedOrigins:             "http://localhost:5173",
+				SentryDSN:                  "",
+				SentryTracesSampleRate:     0.1,
+				Argon2Memory:               65536,
+				Argon2Iterations:           3,
+				Argon2Parallelism:          2,
+				AuthProxyHeader:            "Remote-User",
+				AuthProxyTrustedProxies:    "10.0.0.0/8",
+				AuthProxyAutoProvision:     false,
+				OIDCScopes:                 "openid profile email",
+				OIDCUsernameClaim:          "preferred_username",
+				OIDCAutoProvision:          true,
+				ActivityTrashRetentionDays: 30,
+				MQTTClientID:               "bambino",
+				MQTTTopicPrefix:            "bambino",
+				MQTTDiscovery:              true,
+				MQTTDiscoveryPrefix:        "homeassistant",
+				SMTPPort:                   "587",
 			},
 		},
 	}
@@ -169,6 +286,200 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "DB_SSLMODE must be one of",
 		},
+		{
+			name: "argon2 memory too low for parallelism",
+			config: &Config{
+				Env:               "development",
+				DBType:            "sqlite",
+				SessionSecret:     "secret",
+				Argon2Memory:      16,
+				Argon2Parallelism: 4,
+			},
+			wantErr: true,
+			errMsg:  "ARGON2_MEMORY must be at least 8 times ARGON2_PARALLELISM",
+		},
+		{
+			name: "auth proxy header without trusted proxies",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				AuthProxyHeader: "Remote-User",
+			},
+			wantErr: true,
+			errMsg:  "AUTH_PROXY_TRUSTED_PROXIES must be set",
+		},
+		{
+			name: "invalid auth proxy CIDR",
+			config: &Config{
+				Env:                     "development",
+				DBType:                  "sqlite",
+				SessionSecret:           "secret",
+				AuthProxyHeader:         "Remote-User",
+				AuthProxyTrustedProxies: "10.0.0.0/33",
+			},
+			wantErr: true,
+			errMsg:  "invalid CIDR",
+		},
+		{
+			name: "valid auth proxy config",
+			config: &Config{
+				Env:                     "development",
+				DBType:                  "sqlite",
+				SessionSecret:           "secret",
+				AuthProxyHeader:         "Remote-User",
+				AuthProxyTrustedProxies: "172.16.0.0/12, 10.0.0.5, ::1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid outbound network",
+			config: &Config{
+				Env:                     "development",
+				DBType:                  "sqlite",
+				SessionSecret:           "secret",
+				OutboundAllowedNetworks: "192.168.1.0/24, ntfy.lan",
+			},
+			wantErr: true,
+			errMsg:  "OUTBOUND_ALLOWED_NETWORKS: invalid address",
+		},
+		{
+			name: "OIDC without a client ID",
+			config: &Config{
+				Env:           "development",
+				DBType:        "sqlite",
+				SessionSecret: "secret",
+				OIDCIssuerURL: "https://id.example.com",
+			},
+			wantErr: true,
+			errMsg:  "OIDC_CLIENT_ID must be set",
+		},
+		{
+			name: "OIDC role claim without a mapping",
+			config: &Config{
+				Env:           "development",
+				DBType:        "sqlite",
+				SessionSecret: "secret",
+				OIDCIssuerURL: "https://id.example.com",
+				OIDCClientID:  "bambino",
+				OIDCRoleClaim: "groups",
+			},
+			wantErr: true,
+			errMsg:  "OIDC_ROLE_MAPPING must be set",
+		},
+		{
+			name: "OIDC role mapping with an unknown role",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				OIDCIssuerURL:   "https://id.example.com",
+				OIDCClientID:    "bambino",
+				OIDCRoleClaim:   "groups",
+				OIDCRoleMapping: "parents=owner,friends=admin",
+			},
+			wantErr: true,
+			errMsg:  "unknown role",
+		},
+		{
+			name: "OIDC role babies that aren't IDs",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				OIDCIssuerURL:   "https://id.example.com",
+				OIDCClientID:    "bambino",
+				OIDCRoleClaim:   "groups",
+				OIDCRoleMapping: "parents=owner",
+				OIDCRoleBabies:  "the-twins",
+			},
+			wantErr: true,
+			errMsg:  "OIDC_ROLE_BABIES: invalid baby ID",
+		},
+		{
+			name: "valid OIDC config",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				OIDCIssuerURL:   "https://id.example.com",
+				OIDCClientID:    "bambino",
+				OIDCRoleClaim:   "groups",
+				OIDCRoleMapping: "parents=owner, family=caregiver",
+				OIDCRoleBabies:  "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b",
+			},
+			wantErr: false,
+		},
+		{
+			name: "postgres events without postgres",
+			config: &Config{
+				Env:                  "development",
+				DBType:               "sqlite",
+				SessionSecret:        "secret",
+				EventsPostgresNotify: true,
+			},
+			wantErr: true,
+			errMsg:  "EVENTS_POSTGRES_NOTIFY requires DB_TYPE=postgres",
+		},
+		{
+			name: "MQTT without a user",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				MQTTBrokerURL:   "tcp://localhost:1883",
+				MQTTTopicPrefix: "bambino",
+			},
+			wantErr: true,
+			errMsg:  "MQTT_USER must be set when MQTT_BROKER_URL is set",
+		},
+		{
+			name: "MQTT topic prefix with wildcards",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				MQTTBrokerURL:   "tcp://localhost:1883",
+				MQTTTopicPrefix: "bambino/#",
+				MQTTUser:        "parent",
+			},
+			wantErr: true,
+			errMsg:  "MQTT_TOPIC_PREFIX must be set and must not contain wildcards",
+		},
+		{
+			name: "SMTP without a from address",
+			config: &Config{
+				Env:           "development",
+				DBType:        "sqlite",
+				SessionSecret: "secret",
+				SMTPHost:      "smtp.example.com",
+			},
+			wantErr: true,
+			errMsg:  "SMTP_FROM must be set when SMTP_HOST is set",
+		},
+		{
+			name: "VAPID private key without a public key",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				VAPIDPrivateKey: "private",
+			},
+			wantErr: true,
+			errMsg:  "VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY must be set together",
+		},
+		{
+			name: "VAPID keys without a subject",
+			config: &Config{
+				Env:             "development",
+				DBType:          "sqlite",
+				SessionSecret:   "secret",
+				VAPIDPublicKey:  "public",
+				VAPIDPrivateKey: "private",
+			},
+			wantErr: true,
+			errMsg:  "VAPID_SUBJECT must be a mailto: or https:// URL when VAPID keys are set",
+		},
 		{
 			name: "valid postgres config",
 			config: &Config{
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// oidcRoles are the baby roles OIDC_ROLE_MAPPING may grant
+var oidcRoles = map[string]bool{
+	"owner":     true,
+	"caregiver": true,
+	"viewer":    true,
+}
+
+// OIDCEnabled reports whether login through an OpenID Connect provider is configured
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuerURL != ""
+}
+
+// OIDCScopeList returns the scopes to request, always including openid
+func (c *Config) OIDCScopeList() []string {
+	scopes := []string{"openid"}
+	for _, scope := range strings.FieldsFunc(c.OIDCScopes, func(r rune) bool {
+		return r == ' ' || r == ','
+	}) {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// OIDCRoles parses OIDC_ROLE_MAPPING, a comma-separated list of
+// claim-value=role pairs
+func (c *Config) OIDCRoles() (map[string]string, error) {
+	roles := map[string]string{}
+	for _, entry := range strings.Split(c.OIDCRoleMapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		value, role, found := strings.Cut(entry, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !found || value == "" {
+			return nil, fmt.Errorf("OIDC_ROLE_MAPPING: invalid entry %q", entry)
+		}
+		if !oidcRoles[role] {
+			return nil, fmt.Errorf("OIDC_ROLE_MAPPING: unknown role %q", role)
+		}
+		roles[value] = role
+	}
+	return roles, nil
+}
+
+// OIDCRoleBabyIDs parses OIDC_ROLE_BABIES, a comma-separated list of the
+// babies the OIDC role mapping applies to
+func (c *Config) OIDCRoleBabyIDs() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, entry := range strings.Split(c.OIDCRoleBabies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, err := uuid.Parse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC_ROLE_BABIES: invalid baby ID %q", entry)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
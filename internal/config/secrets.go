@@ -0,0 +1,108 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// sessionSigningKeyLength is the size of generated signing keys, suited
+	// to HMAC-SHA256
+	sessionSigningKeyLength = 64
+	// sessionEncryptionKeyLength selects AES-256 for generated encryption keys
+	sessionEncryptionKeyLength = 32
+	// minSessionSigningKeyLength is the shortest signing key accepted in SESSION_SECRETS
+	minSessionSigningKeyLength = 32
+)
+
+// ErrInvalidSessionSecrets is returned when SESSION_SECRETS can't be parsed
+var ErrInvalidSessionSecrets = errors.New("SESSION_SECRETS must be a comma-separated list of base64 signing:encryption key pairs")
+
+// SessionKeyPairs returns the keys used to sign and encrypt session cookies as
+// alternating signing and encryption keys, newest first. New cookies use the
+// first pair; the others are only used to read existing cookies, so secrets
+// can be rotated without logging everyone out.
+//
+// Without SESSION_SECRETS, a single pair is made from SESSION_SECRET.
+func (c *Config) SessionKeyPairs() ([][]byte, error) {
+	if strings.TrimSpace(c.SessionSecrets) == "" {
+		// Keep the secret itself as the signing key so share links signed
+		// before SESSION_SECRETS existed stay valid
+		encryptionKey := sha256.Sum256([]byte("bambino-session-encryption:" + c.SessionSecret))
+		return [][]byte{[]byte(c.SessionSecret), encryptionKey[:]}, nil
+	}
+
+	var pairs [][]byte
+	for _, entry := range strings.Split(c.SessionSecrets, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 {
+			return nil, ErrInvalidSessionSecrets
+		}
+
+		signingKey, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, ErrInvalidSessionSecrets
+		}
+		if len(signingKey) < minSessionSigningKeyLength {
+			return nil, fmt.Errorf("SESSION_SECRETS signing keys must be at least %d bytes", minSessionSigningKeyLength)
+		}
+
+		encryptionKey, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, ErrInvalidSessionSecrets
+		}
+		switch len(encryptionKey) {
+		case 16, 24, 32:
+		default:
+			return nil, errors.New("SESSION_SECRETS encryption keys must be 16, 24 or 32 bytes")
+		}
+
+		pairs = append(pairs, signingKey, encryptionKey)
+	}
+
+	if len(pairs) == 0 {
+		return nil, ErrInvalidSessionSecrets
+	}
+	return pairs, nil
+}
+
+// SigningKeys returns the signing key of each session key pair, newest first.
+// It is also used to sign share links. The configuration must have been
+// validated.
+func (c *Config) SigningKeys() [][]byte {
+	pairs, err := c.SessionKeyPairs()
+	if err != nil {
+		return nil
+	}
+
+	keys := make([][]byte, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+	}
+	return keys
+}
+
+// GenerateSessionKeyPair returns a new random key pair in the SESSION_SECRETS format
+func GenerateSessionKeyPair() (string, error) {
+	signingKey := make([]byte, sessionSigningKeyLength)
+	if _, err := rand.Read(signingKey); err != nil {
+		return "", err
+	}
+
+	encryptionKey := make([]byte, sessionEncryptionKeyLength)
+	if _, err := rand.Read(encryptionKey); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(signingKey) + ":" +
+		base64.StdEncoding.EncodeToString(encryptionKey), nil
+}
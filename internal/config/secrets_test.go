@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionKeyPairs(t *testing.T) {
+	t.Run("derived from SESSION_SECRET", func(t *testing.T) {
+		cfg := &Config{SessionSecret: "a-long-session-secret"}
+
+		pairs, err := cfg.SessionKeyPairs()
+		require.NoError(t, err)
+		require.Len(t, pairs, 2)
+		assert.Equal(t, []byte("a-long-session-secret"), pairs[0])
+		assert.Len(t, pairs[1], 32)
+
+		assert.Equal(t, [][]byte{[]byte("a-long-session-secret")}, cfg.SigningKeys())
+	})
+
+	t.Run("parsed from SESSION_SECRETS, newest first", func(t *testing.T) {
+		newPair, err := GenerateSessionKeyPair()
+		require.NoError(t, err)
+		oldPair, err := GenerateSessionKeyPair()
+		require.NoError(t, err)
+
+		cfg := &Config{SessionSecret: "ignored", SessionSecrets: newPair + ", " + oldPair}
+
+		pairs, err := cfg.SessionKeyPairs()
+		require.NoError(t, err)
+		require.Len(t, pairs, 4)
+
+		newSigning, _ := base64.StdEncoding.DecodeString(strings.Split(newPair, ":")[0])
+		oldSigning, _ := base64.StdEncoding.DecodeString(strings.Split(oldPair, ":")[0])
+		assert.Equal(t, newSigning, pairs[0])
+		assert.Len(t, pairs[0], 64)
+		assert.Len(t, pairs[1], 32)
+		assert.Equal(t, [][]byte{newSigning, oldSigning}, cfg.SigningKeys())
+	})
+
+	t.Run("invalid SESSION_SECRETS", func(t *testing.T) {
+		key32 := base64.StdEncoding.EncodeToString(make([]byte, 32))
+		key10 := base64.StdEncoding.EncodeToString(make([]byte, 10))
+
+		invalid := []string{
+			",",
+			"no-separator",
+			"not base64:" + key32,
+			key10 + ":" + key32,
+			key32 + ":" + key10,
+			key32 + ":" + key32 + ":" + key32,
+		}
+
+		for _, secrets := range invalid {
+			cfg := &Config{SessionSecrets: secrets}
+			_, err := cfg.SessionKeyPairs()
+			assert.Error(t, err, secrets)
+			assert.Error(t, cfg.Validate(), secrets)
+			assert.Nil(t, cfg.SigningKeys(), secrets)
+		}
+	})
+
+	t.Run("SESSION_SECRETS satisfies production validation", func(t *testing.T) {
+		pair, err := GenerateSessionKeyPair()
+		require.NoError(t, err)
+
+		cfg := &Config{Env: "production", DBType: "sqlite", SessionSecret: "change-me", SessionSecrets: pair}
+		assert.NoError(t, cfg.Validate())
+	})
+}
@@ -19,9 +19,7 @@ func Connect(cfg *config.Config) (*gorm.DB, error) {
 	case "sqlite":
 		db, err = gorm.Open(sqlite.Open(cfg.DBPath), &gorm.Config{})
 	case "postgres":
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-			cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort, cfg.DBSSLMode)
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(cfg.PostgresDSN()), &gorm.Config{})
 	default:
 		return nil, fmt.Errorf("unsupported database type: %s", cfg.DBType)
 	}
@@ -0,0 +1 @@
+ALTER TABLE babies DROP COLUMN archived_at;
@@ -0,0 +1,2 @@
+-- Add archived_at column to babies table
+ALTER TABLE babies ADD COLUMN archived_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Drop baby members table and related indexes
+DROP INDEX IF EXISTS idx_baby_members_user_id;
+DROP TABLE IF EXISTS baby_members;
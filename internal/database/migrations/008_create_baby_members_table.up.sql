@@ -0,0 +1,18 @@
+-- Create baby members table linking users to the babies they can access
+CREATE TABLE IF NOT EXISTS baby_members (
+    baby_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (baby_id, user_id),
+    FOREIGN KEY (baby_id) REFERENCES babies(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create index on user_id for faster lookups
+CREATE INDEX IF NOT EXISTS idx_baby_members_user_id ON baby_members(user_id);
+
+-- Existing babies are owned by the user that created them
+INSERT INTO baby_members (baby_id, user_id, role, created_at, updated_at)
+SELECT id, user_id, 'owner', created_at, created_at FROM babies;
@@ -0,0 +1,4 @@
+-- Remove created_by_id and updated_by_id columns from activities table
+DROP INDEX IF EXISTS idx_activities_created_by_id;
+ALTER TABLE activities DROP COLUMN updated_by_id;
+ALTER TABLE activities DROP COLUMN created_by_id;
@@ -0,0 +1,5 @@
+-- Add created_by_id and updated_by_id columns to activities table
+ALTER TABLE activities ADD COLUMN created_by_id VARCHAR(36) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE activities ADD COLUMN updated_by_id VARCHAR(36) REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_activities_created_by_id ON activities(created_by_id);
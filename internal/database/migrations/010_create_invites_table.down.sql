@@ -0,0 +1,3 @@
+-- Drop invites table
+DROP INDEX IF EXISTS idx_invites_baby_id;
+DROP TABLE IF EXISTS invites;
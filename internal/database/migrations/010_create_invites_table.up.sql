@@ -0,0 +1,18 @@
+-- Create invites table for single-use caregiver invitation links
+CREATE TABLE IF NOT EXISTS invites (
+    id VARCHAR(36) PRIMARY KEY,
+    baby_id VARCHAR(36) NOT NULL,
+    created_by_id VARCHAR(36) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    used_by_id VARCHAR(36),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (baby_id) REFERENCES babies(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (used_by_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Create index on baby_id for listing a baby's invites
+CREATE INDEX IF NOT EXISTS idx_invites_baby_id ON invites(baby_id);
@@ -0,0 +1,5 @@
+-- Drop share_access_logs and share_links tables
+DROP INDEX IF EXISTS idx_share_access_logs_share_link_id;
+DROP TABLE IF EXISTS share_access_logs;
+DROP INDEX IF EXISTS idx_share_links_baby_id;
+DROP TABLE IF EXISTS share_links;
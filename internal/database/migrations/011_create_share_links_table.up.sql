@@ -0,0 +1,29 @@
+-- Create share_links table for read-only links given to pediatricians
+CREATE TABLE IF NOT EXISTS share_links (
+    id VARCHAR(36) PRIMARY KEY,
+    baby_id VARCHAR(36) NOT NULL,
+    created_by_id VARCHAR(36) NOT NULL,
+    label VARCHAR(100),
+    start_date TIMESTAMP NOT NULL,
+    end_date TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (baby_id) REFERENCES babies(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_baby_id ON share_links(baby_id);
+
+-- Create share_access_logs table recording every request made through a share link
+CREATE TABLE IF NOT EXISTS share_access_logs (
+    id VARCHAR(36) PRIMARY KEY,
+    share_link_id VARCHAR(36) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    path VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (share_link_id) REFERENCES share_links(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_access_logs_share_link_id ON share_access_logs(share_link_id);
@@ -0,0 +1,3 @@
+-- Drop api_tokens table
+DROP INDEX IF EXISTS idx_api_tokens_user_id;
+DROP TABLE IF EXISTS api_tokens;
@@ -0,0 +1,17 @@
+-- Create api_tokens table for personal access tokens
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    scope VARCHAR(10) NOT NULL,
+    baby_id VARCHAR(36),
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (baby_id) REFERENCES babies(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
@@ -0,0 +1,6 @@
+-- Drop login_attempts table
+DROP INDEX IF EXISTS idx_login_attempts_created_at;
+DROP INDEX IF EXISTS idx_login_attempts_ip_address;
+DROP INDEX IF EXISTS idx_login_attempts_username;
+DROP INDEX IF EXISTS idx_login_attempts_user_id;
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,4 @@
+-- Remove OpenID Connect identities
+DROP INDEX IF EXISTS idx_oidc_identities_user_id;
+DROP INDEX IF EXISTS idx_oidc_identities_issuer_subject;
+DROP TABLE IF EXISTS oidc_identities;
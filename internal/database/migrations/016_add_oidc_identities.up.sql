@@ -0,0 +1,13 @@
+-- Create oidc_identities table linking OpenID Connect accounts to users
+CREATE TABLE IF NOT EXISTS oidc_identities (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oidc_identities_issuer_subject ON oidc_identities(issuer, subject);
+CREATE INDEX IF NOT EXISTS idx_oidc_identities_user_id ON oidc_identities(user_id);
@@ -0,0 +1,5 @@
+-- Remove the activity trash, purging anything still in it
+DELETE FROM activities WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_activities_deleted_at;
+ALTER TABLE activities DROP COLUMN deleted_at;
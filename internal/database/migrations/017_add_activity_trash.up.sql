@@ -0,0 +1,4 @@
+-- Soft delete activities so they can be restored from the trash
+ALTER TABLE activities ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_activities_deleted_at ON activities(deleted_at);
@@ -0,0 +1,6 @@
+-- Remove the activity revision log
+DROP INDEX IF EXISTS idx_activity_revisions_created_at;
+DROP INDEX IF EXISTS idx_activity_revisions_user_id;
+DROP INDEX IF EXISTS idx_activity_revisions_baby_id;
+DROP INDEX IF EXISTS idx_activity_revisions_activity_id;
+DROP TABLE IF EXISTS activity_revisions;
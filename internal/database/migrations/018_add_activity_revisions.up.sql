@@ -0,0 +1,18 @@
+-- Create activity_revisions table, an append-only log of changes to activities
+CREATE TABLE IF NOT EXISTS activity_revisions (
+    id VARCHAR(36) PRIMARY KEY,
+    activity_id VARCHAR(36) NOT NULL,
+    baby_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36),
+    action VARCHAR(20) NOT NULL,
+    before_state TEXT,
+    after_state TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (baby_id) REFERENCES babies(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_revisions_activity_id ON activity_revisions(activity_id);
+CREATE INDEX IF NOT EXISTS idx_activity_revisions_baby_id ON activity_revisions(baby_id);
+CREATE INDEX IF NOT EXISTS idx_activity_revisions_user_id ON activity_revisions(user_id);
+CREATE INDEX IF NOT EXISTS idx_activity_revisions_created_at ON activity_revisions(created_at);
@@ -0,0 +1,3 @@
+-- Remove record versions
+ALTER TABLE babies DROP COLUMN version;
+ALTER TABLE activities DROP COLUMN version;
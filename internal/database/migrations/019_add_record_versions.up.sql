@@ -0,0 +1,3 @@
+-- Version activities and babies so concurrent edits can be detected
+ALTER TABLE activities ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE babies ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Drop idempotency_keys table
+DROP INDEX IF EXISTS idx_idempotency_keys_created_at;
+DROP INDEX IF EXISTS idx_idempotency_keys_user_key;
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,8 @@
+-- Drop webhook tables
+DROP INDEX IF EXISTS idx_webhook_deliveries_created_at;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP INDEX IF EXISTS idx_webhooks_baby_id;
+DROP INDEX IF EXISTS idx_webhooks_user_id;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,3 @@
+-- Drop notification_channels table
+DROP INDEX IF EXISTS idx_notification_channels_user_id;
+DROP TABLE IF EXISTS notification_channels;
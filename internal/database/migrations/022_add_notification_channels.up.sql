@@ -0,0 +1,14 @@
+-- Create notification_channels table for where users want notifications sent
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    settings TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_channels_user_id ON notification_channels(user_id);
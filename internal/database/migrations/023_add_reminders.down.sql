@@ -0,0 +1,5 @@
+-- Drop reminders table
+DROP INDEX IF EXISTS idx_reminders_next_run_at;
+DROP INDEX IF EXISTS idx_reminders_baby_id;
+DROP INDEX IF EXISTS idx_reminders_user_id;
+DROP TABLE IF EXISTS reminders;
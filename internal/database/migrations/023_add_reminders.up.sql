@@ -0,0 +1,26 @@
+-- Create reminders table for scheduled and activity-based reminders
+CREATE TABLE IF NOT EXISTS reminders (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    baby_id VARCHAR(36) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    notes TEXT,
+    schedule VARCHAR(100),
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    activity_type VARCHAR(20),
+    delay_minutes INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP,
+    snoozed_until TIMESTAMP,
+    last_sent_at TIMESTAMP,
+    sent_count INTEGER NOT NULL DEFAULT 0,
+    acknowledged_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (baby_id) REFERENCES babies(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_user_id ON reminders(user_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_baby_id ON reminders(baby_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_next_run_at ON reminders(next_run_at);
@@ -0,0 +1,2 @@
+-- Remove the marker for memberships granted by OpenID Connect
+ALTER TABLE baby_members DROP COLUMN managed_by_oidc;
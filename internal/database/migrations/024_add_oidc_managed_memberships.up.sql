@@ -0,0 +1,2 @@
+-- Mark the baby memberships granted by the OpenID Connect role mapping
+ALTER TABLE baby_members ADD COLUMN managed_by_oidc BOOLEAN NOT NULL DEFAULT FALSE;
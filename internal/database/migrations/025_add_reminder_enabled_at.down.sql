@@ -0,0 +1,2 @@
+-- Remove the time reminders were switched on
+ALTER TABLE reminders DROP COLUMN enabled_at;
@@ -0,0 +1,4 @@
+-- Track when each reminder was last switched on, so that reminders which
+-- follow an activity ignore the ones from before
+ALTER TABLE reminders ADD COLUMN enabled_at TIMESTAMP;
+UPDATE reminders SET enabled_at = created_at;
@@ -0,0 +1,2 @@
+-- Remove the ETag of stored responses
+ALTER TABLE idempotency_keys DROP COLUMN etag;
@@ -0,0 +1,2 @@
+-- Keep the ETag of stored responses, so that replayed updates return it
+ALTER TABLE idempotency_keys ADD COLUMN etag VARCHAR(100);
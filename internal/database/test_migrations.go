@@ -27,6 +27,15 @@ func RunTestMigrations(db *gorm.DB, cfg *config.Config) error {
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Baby{},
+		&models.BabyMember{},
+		&models.Invite{},
+		&models.ShareLink{},
+		&models.ShareAccessLog{},
+		&models.APIToken{},
+		&models.Session{},
+		&models.LoginAttempt{},
+		&models.RecoveryCode{},
+		&models.OIDCIdentity{},
 		&models.Activity{},
 		&models.FeedActivity{},
 		&models.PumpActivity{},
@@ -35,6 +44,12 @@ func RunTestMigrations(db *gorm.DB, cfg *config.Config) error {
 		&models.GrowthMeasurement{},
 		&models.HealthRecord{},
 		&models.Milestone{},
+		&models.ActivityRevision{},
+		&models.IdempotencyKey{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.NotificationChannel{},
+		&models.Reminder{},
 	)
 
 	if err != nil {
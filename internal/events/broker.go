@@ -0,0 +1,197 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	ActivityCreated  = "activity.created"
+	ActivityUpdated  = "activity.updated"
+	ActivityDeleted  = "activity.deleted"
+	ActivityRestored = "activity.restored"
+	TimerStarted     = "timer.started"
+	TimerStopped     = "timer.stopped"
+)
+
+const (
+	// historySize is how many recent events are kept per baby so that
+	// clients reconnecting with a Last-Event-ID can catch up
+	historySize = 256
+	// subscriberBuffer is how many events may wait for a subscriber before
+	// it is dropped as too slow
+	subscriberBuffer = 64
+)
+
+// Event is something that happened to one of a baby's records
+type Event struct {
+	ID     string          `json:"id"`
+	BabyID uuid.UUID       `json:"baby_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Broker passes events to the clients subscribed to each baby. It keeps the
+// most recent events per baby so that clients can resume after a dropped
+// connection.
+type Broker struct {
+	mu     sync.Mutex
+	babies map[uuid.UUID]*babyEvents
+	// all holds the subscriptions to every baby's events
+	all map[*Subscription]struct{}
+
+	// forward, if set, passes events published here to other instances
+	forward func(Event) error
+}
+
+type babyEvents struct {
+	history     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events for one baby, or for every baby. Events is closed when the
+// subscription is closed, or if the subscriber falls too far behind, in
+// which case it should resubscribe from the last event it saw.
+type Subscription struct {
+	Events <-chan Event
+
+	events chan Event
+	broker *Broker
+	// babyID is uuid.Nil for subscriptions to every baby
+	babyID uuid.UUID
+}
+
+// NewBroker creates a Broker
+func NewBroker() *Broker {
+	return &Broker{
+		babies: make(map[uuid.UUID]*babyEvents),
+		all:    make(map[*Subscription]struct{}),
+	}
+}
+
+// New creates an event for a baby with data encoded as JSON
+func New(babyID uuid.UUID, eventType string, data interface{}) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	// Version 7 UUIDs are unique across instances
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{ID: id.String(), BabyID: babyID, Type: eventType, Data: encoded}, nil
+}
+
+// Publish sends an event to the baby's subscribers, here and on any other
+// instances
+func (b *Broker) Publish(event Event) error {
+	b.deliver(event)
+
+	if b.forward != nil {
+		return b.forward(event)
+	}
+	return nil
+}
+
+// Subscribe starts receiving events for a baby. If lastEventID is set, the
+// events published since it are returned to be sent first. If it is too old
+// to be remembered, found is false and the client should reload everything.
+func (b *Broker) Subscribe(babyID uuid.UUID, lastEventID string) (sub *Subscription, missed []Event, found bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	baby := b.babies[babyID]
+	if baby == nil {
+		baby = &babyEvents{subscribers: make(map[*Subscription]struct{})}
+		b.babies[babyID] = baby
+	}
+
+	found = lastEventID == ""
+	if !found {
+		for i := len(baby.history) - 1; i >= 0; i-- {
+			if baby.history[i].ID == lastEventID {
+				missed = append(missed, baby.history[i+1:]...)
+				found = true
+				break
+			}
+		}
+	}
+
+	events := make(chan Event, subscriberBuffer)
+	sub = &Subscription{Events: events, events: events, broker: b, babyID: babyID}
+	baby.subscribers[sub] = struct{}{}
+
+	return sub, missed, found
+}
+
+// SubscribeAll starts receiving the events for every baby, for services
+// that act on all changes rather than showing one baby's
+func (b *Broker) SubscribeAll() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: events, events: events, broker: b}
+	b.all[sub] = struct{}{}
+
+	return sub
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// deliver records an event and passes it to the baby's subscribers
+func (b *Broker) deliver(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	baby := b.babies[event.BabyID]
+	if baby == nil {
+		baby = &babyEvents{subscribers: make(map[*Subscription]struct{})}
+		b.babies[event.BabyID] = baby
+	}
+
+	baby.history = append(baby.history, event)
+	if len(baby.history) > historySize {
+		baby.history = append([]Event(nil), baby.history[len(baby.history)-historySize:]...)
+	}
+
+	for _, subscribers := range []map[*Subscription]struct{}{baby.subscribers, b.all} {
+		for sub := range subscribers {
+			select {
+			case sub.events <- event:
+			default:
+				// Don't hold everyone up for a slow client; it can resume
+				// from the last event it received
+				b.remove(sub)
+			}
+		}
+	}
+}
+
+// remove drops a subscription. b.mu must be held.
+func (b *Broker) remove(sub *Subscription) {
+	subscribers := b.all
+	if sub.babyID != uuid.Nil {
+		baby := b.babies[sub.babyID]
+		if baby == nil {
+			return
+		}
+		subscribers = baby.subscribers
+	}
+	if _, ok := subscribers[sub]; !ok {
+		return
+	}
+	delete(subscribers, sub)
+	close(sub.events)
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func publish(t *testing.T, broker *Broker, babyID uuid.UUID, eventType string, data interface{}) {
+	t.Helper()
+	event, err := New(babyID, eventType, data)
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(event))
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	babyID := uuid.New()
+	otherBabyID := uuid.New()
+
+	sub, missed, found := broker.Subscribe(babyID, "")
+	defer sub.Close()
+	assert.True(t, found)
+	assert.Empty(t, missed)
+
+	publish(t, broker, babyID, ActivityCreated, map[string]string{"id": "1"})
+	publish(t, broker, otherBabyID, ActivityCreated, map[string]string{"id": "2"})
+	publish(t, broker, babyID, TimerStarted, map[string]string{"id": "3"})
+
+	first := <-sub.Events
+	assert.Equal(t, ActivityCreated, first.Type)
+	assert.Equal(t, babyID, first.BabyID)
+	assert.JSONEq(t, `{"id": "1"}`, string(first.Data))
+
+	// Only the baby's own events are received
+	second := <-sub.Events
+	assert.Equal(t, TimerStarted, second.Type)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	t.Run("resuming returns the missed events", func(t *testing.T) {
+		resumed, missed, found := broker.Subscribe(babyID, first.ID)
+		defer resumed.Close()
+		assert.True(t, found)
+		require.Len(t, missed, 1)
+		assert.Equal(t, second.ID, missed[0].ID)
+
+		_, missed, found = broker.Subscribe(babyID, second.ID)
+		assert.True(t, found)
+		assert.Empty(t, missed)
+	})
+
+	t.Run("unknown event IDs can't be resumed from", func(t *testing.T) {
+		_, missed, found := broker.Subscribe(babyID, "forgotten")
+		assert.False(t, found)
+		assert.Empty(t, missed)
+	})
+
+	t.Run("old events are forgotten", func(t *testing.T) {
+		for i := 0; i < historySize; i++ {
+			publish(t, broker, otherBabyID, ActivityUpdated, i)
+		}
+		_, _, found := broker.Subscribe(otherBabyID, first.ID)
+		assert.False(t, found)
+	})
+
+	t.Run("closing ends the subscription", func(t *testing.T) {
+		closing, _, _ := broker.Subscribe(babyID, "")
+		closing.Close()
+		_, ok := <-closing.Events
+		assert.False(t, ok)
+
+		// Closing twice is harmless
+		closing.Close()
+	})
+
+	t.Run("slow subscribers are dropped", func(t *testing.T) {
+		slow, _, _ := broker.Subscribe(babyID, "")
+		for i := 0; i <= subscriberBuffer; i++ {
+			publish(t, broker, babyID, ActivityUpdated, i)
+		}
+
+		received := 0
+		for range slow.Events {
+			received++
+		}
+		assert.Equal(t, subscriberBuffer, received)
+	})
+
+	t.Run("subscribing to every baby", func(t *testing.T) {
+		all := broker.SubscribeAll()
+		publish(t, broker, babyID, ActivityDeleted, nil)
+		publish(t, broker, otherBabyID, ActivityDeleted, nil)
+
+		assert.Equal(t, babyID, (<-all.Events).BabyID)
+		assert.Equal(t, otherBabyID, (<-all.Events).BabyID)
+
+		all.Close()
+		_, ok := <-all.Events
+		assert.False(t, ok)
+	})
+}
+
+func TestPostgresNotifications(t *testing.T) {
+	broker := NewBroker()
+	babyID := uuid.New()
+	sub, _, _ := broker.Subscribe(babyID, "")
+	defer sub.Close()
+
+	event := Event{ID: uuid.NewString(), BabyID: babyID, Type: ActivityDeleted, Data: json.RawMessage(`{"id":"1"}`)}
+
+	t.Run("events from other instances are delivered", func(t *testing.T) {
+		payload, err := encodeNotification("other", event)
+		require.NoError(t, err)
+
+		broker.handleNotification("this", payload)
+		received := <-sub.Events
+		assert.Equal(t, event, received)
+
+		// And can be resumed from
+		_, _, found := broker.Subscribe(babyID, event.ID)
+		assert.True(t, found)
+	})
+
+	t.Run("an instance's own events are ignored", func(t *testing.T) {
+		payload, err := encodeNotification("this", event)
+		require.NoError(t, err)
+
+		broker.handleNotification("this", payload)
+		broker.handleNotification("this", "not json")
+		assert.Empty(t, sub.Events)
+	})
+
+	t.Run("large events are sent without their data", func(t *testing.T) {
+		large := event
+		large.Data, _ = json.Marshal(strings.Repeat("x", maxNotifyPayload))
+
+		payload, err := encodeNotification("other", large)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(payload), maxNotifyPayload)
+
+		broker.handleNotification("this", payload)
+		received := <-sub.Events
+		assert.Equal(t, event.ID, received.ID)
+		assert.Equal(t, "null", string(received.Data))
+	})
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// postgresChannel is the channel events are sent between instances on
+	postgresChannel = "bambino_events"
+	// maxNotifyPayload is the largest payload NOTIFY accepts, less a little
+	// for the envelope
+	maxNotifyPayload = 7900
+)
+
+// notification is an event sent through Postgres. Origin identifies the
+// instance that sent it, which ignores its own.
+type notification struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+// UsePostgres shares events with other instances using the same database
+// through LISTEN/NOTIFY. Events published here are sent with NOTIFY through
+// db, and a separate connection made with dsn listens for the events of
+// other instances until ctx is done.
+func (b *Broker) UsePostgres(ctx context.Context, db *gorm.DB, dsn string) {
+	origin := uuid.NewString()
+
+	b.forward = func(event Event) error {
+		payload, err := encodeNotification(origin, event)
+		if err != nil {
+			return err
+		}
+		return db.Exec("SELECT pg_notify(?, ?)", postgresChannel, payload).Error
+	}
+
+	go b.listenPostgres(ctx, dsn, origin)
+}
+
+// listenPostgres delivers events from other instances, reconnecting with a
+// growing delay if the connection is lost
+func (b *Broker) listenPostgres(ctx context.Context, dsn, origin string) {
+	backoff := time.Second
+	for {
+		err := b.receiveNotifications(ctx, dsn, origin, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Lost Postgres event listener, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// receiveNotifications listens on one connection until it fails, calling
+// connected once it is listening
+func (b *Broker) receiveNotifications(ctx context.Context, dsn, origin string, connected func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{postgresChannel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		b.handleNotification(origin, n.Payload)
+	}
+}
+
+// handleNotification delivers an event received from another instance
+func (b *Broker) handleNotification(origin, payload string) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		log.Printf("Ignoring malformed event notification: %v", err)
+		return
+	}
+	if n.Origin == origin {
+		return
+	}
+	b.deliver(n.Event)
+}
+
+// encodeNotification encodes an event for NOTIFY. Its data is left out if
+// it is too large to send, and clients will need to reload it.
+func encodeNotification(origin string, event Event) (string, error) {
+	payload, err := json.Marshal(notification{Origin: origin, Event: event})
+	if err != nil {
+		return "", err
+	}
+	if len(payload) > maxNotifyPayload {
+		event.Data = json.RawMessage("null")
+		if payload, err = json.Marshal(notification{Origin: origin, Event: event}); err != nil {
+			return "", err
+		}
+	}
+	return string(payload), nil
+}
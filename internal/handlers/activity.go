@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -11,6 +16,8 @@ import (
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/events"
 	"github.com/engineervix/bambino/internal/models"
 	"github.com/engineervix/bambino/internal/utils"
 )
@@ -61,6 +68,9 @@ type MilestoneData struct {
 
 // ActivityRequest represents the request body for creating/updating activities
 type ActivityRequest struct {
+	// ID can be chosen by the client, so that sending an activity logged
+	// offline again doesn't create a duplicate
+	ID        string     `json:"id,omitempty" validate:"omitempty,uuid"`
 	BabyID    string     `json:"baby_id,omitempty"`
 	Type      string     `json:"type" validate:"required,oneof=feed pump diaper sleep growth health milestone"`
 	StartTime time.Time  `json:"start_time" validate:"required"`
@@ -101,8 +111,15 @@ type ActivityResponse struct {
 	StartTime time.Time  `json:"start_time"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 	Notes     string     `json:"notes,omitempty"`
+	Version   int        `json:"version"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
+	// DeletedAt is only set for activities in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Caregivers who logged and last edited the activity
+	CreatedBy *ActivityAuthor `json:"created_by,omitempty"`
+	UpdatedBy *ActivityAuthor `json:"updated_by,omitempty"`
 
 	// Activity-specific data
 	FeedData      *FeedData      `json:"feed_data,omitempty"`
@@ -114,6 +131,12 @@ type ActivityResponse struct {
 	MilestoneData *MilestoneData `json:"milestone_data,omitempty"`
 }
 
+// ActivityAuthor identifies the user who logged or edited an activity
+type ActivityAuthor struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
 // ActivityListResponse represents the paginated response for activities
 type ActivityListResponse struct {
 	Activities []ActivityResponse `json:"activities"`
@@ -123,8 +146,24 @@ type ActivityListResponse struct {
 	TotalPages int                `json:"total_pages"`
 }
 
+// ActivityTrashResponse lists deleted activities that can still be restored
+type ActivityTrashResponse struct {
+	Activities []ActivityResponse `json:"activities"`
+	// RetentionDays is how long activities stay in the trash, or 0 if they
+	// are kept until restored
+	RetentionDays int `json:"retention_days"`
+}
+
 var validate = validator.New()
 
+// mergePatchContentType is the media type of JSON Merge Patch documents
+const mergePatchContentType = "application/merge-patch+json"
+
+var (
+	errInvalidBabyID    = errors.New("invalid baby ID")
+	errInsufficientRole = errors.New("insufficient role")
+)
+
 // GetActivities handles GET /api/activities
 func GetActivities(c echo.Context) error {
 	// Get user from context
@@ -139,11 +178,11 @@ func GetActivities(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
 	}
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleViewer)
 	if err != nil {
 		utils.CaptureError(c, err, "failed to get user's baby")
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+		return babyLookupError(err)
 	}
 
 	// Parse query parameters
@@ -160,6 +199,7 @@ func GetActivities(c echo.Context) error {
 	activityType := c.QueryParam("type")
 	startDate := c.QueryParam("start_date")
 	endDate := c.QueryParam("end_date")
+	createdBy := c.QueryParam("created_by")
 
 	// Build query
 	query := db.Where("baby_id = ?", baby.ID)
@@ -169,6 +209,16 @@ func GetActivities(c echo.Context) error {
 		query = query.Where("type = ?", activityType)
 	}
 
+	if createdBy != "" {
+		// Accept either a user ID or a username
+		if uid, err := uuid.Parse(createdBy); err == nil {
+			query = query.Where("created_by_id = ?", uid)
+		} else {
+			query = query.Where("created_by_id IN (?)",
+				db.Model(&models.User{}).Select("id").Where("username = ?", createdBy))
+		}
+	}
+
 	if startDate != "" {
 		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
 			query = query.Where("start_time >= ?", parsedDate)
@@ -199,6 +249,8 @@ func GetActivities(c echo.Context) error {
 		Preload("GrowthMeasurement").
 		Preload("HealthRecord").
 		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Order("start_time DESC").
 		Offset(offset).
 		Limit(pageSize).
@@ -249,24 +301,20 @@ func CreateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Get user's baby
-	var baby *models.Baby
-	var err error
-
 	// A baby ID can be optionally provided to associate activity with a specific baby.
 	// If not provided, the most recently created baby for the user is used.
-	if req.BabyID != "" {
-		baby, err = getBabyByIDForUser(db, req.BabyID, userID)
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return echo.NewHTTPError(http.StatusNotFound, "baby not found or does not belong to user")
-			}
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
-		}
-	} else {
-		baby, err = getUserBaby(db, userID)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+	baby, err := resolveBaby(c, db, userID, req.BabyID, models.BabyRoleCaregiver)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	// An activity that already exists with the client's ID has been sent
+	// before, so it is returned as it is
+	var activityID uuid.UUID
+	if req.ID != "" {
+		activityID = uuid.MustParse(req.ID)
+		if found, err := respondWithExistingActivity(c, db, baby.ID, activityID); found {
+			return err
 		}
 	}
 
@@ -279,16 +327,26 @@ func CreateActivity(c echo.Context) error {
 	}()
 
 	// Create base activity
+	author := parseAuthorID(userID)
 	activity := models.Activity{
-		BabyID:    baby.ID,
-		Type:      models.ActivityType(req.Type),
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		Notes:     req.Notes,
+		ID:          activityID,
+		BabyID:      baby.ID,
+		Type:        models.ActivityType(req.Type),
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Notes:       req.Notes,
+		CreatedByID: author,
+		UpdatedByID: author,
 	}
 
 	if err := tx.Create(&activity).Error; err != nil {
 		tx.Rollback()
+		// A retry sent at the same time may have created it first
+		if req.ID != "" {
+			if found, err := respondWithExistingActivity(c, db, baby.ID, activityID); found {
+				return err
+			}
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create activity")
 	}
 
@@ -298,6 +356,11 @@ func CreateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if err := recordActivityChange(tx, models.RevisionActionCreate, baby.ID, activity.ID, userID, nil); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save activity")
@@ -311,13 +374,40 @@ func CreateActivity(c echo.Context) error {
 		Preload("GrowthMeasurement").
 		Preload("HealthRecord").
 		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		First(&activity, activity.ID).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load activity details")
 	}
 
 	// Return created activity
 	response := convertActivityToResponse(activity)
-	return c.JSON(http.StatusCreated, response)
+	publishEvent(c, baby.ID, events.ActivityCreated, &response)
+	return jsonWithETag(c, http.StatusCreated, response.Version, response)
+}
+
+// respondWithExistingActivity responds with the activity a client's ID
+// belongs to, reporting whether it was found. An ID used by another baby's
+// activity is refused like any other invalid ID, so that it doesn't give
+// away that the activity exists.
+func respondWithExistingActivity(c echo.Context, db *gorm.DB, babyID, id uuid.UUID) (bool, error) {
+	var existing models.Activity
+	err := db.Unscoped().Where("id = ?", id).First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return true, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+	if existing.BabyID != babyID {
+		return true, echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
+	}
+
+	current, err := loadActivitySnapshot(db, id)
+	if err != nil {
+		return true, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+	return true, jsonWithETag(c, http.StatusOK, current.Version, current)
 }
 
 // GetActivity handles GET /api/activities/:id
@@ -346,10 +436,10 @@ func GetActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
 	}
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleViewer)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+		return activityLookupError(err)
 	}
 
 	// Find activity with related data
@@ -362,6 +452,8 @@ func GetActivity(c echo.Context) error {
 		Preload("GrowthMeasurement").
 		Preload("HealthRecord").
 		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Where("id = ? AND baby_id = ?", id, baby.ID).
 		First(&activity).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -372,7 +464,7 @@ func GetActivity(c echo.Context) error {
 
 	// Return activity
 	response := convertActivityToResponse(activity)
-	return c.JSON(http.StatusOK, response)
+	return jsonWithETag(c, http.StatusOK, response.Version, response)
 }
 
 // UpdateActivity handles PUT /api/activities/:id
@@ -412,10 +504,10 @@ func UpdateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleCaregiver)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+		return activityLookupError(err)
 	}
 
 	// Start transaction
@@ -437,11 +529,34 @@ func UpdateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
 	}
 
+	// Don't overwrite changes the client hasn't seen
+	if !ifMatch(c, activity.Version) {
+		tx.Rollback()
+		return activityPreconditionFailed(c, db, activity.ID)
+	}
+
+	// Keep what the activity looked like for its history
+	before, err := loadActivitySnapshot(tx, activity.ID)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	if err := claimNextVersion(tx, &models.Activity{}, activity.ID, activity.Version); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errVersionConflict) {
+			return activityPreconditionFailed(c, db, activity.ID)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update activity")
+	}
+
 	// Update activity
 	activity.Type = models.ActivityType(req.Type)
 	activity.StartTime = req.StartTime
 	activity.EndTime = req.EndTime
 	activity.Notes = req.Notes
+	activity.UpdatedByID = parseAuthorID(userID)
+	activity.Version++
 
 	if err := tx.Save(&activity).Error; err != nil {
 		tx.Rollback()
@@ -460,6 +575,11 @@ func UpdateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if err := recordActivityChange(tx, models.RevisionActionUpdate, baby.ID, activity.ID, userID, before); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save activity")
@@ -473,13 +593,156 @@ func UpdateActivity(c echo.Context) error {
 		Preload("GrowthMeasurement").
 		Preload("HealthRecord").
 		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		First(&activity, activity.ID).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load activity details")
 	}
 
 	// Return updated activity
 	response := convertActivityToResponse(activity)
-	return c.JSON(http.StatusOK, response)
+	publishEvent(c, baby.ID, events.ActivityUpdated, &response)
+	return jsonWithETag(c, http.StatusOK, response.Version, response)
+}
+
+// PatchActivity handles PATCH /api/activities/:id. The body is a JSON Merge
+// Patch (RFC 7386), so only the fields it names change, and the patched
+// activity is validated like a full update.
+func PatchActivity(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	// Get activity ID from path
+	activityID := c.Param("id")
+	if activityID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "activity ID is required")
+	}
+
+	// Parse UUID
+	id, err := uuid.Parse(activityID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
+	}
+
+	// Read the patch
+	if contentType := c.Request().Header.Get(echo.HeaderContentType); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != echo.MIMEApplicationJSON) {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, "patch must be "+mergePatchContentType)
+		}
+	}
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleCaregiver)
+	if err != nil {
+		return activityLookupError(err)
+	}
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Find activity
+	var activity models.Activity
+	if err := tx.Where("id = ? AND baby_id = ?", id, baby.ID).First(&activity).Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	// Don't overwrite changes the client hasn't seen
+	if !ifMatch(c, activity.Version) {
+		tx.Rollback()
+		return activityPreconditionFailed(c, db, activity.ID)
+	}
+
+	before, err := loadActivitySnapshot(tx, activity.ID)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	// Apply the patch and check the result
+	req, detailsChanged, err := mergeActivityPatch(before, patch)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := validateActivityRequest(req); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := claimNextVersion(tx, &models.Activity{}, activity.ID, activity.Version); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errVersionConflict) {
+			return activityPreconditionFailed(c, db, activity.ID)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update activity")
+	}
+
+	// Update activity
+	typeChanged := activity.Type != models.ActivityType(req.Type)
+	activity.Type = models.ActivityType(req.Type)
+	activity.StartTime = req.StartTime
+	activity.EndTime = req.EndTime
+	activity.Notes = req.Notes
+	activity.UpdatedByID = parseAuthorID(userID)
+	activity.Version++
+
+	if err := tx.Save(&activity).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update activity")
+	}
+
+	// Rewrite the activity-specific record only if the patch touched it
+	if typeChanged || detailsChanged {
+		if err := deleteActivitySpecificRecord(tx, activity.ID); err != nil {
+			tx.Rollback()
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update activity details")
+		}
+		if err := createActivitySpecificRecord(tx, &activity, req); err != nil {
+			tx.Rollback()
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	if err := recordActivityChange(tx, models.RevisionActionUpdate, baby.ID, activity.ID, userID, before); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save activity")
+	}
+
+	// Return updated activity
+	updated, err := loadActivitySnapshot(db, activity.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load activity details")
+	}
+	publishEvent(c, baby.ID, events.ActivityUpdated, updated)
+	return jsonWithETag(c, http.StatusOK, updated.Version, updated)
 }
 
 // DeleteActivity handles DELETE /api/activities/:id
@@ -508,20 +771,70 @@ func DeleteActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
 	}
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleOwner)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+		return activityLookupError(err)
 	}
 
-	// Find and delete activity
-	result := db.Where("id = ? AND baby_id = ?", id, baby.ID).Delete(&models.Activity{})
-	if result.Error != nil {
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Find activity
+	var activity models.Activity
+	if err := tx.Where("id = ? AND baby_id = ?", id, baby.ID).First(&activity).Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	// Don't delete changes the client hasn't seen
+	if !ifMatch(c, activity.Version) {
+		tx.Rollback()
+		return activityPreconditionFailed(c, db, activity.ID)
+	}
+
+	before, err := loadActivitySnapshot(tx, activity.ID)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	if err := claimNextVersion(tx, &models.Activity{}, activity.ID, activity.Version); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errVersionConflict) {
+			return activityPreconditionFailed(c, db, activity.ID)
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete activity")
 	}
 
-	if result.RowsAffected == 0 {
-		return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+	// Move the activity to the trash, from where it can be restored
+	if err := tx.Delete(&activity).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete activity")
+	}
+
+	if err := recordActivityRevision(tx, models.RevisionActionDelete, baby.ID, activity.ID, userID, before, nil); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete activity")
+	}
+
+	if deleted, err := loadActivitySnapshot(db, activity.ID); err == nil {
+		publishEvent(c, baby.ID, events.ActivityDeleted, deleted)
+	} else {
+		c.Logger().Errorf("failed to load deleted activity: %v", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -529,6 +842,146 @@ func DeleteActivity(c echo.Context) error {
 	})
 }
 
+// GetActivityTrash handles GET /api/activities/trash
+func GetActivityTrash(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	// Only owners can delete activities, so only they see the trash
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	var activities []models.Activity
+	if err := db.Unscoped().
+		Preload("FeedActivity").
+		Preload("PumpActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("GrowthMeasurement").
+		Preload("HealthRecord").
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		Where("baby_id = ? AND deleted_at IS NOT NULL", baby.ID).
+		Order("deleted_at DESC").
+		Find(&activities).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch trash")
+	}
+
+	response := ActivityTrashResponse{
+		Activities: make([]ActivityResponse, len(activities)),
+	}
+	for i, activity := range activities {
+		response.Activities[i] = convertActivityToResponse(activity)
+	}
+	if cfg, ok := c.Get("config").(*config.Config); ok {
+		response.RetentionDays = cfg.ActivityTrashRetentionDays
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// RestoreActivity handles POST /api/activities/:id/restore, taking an
+// activity back out of the trash
+func RestoreActivity(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	// Parse UUID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
+	}
+
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleOwner)
+	if err != nil {
+		return activityLookupError(err)
+	}
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var activity models.Activity
+	if err := tx.Unscoped().
+		Where("id = ? AND baby_id = ? AND deleted_at IS NOT NULL", id, baby.ID).
+		First(&activity).Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "activity not found in trash")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	before, err := loadActivitySnapshot(tx, activity.ID)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	if err := tx.Unscoped().Model(&activity).UpdateColumns(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to restore activity")
+	}
+
+	if err := recordActivityChange(tx, models.RevisionActionRestore, baby.ID, activity.ID, userID, before); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to restore activity")
+	}
+
+	// Return the restored activity
+	if err := db.Preload("FeedActivity").
+		Preload("PumpActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("GrowthMeasurement").
+		Preload("HealthRecord").
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		First(&activity, "id = ?", id).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch restored activity")
+	}
+
+	response := convertActivityToResponse(activity)
+	publishEvent(c, baby.ID, events.ActivityRestored, &response)
+	return jsonWithETag(c, http.StatusOK, response.Version, response)
+}
+
 // StartActivityTimer handles POST /api/activities/timer/start
 func StartActivityTimer(c echo.Context) error {
 	// Get user from context
@@ -554,23 +1007,10 @@ func StartActivityTimer(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Get user's baby
-	var baby *models.Baby
-	var err error
-	if req.BabyID != "" {
-		baby, err = getBabyByIDForUser(db, req.BabyID, userID)
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return echo.NewHTTPError(http.StatusNotFound, "baby not found")
-			}
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
-		}
-	} else {
-		// Fallback to the latest baby if no ID is provided
-		baby, err = getUserBaby(db, userID)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
-		}
+	// Get the baby the timer is for, falling back to the latest baby if no ID is provided
+	baby, err := resolveBaby(c, db, userID, req.BabyID, models.BabyRoleCaregiver)
+	if err != nil {
+		return babyLookupError(err)
 	}
 
 	// Start transaction
@@ -582,11 +1022,14 @@ func StartActivityTimer(c echo.Context) error {
 	}()
 
 	// Create base activity with current time as start time
+	author := parseAuthorID(userID)
 	activity := models.Activity{
-		BabyID:    baby.ID,
-		Type:      models.ActivityType(req.Type),
-		StartTime: time.Now(),
-		Notes:     req.Notes,
+		BabyID:      baby.ID,
+		Type:        models.ActivityType(req.Type),
+		StartTime:   time.Now(),
+		Notes:       req.Notes,
+		CreatedByID: author,
+		UpdatedByID: author,
 	}
 
 	if err := tx.Create(&activity).Error; err != nil {
@@ -631,6 +1074,11 @@ func StartActivityTimer(c echo.Context) error {
 		}
 	}
 
+	if err := recordActivityChange(tx, models.RevisionActionCreate, baby.ID, activity.ID, userID, nil); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save activity")
@@ -642,11 +1090,18 @@ func StartActivityTimer(c echo.Context) error {
 		Type:      string(activity.Type),
 		StartTime: activity.StartTime,
 		Notes:     activity.Notes,
+		Version:   activity.Version,
 		CreatedAt: activity.CreatedAt,
 		UpdatedAt: activity.UpdatedAt,
 	}
+	if author != nil {
+		username, _ := c.Get("username").(string)
+		response.CreatedBy = &ActivityAuthor{ID: author.String(), Username: username}
+		response.UpdatedBy = response.CreatedBy
+	}
 
-	return c.JSON(http.StatusCreated, response)
+	publishEvent(c, baby.ID, events.TimerStarted, &response)
+	return jsonWithETag(c, http.StatusCreated, response.Version, response)
 }
 
 // StopActivityTimer handles PUT /api/activities/timer/:id/stop
@@ -686,10 +1141,10 @@ func StopActivityTimer(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleCaregiver)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+		return activityLookupError(err)
 	}
 
 	// Start transaction
@@ -716,12 +1171,29 @@ func StopActivityTimer(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
 	}
 
+	// Keep the running timer's state for its history
+	before, err := loadActivitySnapshot(tx, activity.ID)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+
+	if err := claimNextVersion(tx, &models.Activity{}, activity.ID, activity.Version); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errVersionConflict) {
+			return echo.NewHTTPError(http.StatusConflict, "timer was changed by another request")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update activity")
+	}
+
 	// Update activity end time
 	endTime := time.Now()
 	activity.EndTime = &endTime
 	if req.Notes != "" {
 		activity.Notes = req.Notes
 	}
+	activity.UpdatedByID = parseAuthorID(userID)
+	activity.Version++
 
 	if err := tx.Save(&activity).Error; err != nil {
 		tx.Rollback()
@@ -765,6 +1237,11 @@ func StopActivityTimer(c echo.Context) error {
 		}
 	}
 
+	if err := recordActivityChange(tx, models.RevisionActionTimerStop, baby.ID, activity.ID, userID, before); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record activity history")
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save activity")
@@ -774,18 +1251,21 @@ func StopActivityTimer(c echo.Context) error {
 	if err := db.Preload("FeedActivity").
 		Preload("PumpActivity").
 		Preload("SleepActivity").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		First(&activity, activity.ID).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load activity details")
 	}
 
 	// Return updated activity
 	response := convertActivityToResponse(activity)
-	return c.JSON(http.StatusOK, response)
+	publishEvent(c, baby.ID, events.TimerStopped, &response)
+	return jsonWithETag(c, http.StatusOK, response.Version, response)
 }
 
 // Helper functions
 
-// getUserBaby gets the user's most recently created baby.
+// getUserBaby gets the most recently created baby the user is a member of that is not archived.
 // This is used as a fallback when a specific baby ID is not provided.
 func getUserBaby(db *gorm.DB, userID string) (*models.Baby, error) {
 	uid, err := uuid.Parse(userID)
@@ -794,18 +1274,21 @@ func getUserBaby(db *gorm.DB, userID string) (*models.Baby, error) {
 	}
 
 	var baby models.Baby
-	if err := db.Where("user_id = ?", uid).Order("created_at DESC").First(&baby).Error; err != nil {
+	if err := db.Joins("JOIN baby_members ON baby_members.baby_id = babies.id").
+		Where("baby_members.user_id = ? AND babies.archived_at IS NULL", uid).
+		Order("babies.created_at DESC").
+		First(&baby).Error; err != nil {
 		return nil, err
 	}
 
 	return &baby, nil
 }
 
-// getBabyByIDForUser retrieves a baby by its ID, ensuring it belongs to the specified user.
+// getBabyByIDForUser retrieves a baby by its ID, ensuring the specified user is a member of it.
 func getBabyByIDForUser(db *gorm.DB, babyIDStr string, userIDStr string) (*models.Baby, error) {
 	babyID, err := uuid.Parse(babyIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid baby ID format: %w", err)
+		return nil, fmt.Errorf("%w: %v", errInvalidBabyID, err)
 	}
 
 	userID, err := uuid.Parse(userIDStr)
@@ -814,12 +1297,159 @@ func getBabyByIDForUser(db *gorm.DB, babyIDStr string, userIDStr string) (*model
 	}
 
 	var baby models.Baby
-	if err := db.Where("id = ? AND user_id = ?", babyID, userID).First(&baby).Error; err != nil {
+	if err := db.Joins("JOIN baby_members ON baby_members.baby_id = babies.id").
+		Where("babies.id = ? AND baby_members.user_id = ?", babyID, userID).
+		First(&baby).Error; err != nil {
 		return nil, err
 	}
 	return &baby, nil
 }
 
+// getBabyRole returns the user's role on a baby
+func getBabyRole(db *gorm.DB, babyID uuid.UUID, userIDStr string) (models.BabyRole, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	var member models.BabyMember
+	if err := db.Where("baby_id = ? AND user_id = ?", babyID, userID).First(&member).Error; err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// resolveBaby returns the baby a request is scoped to, provided the user holds
+// at least minRole on it. An explicit baby ID is taken from the baby_id path
+// param (nested /api/babies/:baby_id/... routes), then bodyBabyID, then the
+// baby_id query param. Only when none of these is given does it fall back to
+// the user's most recently created baby. Requests authenticated with an API
+// token limited to a single baby are always scoped to that baby.
+func resolveBaby(c echo.Context, db *gorm.DB, userID string, bodyBabyID string, minRole models.BabyRole) (*models.Baby, error) {
+	babyID := c.Param("baby_id")
+	if babyID == "" {
+		babyID = bodyBabyID
+	}
+	if babyID == "" {
+		babyID = c.QueryParam("baby_id")
+	}
+
+	// API tokens limited to one baby cannot reach any other
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		if babyID == "" {
+			babyID = token.BabyID.String()
+		} else if id, err := uuid.Parse(babyID); err == nil && id != *token.BabyID {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+
+	var baby *models.Baby
+	var err error
+	if babyID != "" {
+		baby, err = getBabyByIDForUser(db, babyID, userID)
+	} else {
+		baby, err = getUserBaby(db, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := getBabyRole(db, baby.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !role.Includes(minRole) {
+		return nil, errInsufficientRole
+	}
+
+	return baby, nil
+}
+
+// resolveActivityBaby returns the baby an activity belongs to, checking
+// that the user has at least minRole on it. An activity ID names a single
+// baby, so unlike resolveBaby there is no fallback to the newest baby; a
+// baby ID sent with the request, or an API token's baby, must match it.
+func resolveActivityBaby(c echo.Context, db *gorm.DB, userID string, activityID uuid.UUID, minRole models.BabyRole) (*models.Baby, error) {
+	// Deleted activities are included for the trash, and purged ones are
+	// found through their history
+	var babyIDs []uuid.UUID
+	if err := db.Unscoped().Model(&models.Activity{}).
+		Where("id = ?", activityID).
+		Pluck("baby_id", &babyIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(babyIDs) == 0 {
+		if err := db.Model(&models.ActivityRevision{}).
+			Where("activity_id = ?", activityID).
+			Limit(1).
+			Pluck("baby_id", &babyIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(babyIDs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	babyID := babyIDs[0]
+
+	requested := c.Param("baby_id")
+	if requested == "" {
+		requested = c.QueryParam("baby_id")
+	}
+	if requested != "" {
+		id, err := uuid.Parse(requested)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidBabyID, err)
+		}
+		if id != babyID {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+
+	// API tokens limited to one baby cannot reach any other
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil && *token.BabyID != babyID {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	baby, err := getBabyByIDForUser(db, babyID.String(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := getBabyRole(db, baby.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !role.Includes(minRole) {
+		return nil, errInsufficientRole
+	}
+
+	return baby, nil
+}
+
+// activityLookupError converts an error from resolveActivityBaby into an
+// HTTP error. Activities of babies the user can't see are reported as
+// missing.
+func activityLookupError(err error) *echo.HTTPError {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+	}
+	return babyLookupError(err)
+}
+
+// babyLookupError converts an error from resolveBaby into an HTTP error
+func babyLookupError(err error) *echo.HTTPError {
+	switch {
+	case errors.Is(err, errInvalidBabyID):
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid baby ID")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "baby not found")
+	case errors.Is(err, errInsufficientRole):
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions for this baby")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get baby")
+	}
+}
+
 // validateActivityRequest validates the activity request
 func validateActivityRequest(req *ActivityRequest) error {
 	// Basic validation
@@ -990,6 +1620,89 @@ func deleteActivitySpecificRecord(tx *gorm.DB, activityID uuid.UUID) error {
 	return nil
 }
 
+// parseAuthorID converts the authenticated user ID into an activity author reference
+func parseAuthorID(userID string) *uuid.UUID {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil
+	}
+	return &uid
+}
+
+// convertAuthorToResponse converts a user to the author format used in activity responses
+func convertAuthorToResponse(user *models.User) *ActivityAuthor {
+	if user == nil {
+		return nil
+	}
+	return &ActivityAuthor{
+		ID:       user.ID.String(),
+		Username: user.Username,
+	}
+}
+
+// mergeActivityPatch applies a JSON Merge Patch to an activity, returning
+// the full request it amounts to and whether the patch changes the
+// activity-specific data
+func mergeActivityPatch(current *ActivityResponse, patch []byte) (*ActivityRequest, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return nil, false, fmt.Errorf("patch must be a JSON object")
+	}
+
+	document, err := json.Marshal(activityRequestFromResponse(current))
+	if err != nil {
+		return nil, false, err
+	}
+	merged, err := utils.MergePatch(document, patch)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid patch")
+	}
+
+	var req ActivityRequest
+	if err := json.Unmarshal(merged, &req); err != nil {
+		return nil, false, fmt.Errorf("invalid patch: %v", err)
+	}
+
+	detailsChanged := false
+	for field := range fields {
+		if strings.HasSuffix(field, "_data") {
+			detailsChanged = true
+		}
+	}
+	return &req, detailsChanged, nil
+}
+
+// activityRequestFromResponse turns an activity back into the request that
+// would create it
+func activityRequestFromResponse(activity *ActivityResponse) ActivityRequest {
+	return ActivityRequest{
+		Type:          activity.Type,
+		StartTime:     activity.StartTime,
+		EndTime:       activity.EndTime,
+		Notes:         activity.Notes,
+		FeedData:      activity.FeedData,
+		PumpData:      activity.PumpData,
+		DiaperData:    activity.DiaperData,
+		SleepData:     activity.SleepData,
+		GrowthData:    activity.GrowthData,
+		HealthData:    activity.HealthData,
+		MilestoneData: activity.MilestoneData,
+	}
+}
+
+// activityPreconditionFailed answers a request made against an outdated
+// version of an activity with its current representation
+func activityPreconditionFailed(c echo.Context, db *gorm.DB, id uuid.UUID) error {
+	current, err := loadActivitySnapshot(db, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activity")
+	}
+	if current.DeletedAt != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+	}
+	return jsonWithETag(c, http.StatusPreconditionFailed, current.Version, current)
+}
+
 // convertActivityToResponse converts a model to response format
 func convertActivityToResponse(activity models.Activity) ActivityResponse {
 	resp := ActivityResponse{
@@ -998,8 +1711,14 @@ func convertActivityToResponse(activity models.Activity) ActivityResponse {
 		StartTime: activity.StartTime,
 		EndTime:   activity.EndTime,
 		Notes:     activity.Notes,
+		Version:   activity.Version,
 		CreatedAt: activity.CreatedAt,
 		UpdatedAt: activity.UpdatedAt,
+		CreatedBy: convertAuthorToResponse(activity.CreatedBy),
+		UpdatedBy: convertAuthorToResponse(activity.UpdatedBy),
+	}
+	if activity.DeletedAt.Valid {
+		resp.DeletedAt = &activity.DeletedAt.Time
 	}
 
 	// Add activity-specific data
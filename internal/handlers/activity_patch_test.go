@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+func patchActivity(t *testing.T, ctx *TestContext, id, patch string) (ActivityResponse, error) {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "PATCH", "/api/activities/"+id, json.RawMessage(patch))
+	c.Request().Header.Set(echo.HeaderContentType, mergePatchContentType)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	if err := PatchActivity(c); err != nil {
+		return ActivityResponse{}, err
+	}
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response ActivityResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response, nil
+}
+
+func TestPatchActivity(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	endTime := time.Now().Add(-30 * time.Minute)
+	c, rec := createEchoContext(ctx, "POST", "/api/activities", ActivityRequest{
+		Type:      "feed",
+		StartTime: time.Now().Add(-time.Hour),
+		EndTime:   &endTime,
+		Notes:     "Bottel",
+		FeedData: &FeedData{
+			FeedType:        "bottle",
+			AmountML:        floatPtr(120),
+			DurationMinutes: intPtr(30),
+		},
+	})
+	require.NoError(t, CreateActivity(c))
+	var created ActivityResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+	t.Run("only the supplied fields change", func(t *testing.T) {
+		patched, err := patchActivity(t, ctx, created.ID, `{"notes": "Bottle"}`)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Bottle", patched.Notes)
+		assert.Equal(t, "feed", patched.Type)
+		assert.WithinDuration(t, created.StartTime, patched.StartTime, time.Second)
+		require.NotNil(t, patched.EndTime)
+		require.NotNil(t, patched.FeedData)
+		assert.Equal(t, float64(120), *patched.FeedData.AmountML)
+		assert.Equal(t, 30, *patched.FeedData.DurationMinutes)
+		assert.Equal(t, created.Version+1, patched.Version)
+	})
+
+	t.Run("nested fields are merged and null removes them", func(t *testing.T) {
+		patched, err := patchActivity(t, ctx, created.ID, `{"feed_data": {"amount_ml": 150, "duration_minutes": null}, "end_time": null}`)
+		require.NoError(t, err)
+
+		assert.Nil(t, patched.EndTime)
+		require.NotNil(t, patched.FeedData)
+		assert.Equal(t, "bottle", patched.FeedData.FeedType)
+		assert.Equal(t, float64(150), *patched.FeedData.AmountML)
+		assert.Nil(t, patched.FeedData.DurationMinutes)
+		assert.Equal(t, "Bottle", patched.Notes)
+	})
+
+	t.Run("the merged result is validated", func(t *testing.T) {
+		_, err := patchActivity(t, ctx, created.ID, `{"feed_data": {"feed_type": "juice"}}`)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+
+		// Changing the type needs data for the new type
+		_, err = patchActivity(t, ctx, created.ID, `{"type": "diaper"}`)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+
+		_, err = patchActivity(t, ctx, created.ID, `["notes"]`)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+
+		// Nothing was changed
+		var feed models.FeedActivity
+		require.NoError(t, ctx.DB.First(&feed, "activity_id = ?", created.ID).Error)
+		assert.Equal(t, models.FeedTypeBottle, feed.FeedType)
+	})
+
+	t.Run("changing the type replaces the details", func(t *testing.T) {
+		patched, err := patchActivity(t, ctx, created.ID, `{"type": "diaper", "feed_data": null, "diaper_data": {"wet": true}}`)
+		require.NoError(t, err)
+
+		assert.Equal(t, "diaper", patched.Type)
+		assert.Nil(t, patched.FeedData)
+		require.NotNil(t, patched.DiaperData)
+		assert.True(t, patched.DiaperData.Wet)
+
+		var count int64
+		ctx.DB.Model(&models.FeedActivity{}).Where("activity_id = ?", created.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("the change is recorded in the history", func(t *testing.T) {
+		history := getActivityHistory(t, ctx, created.ID)
+		assert.Equal(t, "update", history[len(history)-1].Action)
+	})
+
+	t.Run("If-Match is honoured", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "PATCH", "/api/activities/"+created.ID, json.RawMessage(`{"notes": "stale"}`))
+		c.SetParamNames("id")
+		c.SetParamValues(created.ID)
+		c.Request().Header.Set("If-Match", versionETag(created.Version))
+		require.NoError(t, PatchActivity(c))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("other content types are refused", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "PATCH", "/api/activities/"+created.ID, json.RawMessage(`{"notes": "x"}`))
+		c.Request().Header.Set(echo.HeaderContentType, "application/json-patch+json")
+		c.SetParamNames("id")
+		c.SetParamValues(created.ID)
+		err := PatchActivity(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, err.(*echo.HTTPError).Code)
+	})
+}
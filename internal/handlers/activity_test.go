@@ -675,3 +675,59 @@ func TestActivityCrossBabyAccess(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, httpError.Code)
 	})
 }
+
+func TestActivityAuthors(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	partner := addTestMember(t, ctx, models.BabyRoleCaregiver)
+
+	req := ActivityRequest{
+		Type:       "diaper",
+		StartTime:  time.Now(),
+		DiaperData: &DiaperData{Wet: true},
+	}
+
+	// Logged by the test user
+	c, rec := createEchoContext(ctx, "POST", "/api/activities", req)
+	require.NoError(t, CreateActivity(c))
+	var created ActivityResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+	require.NotNil(t, created.CreatedBy)
+	assert.Equal(t, ctx.User.Username, created.CreatedBy.Username)
+	require.NotNil(t, created.UpdatedBy)
+	assert.Equal(t, ctx.User.ID.String(), created.UpdatedBy.ID)
+
+	// Logged by the partner
+	c, _ = createEchoContext(ctx, "POST", "/api/activities", req)
+	asUser(c, partner)
+	require.NoError(t, CreateActivity(c))
+
+	t.Run("update records editor", func(t *testing.T) {
+		req.Notes = "Corrected"
+		c, rec := createEchoContext(ctx, "PUT", "/api/activities/"+created.ID, req)
+		c.SetParamNames("id")
+		c.SetParamValues(created.ID)
+		asUser(c, partner)
+
+		require.NoError(t, UpdateActivity(c))
+
+		var updated ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, ctx.User.Username, updated.CreatedBy.Username)
+		assert.Equal(t, partner.Username, updated.UpdatedBy.Username)
+	})
+
+	t.Run("filter by caregiver", func(t *testing.T) {
+		for _, filter := range []string{partner.ID.String(), partner.Username} {
+			c, rec := createEchoContext(ctx, "GET", "/api/activities?created_by="+filter, nil)
+			require.NoError(t, GetActivities(c))
+
+			var response ActivityListResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			require.Len(t, response.Activities, 1)
+			assert.Equal(t, partner.Username, response.Activities[0].CreatedBy.Username)
+		}
+	})
+}
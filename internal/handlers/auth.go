@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -21,6 +24,24 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Message  string `json:"message"`
 	Username string `json:"username"`
+	// TwoFactorRequired means the password was accepted but the login must
+	// be completed with POST /api/auth/login/2fa
+	TwoFactorRequired bool `json:"two_factor_required,omitempty"`
+}
+
+// ChangePasswordRequest represents the request body for changing a password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+// LoginAttemptResponse represents an entry in a user's login history
+type LoginAttemptResponse struct {
+	ID        string    `json:"id"`
+	Result    string    `json:"result"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // UserResponse represents the user response
@@ -47,14 +68,26 @@ func Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
 	}
 
+	username := strings.TrimSpace(req.Username)
+	attempt := newLoginAttempt(c, username)
+
+	// Slow down repeated failures for this username or IP
+	if err := checkLoginThrottle(c, db, attempt); err != nil {
+		return err
+	}
+
 	// Find user by username
 	var user models.User
-	if err := db.Where("username = ?", strings.TrimSpace(req.Username)).First(&user).Error; err != nil {
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			if err := recordLoginAttempt(db, attempt, models.LoginResultInvalidCredentials); err != nil {
+				return err
+			}
 			return echo.NewHTTPError(http.StatusUnauthorized, "invalid username or password")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
 	}
+	attempt.UserID = &user.ID
 
 	// Verify password
 	valid, err := utils.VerifyPassword(req.Password, user.PasswordHash)
@@ -62,20 +95,96 @@ func Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "password verification error")
 	}
 	if !valid {
+		if err := recordLoginAttempt(db, attempt, models.LoginResultInvalidCredentials); err != nil {
+			return err
+		}
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid username or password")
 	}
 
+	// Upgrade hashes made under an older, weaker policy while we have the password
+	if utils.NeedsRehash(user.PasswordHash, utils.DefaultArgon2Params) {
+		if err := rehashPassword(db, &user, req.Password); err != nil {
+			// Not fatal, the old hash still works
+			c.Logger().Errorf("failed to rehash password for %s: %v", user.Username, err)
+		}
+	}
+
+	// Users with two-factor authentication need to give a code as well
+	if user.TOTPEnabled() {
+		if err := recordLoginAttempt(db, attempt, models.LoginResultTwoFactorRequired); err != nil {
+			return err
+		}
+		if err := utils.CreatePendingTwoFactorSession(c, user.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "session creation error")
+		}
+		return c.JSON(http.StatusOK, LoginResponse{
+			Message:           "two-factor authentication required",
+			Username:          user.Username,
+			TwoFactorRequired: true,
+		})
+	}
+
 	// Create session
 	if err := utils.CreateUserSession(c, user.ID, user.Username); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "session creation error")
 	}
 
+	if err := recordLoginAttempt(db, attempt, models.LoginResultSuccess); err != nil {
+		return err
+	}
+
 	return c.JSON(http.StatusOK, LoginResponse{
 		Message:  "login successful",
 		Username: user.Username,
 	})
 }
 
+// rehashPassword replaces a user's password hash with one made under the
+// current Argon2 parameters
+func rehashPassword(db *gorm.DB, user *models.User, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return db.Model(user).Update("password_hash", hash).Error
+}
+
+// newLoginAttempt starts a login history entry for the request
+func newLoginAttempt(c echo.Context, username string) models.LoginAttempt {
+	return models.LoginAttempt{
+		Username:  utils.TruncateString(username, 50),
+		IPAddress: utils.TruncateString(c.RealIP(), 45),
+		UserAgent: utils.TruncateString(c.Request().UserAgent(), 255),
+	}
+}
+
+// checkLoginThrottle returns a 429 error if the attempt has to wait because
+// of earlier failures
+func checkLoginThrottle(c echo.Context, db *gorm.DB, attempt models.LoginAttempt) error {
+	wait, err := utils.DefaultLoginThrottle.RetryAfter(db, attempt.Username, attempt.IPAddress, time.Now())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+	if wait > 0 {
+		if err := recordLoginAttempt(db, attempt, models.LoginResultThrottled); err != nil {
+			return err
+		}
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "too many failed login attempts, try again later")
+	}
+	return nil
+}
+
+// recordLoginAttempt adds an entry to the login history, which also drives
+// login throttling
+func recordLoginAttempt(db *gorm.DB, attempt models.LoginAttempt, result models.LoginResult) error {
+	attempt.Result = result
+	if err := db.Create(&attempt).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record login attempt")
+	}
+	return nil
+}
+
 // Logout handles user logout
 func Logout(c echo.Context) error {
 	// Destroy session
@@ -90,9 +199,9 @@ func Logout(c echo.Context) error {
 
 // GetCurrentUser returns the current authenticated user
 func GetCurrentUser(c echo.Context) error {
-	// Get user from session
-	userID, _, err := utils.GetUserSession(c)
-	if err != nil {
+	// Get user from context, set from either the session or an API token
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
 	}
 
@@ -104,7 +213,7 @@ func GetCurrentUser(c echo.Context) error {
 
 	// Verify user still exists in database
 	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// User was deleted, destroy session
 			utils.DestroyUserSession(c)
@@ -119,6 +228,121 @@ func GetCurrentUser(c echo.Context) error {
 	})
 }
 
+// ChangePassword handles PUT /api/auth/password. The current password must be
+// given again, and every other session of the user is logged out.
+func ChangePassword(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot change passwords")
+	}
+
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	valid, err := utils.VerifyPassword(req.CurrentPassword, user.PasswordHash)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "password verification error")
+	}
+	if !valid {
+		return echo.NewHTTPError(http.StatusUnauthorized, "current password is incorrect")
+	}
+
+	hash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to hash password")
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(&user).Update("password_hash", hash).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update password")
+	}
+
+	if err := utils.DeleteUserSessions(tx, user.ID, utils.CurrentSessionKey(c)); err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update password")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "password changed successfully",
+	})
+}
+
+// GetLoginHistory handles GET /api/auth/logins, listing recent successful and
+// failed logins to the current user's account
+func GetLoginHistory(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	limit := 50
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+			limit = l
+		}
+	}
+
+	var attempts []models.LoginAttempt
+	if err := db.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&attempts).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch login history")
+	}
+
+	response := make([]LoginAttemptResponse, len(attempts))
+	for i, attempt := range attempts {
+		response[i] = LoginAttemptResponse{
+			ID:        attempt.ID.String(),
+			Result:    string(attempt.Result),
+			IPAddress: attempt.IPAddress,
+			UserAgent: attempt.UserAgent,
+			CreatedAt: attempt.CreatedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
 // CheckAuth is a simple endpoint to check if user is authenticated
 func CheckAuth(c echo.Context) error {
 	if !utils.IsAuthenticated(c) {
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,14 +17,17 @@ import (
 
 // BabyResponse represents the response for baby data
 type BabyResponse struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	BirthDate   time.Time `json:"birth_date"`
-	TrackSleep  bool      `json:"track_sleep"`
-	BirthWeight *float64  `json:"birth_weight,omitempty"`
-	BirthHeight *float64  `json:"birth_height,omitempty"`
-	AgeInDays   int       `json:"age_in_days"`
-	AgeDisplay  string    `json:"age_display"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	BirthDate   time.Time  `json:"birth_date"`
+	TrackSleep  bool       `json:"track_sleep"`
+	BirthWeight *float64   `json:"birth_weight,omitempty"`
+	BirthHeight *float64   `json:"birth_height,omitempty"`
+	AgeInDays   int        `json:"age_in_days"`
+	AgeDisplay  string     `json:"age_display"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	Role        string     `json:"role"`
+	Version     int        `json:"version"`
 }
 
 // GetBabies handles GET /api/babies
@@ -44,26 +50,29 @@ func GetBabies(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
 	}
 
-	// Get babies for user
-	var babies []models.Baby
-	if err := db.Where("user_id = ?", uid).Find(&babies).Error; err != nil {
+	// Get the babies the user is a member of
+	query := db.Preload("Baby").Where("user_id = ?", uid)
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		query = query.Where("baby_id = ?", *token.BabyID)
+	}
+
+	var members []models.BabyMember
+	if err := query.Find(&members).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch babies")
 	}
 
-	// Convert to response format
-	response := make([]BabyResponse, len(babies))
-	for i, baby := range babies {
-		ageInDays := int(time.Since(baby.BirthDate).Hours() / 24)
-		response[i] = BabyResponse{
-			ID:          baby.ID.String(),
-			Name:        baby.Name,
-			BirthDate:   baby.BirthDate,
-			TrackSleep:  baby.TrackSleep,
-			BirthWeight: baby.BirthWeight,
-			BirthHeight: baby.BirthHeight,
-			AgeInDays:   ageInDays,
-			AgeDisplay:  formatAge(ageInDays),
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Baby.CreatedAt.Before(members[j].Baby.CreatedAt)
+	})
+
+	// Convert to response format, hiding archived profiles unless asked for
+	includeArchived := c.QueryParam("include_archived") == "true"
+	response := make([]BabyResponse, 0, len(members))
+	for _, member := range members {
+		if member.Baby.IsArchived() && !includeArchived {
+			continue
 		}
+		response = append(response, convertBabyToResponse(member.Baby, member.Role))
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -139,10 +148,80 @@ func formatAge(days int) string {
 	return fmt.Sprintf("%s old", result)
 }
 
+// BabyRequest represents the request body for creating a baby profile
+type BabyRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	BirthDate   string   `json:"birth_date" validate:"required,datetime=2006-01-02"`
+	TrackSleep  *bool    `json:"track_sleep,omitempty"`
+	BirthWeight *float64 `json:"birth_weight,omitempty" validate:"omitempty,min=0.3,max=10"`
+	BirthHeight *float64 `json:"birth_height,omitempty" validate:"omitempty,min=20,max=70"`
+}
+
+// UpdateBabyRequest represents the request body for updating a baby profile.
+// Only the fields that are supplied are changed.
 type UpdateBabyRequest struct {
-	TrackSleep *bool `json:"track_sleep"`
+	Name        *string  `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	BirthDate   *string  `json:"birth_date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	TrackSleep  *bool    `json:"track_sleep,omitempty"`
+	BirthWeight *float64 `json:"birth_weight,omitempty" validate:"omitempty,min=0.3,max=10"`
+	BirthHeight *float64 `json:"birth_height,omitempty" validate:"omitempty,min=20,max=70"`
 }
 
+// CreateBaby handles POST /api/babies
+func CreateBaby(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	var req BabyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	birthDate, err := parseBirthDate(req.BirthDate)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	baby := models.Baby{
+		UserID:      uid,
+		Name:        strings.TrimSpace(req.Name),
+		BirthDate:   birthDate,
+		BirthWeight: req.BirthWeight,
+		BirthHeight: req.BirthHeight,
+	}
+
+	if err := db.Create(&baby).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create baby")
+	}
+
+	// GORM skips zero values that have a column default on insert,
+	// so an explicit "false" has to be written separately
+	if req.TrackSleep != nil && !*req.TrackSleep {
+		if err := db.Model(&baby).Update("track_sleep", false).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create baby")
+		}
+	}
+
+	return jsonWithETag(c, http.StatusCreated, baby.Version, convertBabyToResponse(baby, models.BabyRoleOwner))
+}
+
+// UpdateBaby handles PUT /api/babies/:baby_id
 func UpdateBaby(c echo.Context) error {
 	userID, ok := c.Get("user_id").(string)
 	if !ok {
@@ -164,27 +243,136 @@ func UpdateBaby(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
 	}
 
-	// Find the baby and check if it belongs to the user
-	var baby models.Baby
-	if err := db.Where("id = ? AND user_id = ?", babyID, userID).First(&baby).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return echo.NewHTTPError(http.StatusNotFound, "baby not found")
-		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Find the baby and check that the user owns it
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
 	}
 
-	// Update the baby's track_sleep field
+	// Don't overwrite changes the client hasn't seen
+	if !ifMatch(c, baby.Version) {
+		return jsonWithETag(c, http.StatusPreconditionFailed, baby.Version, convertBabyToResponse(*baby, models.BabyRoleOwner))
+	}
+
+	// Apply the supplied fields
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "name cannot be empty")
+		}
+		baby.Name = name
+	}
+	if req.BirthDate != nil {
+		birthDate, err := parseBirthDate(*req.BirthDate)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		baby.BirthDate = birthDate
+	}
 	if req.TrackSleep != nil {
 		baby.TrackSleep = *req.TrackSleep
 	}
+	if req.BirthWeight != nil {
+		baby.BirthWeight = req.BirthWeight
+	}
+	if req.BirthHeight != nil {
+		baby.BirthHeight = req.BirthHeight
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := claimNextVersion(tx, &models.Baby{}, baby.ID, baby.Version); err != nil {
+			return err
+		}
+		baby.Version++
+		return tx.Save(baby).Error
+	})
+	if errors.Is(err, errVersionConflict) {
+		var current models.Baby
+		if err := db.First(&current, "id = ?", baby.ID).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch baby")
+		}
+		return jsonWithETag(c, http.StatusPreconditionFailed, current.Version, convertBabyToResponse(current, models.BabyRoleOwner))
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update baby")
+	}
+
+	return jsonWithETag(c, http.StatusOK, baby.Version, convertBabyToResponse(*baby, models.BabyRoleOwner))
+}
+
+// ArchiveBaby handles DELETE /api/babies/:baby_id
+//
+// Babies are archived rather than deleted so that their history is kept.
+// Archived babies are hidden from GET /api/babies unless include_archived=true.
+func ArchiveBaby(c echo.Context) error {
+	return setBabyArchived(c, true)
+}
+
+// UnarchiveBaby handles POST /api/babies/:baby_id/unarchive
+func UnarchiveBaby(c echo.Context) error {
+	return setBabyArchived(c, false)
+}
+
+// setBabyArchived archives or restores the baby identified by the baby_id path param
+func setBabyArchived(c echo.Context, archived bool) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	babyID := c.Param("baby_id")
+	if babyID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "baby ID is required")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
 
-	if err := db.Save(&baby).Error; err != nil {
+	if archived {
+		now := time.Now()
+		baby.ArchivedAt = &now
+	} else {
+		baby.ArchivedAt = nil
+	}
+
+	if err := db.Model(baby).Updates(map[string]interface{}{
+		"archived_at": baby.ArchivedAt,
+		"version":     gorm.Expr("version + 1"),
+	}).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update baby")
 	}
+	baby.Version++
+
+	return jsonWithETag(c, http.StatusOK, baby.Version, convertBabyToResponse(*baby, models.BabyRoleOwner))
+}
 
-	// Convert to response format for consistency
+// parseBirthDate parses a YYYY-MM-DD birth date and rejects dates in the future
+func parseBirthDate(value string) (time.Time, error) {
+	birthDate, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid birth date format, use YYYY-MM-DD")
+	}
+	if birthDate.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("birth date cannot be in the future")
+	}
+	return birthDate, nil
+}
+
+// convertBabyToResponse converts a model and the user's role on it to response format
+func convertBabyToResponse(baby models.Baby, role models.BabyRole) BabyResponse {
 	ageInDays := int(time.Since(baby.BirthDate).Hours() / 24)
-	response := BabyResponse{
+	return BabyResponse{
 		ID:          baby.ID.String(),
 		Name:        baby.Name,
 		BirthDate:   baby.BirthDate,
@@ -193,7 +381,8 @@ func UpdateBaby(c echo.Context) error {
 		BirthHeight: baby.BirthHeight,
 		AgeInDays:   ageInDays,
 		AgeDisplay:  formatAge(ageInDays),
+		ArchivedAt:  baby.ArchivedAt,
+		Role:        string(role),
+		Version:     baby.Version,
 	}
-
-	return c.JSON(http.StatusOK, response)
 }
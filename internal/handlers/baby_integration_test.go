@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -223,3 +224,182 @@ func TestBabyAgeCalculations(t *testing.T) {
 		})
 	}
 }
+
+func TestBabyScopedRequests(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	// The test baby is the older twin; the second twin is created later and
+	// so becomes the default baby for requests without a baby_id
+	olderTwin := ctx.Baby
+	time.Sleep(10 * time.Millisecond)
+	youngerTwin := &models.Baby{
+		UserID:    ctx.User.ID,
+		Name:      "Younger Twin",
+		BirthDate: olderTwin.BirthDate,
+	}
+	require.NoError(t, ctx.DB.Create(youngerTwin).Error)
+
+	activity := createTestActivity(t, ctx, "diaper")
+	require.Equal(t, olderTwin.ID, activity.BabyID)
+
+	t.Run("list activities falls back to the latest baby", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/activities", nil)
+		err := GetActivities(c)
+		require.NoError(t, err)
+
+		var response ActivityListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, int64(0), response.Total)
+	})
+
+	t.Run("list activities by baby_id query param", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/activities?baby_id="+olderTwin.ID.String(), nil)
+		err := GetActivities(c)
+		require.NoError(t, err)
+
+		var response ActivityListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Equal(t, int64(1), response.Total)
+		assert.Equal(t, activity.ID.String(), response.Activities[0].ID)
+	})
+
+	t.Run("get activity by nested route", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/babies/"+olderTwin.ID.String()+"/activities/"+activity.ID.String(), nil)
+		c.SetParamNames("baby_id", "id")
+		c.SetParamValues(olderTwin.ID.String(), activity.ID.String())
+
+		err := GetActivity(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("get activity without baby_id uses the activity's baby", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/activities/"+activity.ID.String(), nil)
+		c.SetParamNames("id")
+		c.SetParamValues(activity.ID.String())
+
+		err := GetActivity(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("get activity under the other twin", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "GET", "/api/babies/"+youngerTwin.ID.String()+"/activities/"+activity.ID.String(), nil)
+		c.SetParamNames("baby_id", "id")
+		c.SetParamValues(youngerTwin.ID.String(), activity.ID.String())
+
+		err := GetActivity(c)
+		require.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, httpError.Code)
+	})
+
+	t.Run("update activity with baby_id", func(t *testing.T) {
+		req := ActivityRequest{
+			Type:      "diaper",
+			StartTime: time.Now().Add(-30 * time.Minute),
+			DiaperData: &DiaperData{
+				Wet: true,
+			},
+		}
+
+		c, rec := createEchoContext(ctx, "PUT", "/api/activities/"+activity.ID.String()+"?baby_id="+olderTwin.ID.String(), req)
+		c.SetParamNames("id")
+		c.SetParamValues(activity.ID.String())
+
+		err := UpdateActivity(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("daily stats with baby_id", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/stats/daily?baby_id="+olderTwin.ID.String(), nil)
+		err := GetDailyStats(c)
+		require.NoError(t, err)
+
+		var response DailyStatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Counts["diaper"])
+	})
+
+	t.Run("stop timer with baby_id", func(t *testing.T) {
+		timer := &models.Activity{
+			BabyID:    olderTwin.ID,
+			Type:      models.ActivityTypeSleep,
+			StartTime: time.Now().Add(-1 * time.Hour),
+		}
+		require.NoError(t, ctx.DB.Create(timer).Error)
+
+		c, rec := createEchoContext(ctx, "PUT", "/api/activities/timer/"+timer.ID.String()+"/stop?baby_id="+olderTwin.ID.String(), TimerStopRequest{})
+		c.SetParamNames("id")
+		c.SetParamValues(timer.ID.String())
+
+		err := StopActivityTimer(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("delete activity with baby_id", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "DELETE", "/api/activities/"+activity.ID.String()+"?baby_id="+olderTwin.ID.String(), nil)
+		c.SetParamNames("id")
+		c.SetParamValues(activity.ID.String())
+
+		err := DeleteActivity(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("trash, restore and history by nested route", func(t *testing.T) {
+		prefix := "/api/babies/" + olderTwin.ID.String() + "/activities/"
+
+		c, rec := createEchoContext(ctx, "GET", prefix+"trash", nil)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(olderTwin.ID.String())
+		require.NoError(t, GetActivityTrash(c))
+
+		var trash ActivityTrashResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trash))
+		require.Len(t, trash.Activities, 1)
+		assert.Equal(t, activity.ID.String(), trash.Activities[0].ID)
+
+		c, rec = createEchoContext(ctx, "POST", prefix+activity.ID.String()+"/restore", nil)
+		c.SetParamNames("baby_id", "id")
+		c.SetParamValues(olderTwin.ID.String(), activity.ID.String())
+		require.NoError(t, RestoreActivity(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		c, rec = createEchoContext(ctx, "GET", prefix+activity.ID.String()+"/history", nil)
+		c.SetParamNames("baby_id", "id")
+		c.SetParamValues(olderTwin.ID.String(), activity.ID.String())
+		require.NoError(t, GetActivityHistory(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("invalid baby_id", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "GET", "/api/stats/recent?baby_id=not-a-uuid", nil)
+		err := GetRecentStats(c)
+		assert.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	})
+
+	t.Run("another user's baby", func(t *testing.T) {
+		otherUser := createTestUser(t, ctx.DB)
+		otherBaby := &models.Baby{
+			UserID:    otherUser.ID,
+			Name:      "Other Baby",
+			BirthDate: time.Now(),
+		}
+		require.NoError(t, ctx.DB.Create(otherBaby).Error)
+
+		c, _ := createEchoContext(ctx, "GET", "/api/stats/weekly?baby_id="+otherBaby.ID.String(), nil)
+		err := GetWeeklyStats(c)
+		assert.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, httpError.Code)
+	})
+}
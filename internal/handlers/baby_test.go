@@ -262,6 +262,201 @@ func TestBabyCrossBoundary(t *testing.T) {
 	}
 }
 
+func TestCreateBaby(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	t.Run("create baby with all fields", func(t *testing.T) {
+		trackSleep := false
+		req := BabyRequest{
+			Name:        "Second Child",
+			BirthDate:   time.Now().AddDate(0, 0, -3).Format("2006-01-02"),
+			TrackSleep:  &trackSleep,
+			BirthWeight: floatPtr(3.1),
+			BirthHeight: floatPtr(49.5),
+		}
+
+		c, rec := createEchoContext(ctx, "POST", "/api/babies", req)
+		err := CreateBaby(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response BabyResponse
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "Second Child", response.Name)
+		assert.False(t, response.TrackSleep)
+		assert.Equal(t, 3.1, *response.BirthWeight)
+		assert.Equal(t, 49.5, *response.BirthHeight)
+
+		var baby models.Baby
+		err = ctx.DB.First(&baby, "id = ?", response.ID).Error
+		require.NoError(t, err)
+		assert.Equal(t, ctx.User.ID, baby.UserID)
+		assert.False(t, baby.TrackSleep)
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		req := BabyRequest{BirthDate: "2024-01-01"}
+
+		c, _ := createEchoContext(ctx, "POST", "/api/babies", req)
+		err := CreateBaby(c)
+		assert.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	})
+
+	t.Run("invalid birth date", func(t *testing.T) {
+		req := BabyRequest{Name: "Baby", BirthDate: "01/01/2024"}
+
+		c, _ := createEchoContext(ctx, "POST", "/api/babies", req)
+		err := CreateBaby(c)
+		assert.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	})
+
+	t.Run("birth date in the future", func(t *testing.T) {
+		req := BabyRequest{Name: "Baby", BirthDate: time.Now().AddDate(0, 0, 2).Format("2006-01-02")}
+
+		c, _ := createEchoContext(ctx, "POST", "/api/babies", req)
+		err := CreateBaby(c)
+		assert.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	})
+}
+
+func TestUpdateBaby(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	t.Run("update profile fields", func(t *testing.T) {
+		name := "Renamed Baby"
+		birthDate := "2024-02-29"
+		req := UpdateBabyRequest{
+			Name:        &name,
+			BirthDate:   &birthDate,
+			BirthWeight: floatPtr(3.4),
+			BirthHeight: floatPtr(51),
+		}
+
+		c, rec := createEchoContext(ctx, "PUT", "/api/babies/"+ctx.Baby.ID.String(), req)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(ctx.Baby.ID.String())
+
+		err := UpdateBaby(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response BabyResponse
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed Baby", response.Name)
+		assert.Equal(t, "2024-02-29", response.BirthDate.Format("2006-01-02"))
+		assert.Equal(t, 3.4, *response.BirthWeight)
+		assert.Equal(t, 51.0, *response.BirthHeight)
+		assert.True(t, response.TrackSleep)
+	})
+
+	t.Run("toggle track sleep only", func(t *testing.T) {
+		trackSleep := false
+		req := UpdateBabyRequest{TrackSleep: &trackSleep}
+
+		c, rec := createEchoContext(ctx, "PUT", "/api/babies/"+ctx.Baby.ID.String(), req)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(ctx.Baby.ID.String())
+
+		err := UpdateBaby(c)
+		require.NoError(t, err)
+
+		var response BabyResponse
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.False(t, response.TrackSleep)
+		assert.Equal(t, "Renamed Baby", response.Name)
+	})
+
+	t.Run("cannot update another user's baby", func(t *testing.T) {
+		otherUser := createTestUser(t, ctx.DB)
+		otherBaby := &models.Baby{
+			UserID:    otherUser.ID,
+			Name:      "Other Baby",
+			BirthDate: time.Now(),
+		}
+		require.NoError(t, ctx.DB.Create(otherBaby).Error)
+
+		name := "Hijacked"
+		c, _ := createEchoContext(ctx, "PUT", "/api/babies/"+otherBaby.ID.String(), UpdateBabyRequest{Name: &name})
+		c.SetParamNames("baby_id")
+		c.SetParamValues(otherBaby.ID.String())
+
+		err := UpdateBaby(c)
+		assert.Error(t, err)
+		httpError, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, httpError.Code)
+	})
+}
+
+func TestArchiveBaby(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	c, rec := createEchoContext(ctx, "DELETE", "/api/babies/"+ctx.Baby.ID.String(), nil)
+	c.SetParamNames("baby_id")
+	c.SetParamValues(ctx.Baby.ID.String())
+
+	err := ArchiveBaby(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	t.Run("archived baby is hidden by default", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/babies", nil)
+		err := GetBabies(c)
+		require.NoError(t, err)
+
+		var babies []BabyResponse
+		err = json.Unmarshal(rec.Body.Bytes(), &babies)
+		require.NoError(t, err)
+		assert.Len(t, babies, 0)
+	})
+
+	t.Run("archived baby is listed when requested", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/babies?include_archived=true", nil)
+		err := GetBabies(c)
+		require.NoError(t, err)
+
+		var babies []BabyResponse
+		err = json.Unmarshal(rec.Body.Bytes(), &babies)
+		require.NoError(t, err)
+		require.Len(t, babies, 1)
+		assert.NotNil(t, babies[0].ArchivedAt)
+	})
+
+	t.Run("archived baby is not used as the default", func(t *testing.T) {
+		_, err := getUserBaby(ctx.DB, ctx.User.ID.String())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("unarchive restores the baby", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "POST", "/api/babies/"+ctx.Baby.ID.String()+"/unarchive", nil)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(ctx.Baby.ID.String())
+
+		err := UnarchiveBaby(c)
+		require.NoError(t, err)
+
+		baby, err := getUserBaby(ctx.DB, ctx.User.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, ctx.Baby.ID, baby.ID)
+		assert.Nil(t, baby.ArchivedAt)
+	})
+}
+
 func createTestUser(t *testing.T, db *gorm.DB) *models.User {
 	t.Helper()
 
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// errVersionConflict is returned when a record changed after it was read
+var errVersionConflict = errors.New("record was changed by another request")
+
+// versionETag returns the entity tag for a version of a record
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch reports whether the request's If-Match header allows changing a
+// record at the given version. Requests without the header always match.
+func ifMatch(c echo.Context, version int) bool {
+	header := c.Request().Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	etag := versionETag(version)
+	for _, candidate := range strings.Split(header, ",") {
+		// If-Match uses strong comparison, so weak tags never match
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// claimNextVersion moves a record from version on to the next one. It fails
+// with errVersionConflict when another request changed the record first, so
+// that the changes can't overwrite each other.
+func claimNextVersion(tx *gorm.DB, model interface{}, id uuid.UUID, version int) error {
+	result := tx.Model(model).
+		Where("id = ? AND version = ?", id, version).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
+// jsonWithETag sends a JSON response tagged with the version of the record it
+// represents
+func jsonWithETag(c echo.Context, code int, version int, body interface{}) error {
+	c.Response().Header().Set("ETag", versionETag(version))
+	return c.JSON(code, body)
+}
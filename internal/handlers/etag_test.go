@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+func TestActivityETags(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	activity := createTestActivity(t, ctx, "diaper")
+	id := activity.ID.String()
+
+	updateWith := func(ifMatch, notes string) (int, string, ActivityResponse) {
+		c, rec := createEchoContext(ctx, "PUT", "/api/activities/"+id, ActivityRequest{
+			Type:       "diaper",
+			StartTime:  activity.StartTime,
+			Notes:      notes,
+			DiaperData: &DiaperData{Wet: true},
+		})
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		if ifMatch != "" {
+			c.Request().Header.Set("If-Match", ifMatch)
+		}
+		require.NoError(t, UpdateActivity(c))
+
+		var response ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return rec.Code, rec.Header().Get("ETag"), response
+	}
+
+	t.Run("get returns the version as the ETag", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/activities/"+id, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		require.NoError(t, GetActivity(c))
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("update with the current ETag", func(t *testing.T) {
+		code, etag, response := updateWith(`"1"`, "first phone")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, `"2"`, etag)
+		assert.Equal(t, 2, response.Version)
+	})
+
+	t.Run("update with an outdated ETag is refused", func(t *testing.T) {
+		code, etag, response := updateWith(`"1"`, "second phone")
+		assert.Equal(t, http.StatusPreconditionFailed, code)
+		assert.Equal(t, `"2"`, etag)
+		assert.Equal(t, "first phone", response.Notes)
+		require.NotNil(t, response.DiaperData)
+
+		// Weak tags never match
+		code, _, _ = updateWith(`W/"2"`, "second phone")
+		assert.Equal(t, http.StatusPreconditionFailed, code)
+	})
+
+	t.Run("any of several ETags or a wildcard match", func(t *testing.T) {
+		code, etag, _ := updateWith(`"1", "2"`, "second phone")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, `"3"`, etag)
+
+		code, etag, _ = updateWith("*", "third phone")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, `"4"`, etag)
+	})
+
+	t.Run("updates without If-Match still move the version on", func(t *testing.T) {
+		code, etag, _ := updateWith("", "no precondition")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, `"5"`, etag)
+	})
+
+	t.Run("delete honours If-Match", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "DELETE", "/api/activities/"+id, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		c.Request().Header.Set("If-Match", `"4"`)
+		require.NoError(t, DeleteActivity(c))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.Equal(t, `"5"`, rec.Header().Get("ETag"))
+
+		var count int64
+		ctx.DB.Model(&models.Activity{}).Where("id = ?", id).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		c, rec = createEchoContext(ctx, "DELETE", "/api/activities/"+id, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		c.Request().Header.Set("If-Match", `"5"`)
+		require.NoError(t, DeleteActivity(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		ctx.DB.Model(&models.Activity{}).Where("id = ?", id).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("a change made after reading the activity is detected", func(t *testing.T) {
+		other := createTestActivity(t, ctx, "feed")
+
+		require.NoError(t, claimNextVersion(ctx.DB, &models.Activity{}, other.ID, 1))
+		err := claimNextVersion(ctx.DB, &models.Activity{}, other.ID, 1)
+		assert.ErrorIs(t, err, errVersionConflict)
+	})
+}
+
+func TestBabyETags(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	updateWith := func(ifMatch, name string) (int, string, BabyResponse) {
+		c, rec := createEchoContext(ctx, "PUT", "/api/babies/"+ctx.Baby.ID.String(), UpdateBabyRequest{Name: &name})
+		c.SetParamNames("baby_id")
+		c.SetParamValues(ctx.Baby.ID.String())
+		c.Request().Header.Set("If-Match", ifMatch)
+		require.NoError(t, UpdateBaby(c))
+
+		var response BabyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return rec.Code, rec.Header().Get("ETag"), response
+	}
+
+	code, etag, response := updateWith(`"1"`, "First Name")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"2"`, etag)
+	assert.Equal(t, 2, response.Version)
+
+	code, etag, response = updateWith(`"1"`, "Second Name")
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, `"2"`, etag)
+	assert.Equal(t, "First Name", response.Name)
+
+	var baby models.Baby
+	require.NoError(t, ctx.DB.First(&baby, "id = ?", ctx.Baby.ID).Error)
+	assert.Equal(t, "First Name", baby.Name)
+	assert.Equal(t, 2, baby.Version)
+
+	// Archiving changes the profile too
+	c, rec := createEchoContext(ctx, "DELETE", "/api/babies/"+ctx.Baby.ID.String(), nil)
+	c.SetParamNames("baby_id")
+	c.SetParamValues(ctx.Baby.ID.String())
+	require.NoError(t, ArchiveBaby(c))
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	require.NoError(t, ctx.DB.First(&baby, "id = ?", ctx.Baby.ID).Error)
+	assert.Equal(t, 3, baby.Version)
+}
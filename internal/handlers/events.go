@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/webhooks"
+)
+
+const (
+	// eventStreamRetry is how long clients wait before reconnecting, in ms
+	eventStreamRetry = 5000
+	// eventResetType tells a client that events were missed and it should
+	// reload what it shows
+	eventResetType = "reset"
+)
+
+// eventStreamHeartbeat is how often a comment is sent on an idle stream to
+// keep proxies from closing it. Access to the baby is checked again at the
+// same time.
+var eventStreamHeartbeat = 30 * time.Second
+
+// GetEvents handles GET /api/events. It streams the changes made to a baby's
+// activities as server-sent events. A client that reconnects with the
+// Last-Event-ID header (or last_event_id query param) is sent the events it
+// missed first, or a reset event if they are no longer known.
+func GetEvents(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	broker, ok := c.Get("events").(*events.Broker)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "event stream unavailable")
+	}
+
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleViewer)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+
+	sub, missed, found := broker.Subscribe(baby.ID, lastEventID)
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// Stop nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", eventStreamRetry); err != nil {
+		return nil
+	}
+	if !found {
+		// An empty ID clears the client's last event ID, so it won't be
+		// sent the reset again when it next reconnects
+		if _, err := fmt.Fprintf(res, "id\nevent: %s\ndata: {}\n\n", eventResetType); err != nil {
+			return nil
+		}
+	}
+	for _, event := range missed {
+		if err := writeEvent(res, event); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+
+		case event, ok := <-sub.Events:
+			// The client fell behind and will resume when it reconnects
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(res, event); err != nil {
+				return nil
+			}
+			res.Flush()
+
+		case <-heartbeat.C:
+			// Stop streaming to users who have lost access to the baby
+			if _, err := getBabyRole(db, baby.ID, userID); err != nil {
+				return nil
+			}
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// writeEvent writes an event in the server-sent events format
+func writeEvent(res *echo.Response, event events.Event) error {
+	_, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+// publishEvent tells the baby's other clients and webhooks about a change to
+// an activity. It is called once the change is committed, and failures are
+// only logged as the change itself has succeeded.
+func publishEvent(c echo.Context, babyID uuid.UUID, eventType string, activity *ActivityResponse) {
+	event, err := events.New(babyID, eventType, activity)
+	if err != nil {
+		c.Logger().Errorf("failed to create %s event: %v", eventType, err)
+		return
+	}
+
+	if broker, ok := c.Get("events").(*events.Broker); ok {
+		if err := broker.Publish(event); err != nil {
+			c.Logger().Errorf("failed to publish %s event: %v", eventType, err)
+		}
+	}
+
+	if db, ok := c.Get("db").(*gorm.DB); ok {
+		if err := webhooks.Enqueue(db, event, models.ActivityType(activity.Type)); err != nil {
+			c.Logger().Errorf("failed to queue %s webhooks: %v", eventType, err)
+		}
+	}
+}
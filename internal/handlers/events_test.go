@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// sseFrame is an event read from a stream
+type sseFrame struct {
+	ID    string
+	HasID bool
+	Event string
+	Data  string
+}
+
+// readEvents reads the events from a stream, leaving out comments and
+// frames with no event
+func readEvents(body *bufio.Reader) <-chan sseFrame {
+	frames := make(chan sseFrame)
+	go func() {
+		defer close(frames)
+		var frame sseFrame
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				if frame.Event != "" {
+					frames <- frame
+				}
+				frame = sseFrame{}
+			case line == "id":
+				frame.HasID = true
+			case strings.HasPrefix(line, "id: "):
+				frame.ID, frame.HasID = strings.TrimPrefix(line, "id: "), true
+			case strings.HasPrefix(line, "event: "):
+				frame.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				frame.Data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return frames
+}
+
+func nextEvent(t *testing.T, frames <-chan sseFrame) sseFrame {
+	t.Helper()
+	select {
+	case frame, ok := <-frames:
+		require.True(t, ok, "stream ended")
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return sseFrame{}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	viewer := addTestMember(t, ctx, models.BabyRoleViewer)
+	users := map[string]*models.User{
+		ctx.User.ID.String(): ctx.User,
+		viewer.ID.String():   viewer,
+	}
+
+	broker := events.NewBroker()
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", ctx.DB)
+			c.Set("config", ctx.Config)
+			c.Set("events", broker)
+			user := ctx.User
+			if id := c.Request().Header.Get("X-Test-User"); id != "" {
+				user = users[id]
+			}
+			asUser(c, user)
+			return next(c)
+		}
+	})
+	e.GET("/api/events", GetEvents)
+	e.POST("/api/activities", CreateActivity)
+	e.POST("/api/activities/timer/start", StartActivityTimer)
+	e.PUT("/api/activities/timer/:id/stop", StopActivityTimer)
+	e.DELETE("/api/activities/:id", DeleteActivity)
+
+	// Streams are closed by each test's cleanup, before the server
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	subscribe := func(t *testing.T, user *models.User, lastEventID string) (<-chan sseFrame, *http.Response) {
+		t.Helper()
+		req, err := http.NewRequest("GET", server.URL+"/api/events", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Test-User", user.ID.String())
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return readEvents(bufio.NewReader(resp.Body)), resp
+	}
+
+	send := func(t *testing.T, method, path string, body interface{}) ActivityResponse {
+		t.Helper()
+		encoded, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(encoded))
+		require.NoError(t, err)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Less(t, resp.StatusCode, 300)
+
+		var activity ActivityResponse
+		json.NewDecoder(resp.Body).Decode(&activity)
+		return activity
+	}
+
+	frames, resp := subscribe(t, viewer, "")
+	assert.Equal(t, "text/event-stream", resp.Header.Get(echo.HeaderContentType))
+	assert.Equal(t, "no-cache", resp.Header.Get(echo.HeaderCacheControl))
+
+	var createdEventID string
+
+	t.Run("changes are streamed to other caregivers", func(t *testing.T) {
+		created := send(t, "POST", "/api/activities", ActivityRequest{
+			Type:       "diaper",
+			StartTime:  time.Now(),
+			DiaperData: &DiaperData{Wet: true},
+		})
+
+		frame := nextEvent(t, frames)
+		assert.Equal(t, events.ActivityCreated, frame.Event)
+		assert.NotEmpty(t, frame.ID)
+		var data ActivityResponse
+		require.NoError(t, json.Unmarshal([]byte(frame.Data), &data))
+		assert.Equal(t, created.ID, data.ID)
+		createdEventID = frame.ID
+
+		send(t, "DELETE", "/api/activities/"+created.ID, nil)
+		frame = nextEvent(t, frames)
+		assert.Equal(t, events.ActivityDeleted, frame.Event)
+		require.NoError(t, json.Unmarshal([]byte(frame.Data), &data))
+		assert.NotNil(t, data.DeletedAt)
+	})
+
+	t.Run("timers are streamed", func(t *testing.T) {
+		timer := send(t, "POST", "/api/activities/timer/start", TimerStartRequest{Type: "sleep"})
+
+		frame := nextEvent(t, frames)
+		assert.Equal(t, events.TimerStarted, frame.Event)
+		assert.Contains(t, frame.Data, timer.ID)
+
+		send(t, "PUT", "/api/activities/timer/"+timer.ID+"/stop", nil)
+		frame = nextEvent(t, frames)
+		assert.Equal(t, events.TimerStopped, frame.Event)
+		var data ActivityResponse
+		require.NoError(t, json.Unmarshal([]byte(frame.Data), &data))
+		assert.NotNil(t, data.EndTime)
+	})
+
+	t.Run("reconnecting resumes after the last event", func(t *testing.T) {
+		resumed, _ := subscribe(t, ctx.User, createdEventID)
+		assert.Equal(t, events.ActivityDeleted, nextEvent(t, resumed).Event)
+		assert.Equal(t, events.TimerStarted, nextEvent(t, resumed).Event)
+		assert.Equal(t, events.TimerStopped, nextEvent(t, resumed).Event)
+	})
+
+	t.Run("an unknown last event asks for a reset", func(t *testing.T) {
+		resumed, _ := subscribe(t, ctx.User, "forgotten")
+		frame := nextEvent(t, resumed)
+		assert.Equal(t, eventResetType, frame.Event)
+		assert.True(t, frame.HasID)
+		assert.Empty(t, frame.ID)
+	})
+
+	t.Run("other babies' events aren't streamed", func(t *testing.T) {
+		other := createTestUser(t, ctx.DB)
+		c, _ := createEchoContext(ctx, "GET", "/api/events?baby_id="+ctx.Baby.ID.String(), nil)
+		c.Set("events", broker)
+		asUser(c, other)
+		err := GetEvents(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestEventsAccessLost(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	// Restored once the server has finished with it
+	interval := eventStreamHeartbeat
+	t.Cleanup(func() { eventStreamHeartbeat = interval })
+	eventStreamHeartbeat = 10 * time.Millisecond
+
+	viewer := addTestMember(t, ctx, models.BabyRoleViewer)
+
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", ctx.DB)
+			c.Set("events", events.NewBroker())
+			asUser(c, viewer)
+			return next(c)
+		}
+	})
+	e.GET("/api/events", GetEvents)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/api/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	stream := readEvents(bufio.NewReader(resp.Body))
+
+	require.NoError(t, ctx.DB.Where("baby_id = ? AND user_id = ?", ctx.Baby.ID, viewer.ID).
+		Delete(&models.BabyMember{}).Error)
+
+	select {
+	case _, ok := <-stream:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream wasn't closed")
+	}
+}
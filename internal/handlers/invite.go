@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+const (
+	// defaultInviteTTL is how long an invite stays valid when no expiry is given
+	defaultInviteTTL = 48 * time.Hour
+)
+
+// InviteRequest represents the request body for creating an invite
+type InviteRequest struct {
+	Role           string `json:"role" validate:"omitempty,oneof=owner caregiver viewer"`
+	ExpiresInHours int    `json:"expires_in_hours" validate:"omitempty,min=1,max=720"`
+}
+
+// InviteResponse represents an invite. The token is only returned when the
+// invite is created, since only its hash is stored.
+type InviteResponse struct {
+	ID        string     `json:"id"`
+	BabyID    string     `json:"baby_id"`
+	Role      string     `json:"role"`
+	Token     string     `json:"token,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// InviteInfoResponse describes an invite to the person redeeming it
+type InviteInfoResponse struct {
+	BabyName  string    `json:"baby_name"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AcceptInviteRequest represents the request body for redeeming an invite
+type AcceptInviteRequest struct {
+	Username string `json:"username" validate:"required,max=50"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// CreateInvite handles POST /api/babies/:baby_id/invites
+func CreateInvite(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req InviteRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	creatorID, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	role := models.BabyRoleCaregiver
+	if req.Role != "" {
+		role = models.BabyRole(req.Role)
+	}
+
+	ttl := defaultInviteTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	token, err := utils.GenerateToken(utils.DefaultTokenLength)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate invite token")
+	}
+
+	invite := models.Invite{
+		BabyID:      baby.ID,
+		CreatedByID: creatorID,
+		TokenHash:   utils.HashToken(token),
+		Role:        role,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := db.Create(&invite).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create invite")
+	}
+
+	response := convertInviteToResponse(invite)
+	response.Token = token
+	return c.JSON(http.StatusCreated, response)
+}
+
+// GetInvites handles GET /api/babies/:baby_id/invites
+func GetInvites(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	var invites []models.Invite
+	if err := db.Where("baby_id = ?", baby.ID).
+		Order("created_at DESC").
+		Find(&invites).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch invites")
+	}
+
+	response := make([]InviteResponse, len(invites))
+	for i, invite := range invites {
+		response[i] = convertInviteToResponse(invite)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// RevokeInvite handles DELETE /api/babies/:baby_id/invites/:id
+func RevokeInvite(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invite ID")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	result := db.Where("id = ? AND baby_id = ? AND used_at IS NULL", id, baby.ID).Delete(&models.Invite{})
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke invite")
+	}
+
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "invite not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "invite revoked successfully",
+	})
+}
+
+// GetInvite handles GET /api/invites/:token. It is public so the invitee can
+// see what they are joining before choosing a username and password.
+func GetInvite(c echo.Context) error {
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	invite, err := findRedeemableInvite(db, c.Param("token"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, InviteInfoResponse{
+		BabyName:  invite.Baby.Name,
+		Role:      string(invite.Role),
+		ExpiresAt: invite.ExpiresAt,
+	})
+}
+
+// AcceptInvite handles POST /api/invites/:token/accept. It creates the
+// invitee's account, grants them the invited role and logs them in.
+func AcceptInvite(c echo.Context) error {
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req AcceptInviteRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	invite, err := findRedeemableInvite(db, c.Param("token"))
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(&models.User{}).Where("username = ?", req.Username).Count(&count).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "username is already taken")
+	}
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to hash password")
+	}
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	user := models.User{
+		Username:     req.Username,
+		PasswordHash: hash,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create user")
+	}
+
+	// Claim the invite, guarding against it being redeemed concurrently
+	now := time.Now()
+	result := tx.Model(invite).
+		Where("used_at IS NULL").
+		Updates(map[string]interface{}{"used_at": now, "used_by_id": user.ID})
+	if result.Error != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to redeem invite")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusGone, "invite has expired or has already been used")
+	}
+
+	member := models.BabyMember{
+		BabyID: invite.BabyID,
+		UserID: user.ID,
+		Role:   invite.Role,
+	}
+	if err := tx.Create(&member).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add member")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to redeem invite")
+	}
+
+	// Log the new user in
+	if err := utils.CreateUserSession(c, user.ID, user.Username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "session creation error")
+	}
+
+	return c.JSON(http.StatusCreated, LoginResponse{
+		Message:  "invite accepted",
+		Username: user.Username,
+	})
+}
+
+// findRedeemableInvite looks up an invite by its token, returning an HTTP
+// error if it does not exist, has expired or has already been used
+func findRedeemableInvite(db *gorm.DB, token string) (*models.Invite, error) {
+	if token == "" {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "invite not found")
+	}
+
+	var invite models.Invite
+	if err := db.Preload("Baby").
+		Where("token_hash = ?", utils.HashToken(token)).
+		First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "invite not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	if !invite.IsRedeemable(time.Now()) || invite.Baby.IsArchived() {
+		return nil, echo.NewHTTPError(http.StatusGone, "invite has expired or has already been used")
+	}
+
+	return &invite, nil
+}
+
+// convertInviteToResponse converts a model to response format
+func convertInviteToResponse(invite models.Invite) InviteResponse {
+	return InviteResponse{
+		ID:        invite.ID.String(),
+		BabyID:    invite.BabyID.String(),
+		Role:      string(invite.Role),
+		ExpiresAt: invite.ExpiresAt,
+		UsedAt:    invite.UsedAt,
+		CreatedAt: invite.CreatedAt,
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// createTestInvite creates an invite through the API and returns its token
+func createTestInvite(t *testing.T, ctx *TestContext, req InviteRequest) InviteResponse {
+	t.Helper()
+
+	babyID := ctx.Baby.ID.String()
+	c, rec := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/invites", req)
+	c.SetParamNames("baby_id")
+	c.SetParamValues(babyID)
+
+	require.NoError(t, CreateInvite(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response InviteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotEmpty(t, response.Token)
+
+	return response
+}
+
+// acceptTestInvite redeems an invite anonymously with a session store attached
+func acceptTestInvite(ctx *TestContext, token string, req AcceptInviteRequest) (echo.Context, error) {
+	c, _ := createEchoContext(ctx, "POST", "/api/invites/"+token+"/accept", req)
+	c.Set("user_id", nil)
+	c.Set("username", nil)
+	c.Set("_session_store", sessions.NewCookieStore([]byte("test-secret")))
+	c.SetParamNames("token")
+	c.SetParamValues(token)
+
+	return c, AcceptInvite(c)
+}
+
+func TestCreateInvite(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	t.Run("defaults to caregiver for 48 hours", func(t *testing.T) {
+		invite := createTestInvite(t, ctx, InviteRequest{})
+
+		assert.Equal(t, "caregiver", invite.Role)
+		assert.WithinDuration(t, time.Now().Add(48*time.Hour), invite.ExpiresAt, time.Minute)
+
+		// Only the hash of the token is stored
+		var stored models.Invite
+		require.NoError(t, ctx.DB.First(&stored, "id = ?", invite.ID).Error)
+		assert.Equal(t, utils.HashToken(invite.Token), stored.TokenHash)
+	})
+
+	t.Run("non-owner cannot create invites", func(t *testing.T) {
+		caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+		babyID := ctx.Baby.ID.String()
+
+		c, _ := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/invites", InviteRequest{})
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+		asUser(c, caregiver)
+
+		err := CreateInvite(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, httpError.Code)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		babyID := ctx.Baby.ID.String()
+		c, _ := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/invites", InviteRequest{Role: "admin"})
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+
+		err := CreateInvite(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	})
+}
+
+func TestAcceptInvite(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	invite := createTestInvite(t, ctx, InviteRequest{Role: "viewer", ExpiresInHours: 4})
+
+	t.Run("get invite info", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/invites/"+invite.Token, nil)
+		c.SetParamNames("token")
+		c.SetParamValues(invite.Token)
+
+		require.NoError(t, GetInvite(c))
+
+		var info InviteInfoResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, ctx.Baby.Name, info.BabyName)
+		assert.Equal(t, "viewer", info.Role)
+	})
+
+	t.Run("username already taken", func(t *testing.T) {
+		_, err := acceptTestInvite(ctx, invite.Token, AcceptInviteRequest{
+			Username: ctx.User.Username,
+			Password: "babysitter123",
+		})
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusConflict, httpError.Code)
+	})
+
+	t.Run("accept creates user with role on baby", func(t *testing.T) {
+		c, err := acceptTestInvite(ctx, invite.Token, AcceptInviteRequest{
+			Username: "babysitter",
+			Password: "babysitter123",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, c.Response().Status)
+		assert.NotEmpty(t, c.Response().Header().Get("Set-Cookie"))
+
+		var user models.User
+		require.NoError(t, ctx.DB.Where("username = ?", "babysitter").First(&user).Error)
+
+		valid, err := utils.VerifyPassword("babysitter123", user.PasswordHash)
+		require.NoError(t, err)
+		assert.True(t, valid)
+
+		role, err := getBabyRole(ctx.DB, ctx.Baby.ID, user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.BabyRoleViewer, role)
+	})
+
+	t.Run("invite is single use", func(t *testing.T) {
+		_, err := acceptTestInvite(ctx, invite.Token, AcceptInviteRequest{
+			Username: "another",
+			Password: "another123",
+		})
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusGone, httpError.Code)
+	})
+
+	t.Run("expired invite is rejected", func(t *testing.T) {
+		expired := createTestInvite(t, ctx, InviteRequest{})
+		require.NoError(t, ctx.DB.Model(&models.Invite{}).
+			Where("id = ?", expired.ID).
+			UpdateColumn("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		_, err := acceptTestInvite(ctx, expired.Token, AcceptInviteRequest{
+			Username: "latecomer",
+			Password: "latecomer123",
+		})
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusGone, httpError.Code)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := acceptTestInvite(ctx, "not-a-token", AcceptInviteRequest{
+			Username: "stranger",
+			Password: "stranger123",
+		})
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, httpError.Code)
+	})
+}
+
+func TestRevokeInvite(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	invite := createTestInvite(t, ctx, InviteRequest{})
+	babyID := ctx.Baby.ID.String()
+
+	c, _ := createEchoContext(ctx, "DELETE", "/api/babies/"+babyID+"/invites/"+invite.ID, nil)
+	c.SetParamNames("baby_id", "id")
+	c.SetParamValues(babyID, invite.ID)
+	require.NoError(t, RevokeInvite(c))
+
+	c, rec := createEchoContext(ctx, "GET", "/api/babies/"+babyID+"/invites", nil)
+	c.SetParamNames("baby_id")
+	c.SetParamValues(babyID)
+	require.NoError(t, GetInvites(c))
+
+	var invites []InviteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &invites))
+	assert.Len(t, invites, 0)
+
+	_, err := acceptTestInvite(ctx, invite.Token, AcceptInviteRequest{
+		Username: "babysitter",
+		Password: "babysitter123",
+	})
+	httpError, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, httpError.Code)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// attemptLogin posts a login from the given client IP
+func attemptLogin(e *echo.Echo, username, password, ip string) *httptest.ResponseRecorder {
+	body := `{"username":"` + username + `","password":"` + password + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("User-Agent", "Test Browser")
+	req.Header.Set("X-Real-IP", ip)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// ageLoginAttempts moves every recorded attempt back in time
+func ageLoginAttempts(t *testing.T, ctx *TestContext, by time.Duration) {
+	t.Helper()
+	require.NoError(t, ctx.DB.Exec("UPDATE login_attempts SET created_at = ?", time.Now().Add(-by)).Error)
+}
+
+func TestLoginThrottling(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newSessionServer(ctx)
+	createPasswordUser(t, ctx, "parent", "password123")
+	limit := utils.DefaultLoginThrottle.MaxUsernameFailures
+
+	t.Run("repeated failures for a username are throttled", func(t *testing.T) {
+		for i := 0; i < limit; i++ {
+			rec := attemptLogin(e, "parent", "wrong", "192.0.2.10")
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		// Even the right password from another IP has to wait
+		rec := attemptLogin(e, "parent", "password123", "192.0.2.99")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.InDelta(t, utils.DefaultLoginThrottle.BaseDelay.Seconds(), retryAfter, 1)
+
+		// Throttled attempts don't extend the delay
+		var failures int64
+		ctx.DB.Model(&models.LoginAttempt{}).
+			Where("username = ? AND result = ?", "parent", models.LoginResultInvalidCredentials).
+			Count(&failures)
+		assert.Equal(t, int64(limit), failures)
+	})
+
+	t.Run("login works again once the delay has passed", func(t *testing.T) {
+		ageLoginAttempts(t, ctx, time.Minute)
+
+		rec := attemptLogin(e, "parent", "password123", "192.0.2.10")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// A success resets the username's count
+		rec = attemptLogin(e, "parent", "wrong", "192.0.2.10")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("repeated failures from an IP are throttled", func(t *testing.T) {
+		ageLoginAttempts(t, ctx, 2*time.Hour)
+
+		for i := 0; i < utils.DefaultLoginThrottle.MaxIPFailures; i++ {
+			rec := attemptLogin(e, "user"+strconv.Itoa(i), "wrong", "198.51.100.7")
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		rec := attemptLogin(e, "parent", "password123", "198.51.100.7")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		// Other clients are unaffected
+		rec = attemptLogin(e, "parent", "password123", "192.0.2.10")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("client IP headers from outside the proxy are ignored", func(t *testing.T) {
+		ageLoginAttempts(t, ctx, 2*time.Hour)
+
+		// A client changing X-Real-IP on every attempt is still counted
+		// by the address it connects from
+		for i := 0; i < utils.DefaultLoginThrottle.MaxIPFailures; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login",
+				strings.NewReader(`{"username":"user`+strconv.Itoa(i)+`","password":"wrong"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderXRealIP, "198.51.100."+strconv.Itoa(i))
+			req.Header.Set("CF-Connecting-IP", "198.51.100."+strconv.Itoa(i))
+			req.RemoteAddr = "203.0.113.9:4321"
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		var attempt models.LoginAttempt
+		require.NoError(t, ctx.DB.Where("username = ?", "user0").Order("created_at DESC").First(&attempt).Error)
+		assert.Equal(t, "203.0.113.9", attempt.IPAddress)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login",
+			strings.NewReader(`{"username":"parent","password":"password123"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = "203.0.113.9:4321"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		// Nor can it get someone else's address throttled
+		rec = attemptLogin(e, "parent", "password123", "198.51.100.0")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestLoginHistory(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newSessionServer(ctx)
+	createPasswordUser(t, ctx, "parent", "password123")
+	createPasswordUser(t, ctx, "nanny", "password123")
+
+	attemptLogin(e, "parent", "wrong", "192.0.2.10")
+	attemptLogin(e, "nanny", "password123", "192.0.2.20")
+	attemptLogin(e, "nobody", "password123", "192.0.2.30")
+	cookie := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.40")
+
+	rec := cookieRequest(e, http.MethodGet, "/api/auth/logins", cookie)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var history []LoginAttemptResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	require.Len(t, history, 2)
+
+	results := map[string]LoginAttemptResponse{}
+	for _, entry := range history {
+		results[entry.Result] = entry
+	}
+	assert.Equal(t, "192.0.2.10", results["invalid_credentials"].IPAddress)
+	assert.Equal(t, "Test Browser", results["invalid_credentials"].UserAgent)
+	assert.Equal(t, "192.0.2.40", results["success"].IPAddress)
+	assert.Equal(t, "Laptop", results["success"].UserAgent)
+
+	t.Run("old history is purged", func(t *testing.T) {
+		ageLoginAttempts(t, ctx, 100*24*time.Hour)
+		require.NoError(t, utils.PurgeLoginAttempts(ctx.DB, time.Now().Add(-90*24*time.Hour)))
+
+		var count int64
+		ctx.DB.Model(&models.LoginAttempt{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestLoginRehashesWeakPasswords(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newSessionServer(ctx)
+	user := createPasswordUser(t, ctx, "parent", "password123")
+
+	weak, err := utils.HashPasswordWithParams("password123", utils.NewArgon2Params(8*1024, 1, 1))
+	require.NoError(t, err)
+	require.NoError(t, ctx.DB.Model(user).Update("password_hash", weak).Error)
+
+	loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+
+	var loaded models.User
+	require.NoError(t, ctx.DB.First(&loaded, "id = ?", user.ID).Error)
+	assert.NotEqual(t, weak, loaded.PasswordHash)
+	assert.False(t, utils.NeedsRehash(loaded.PasswordHash, utils.DefaultArgon2Params))
+	assert.Contains(t, loaded.PasswordHash, "m=65536,t=3,p=2")
+
+	// The new hash still works, and isn't replaced again
+	loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+
+	var again models.User
+	require.NoError(t, ctx.DB.First(&again, "id = ?", user.ID).Error)
+	assert.Equal(t, loaded.PasswordHash, again.PasswordHash)
+}
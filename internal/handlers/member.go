@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// MemberRequest represents the request body for adding a member to a baby
+type MemberRequest struct {
+	Username string `json:"username" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=owner caregiver viewer"`
+}
+
+// UpdateMemberRequest represents the request body for changing a member's role
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner caregiver viewer"`
+}
+
+// MemberResponse represents a user's membership of a baby
+type MemberResponse struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetBabyMembers handles GET /api/babies/:baby_id/members
+func GetBabyMembers(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleViewer)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	var members []models.BabyMember
+	if err := db.Preload("User").
+		Where("baby_id = ?", baby.ID).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch members")
+	}
+
+	response := make([]MemberResponse, len(members))
+	for i, member := range members {
+		response[i] = convertMemberToResponse(member)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// AddBabyMember handles POST /api/babies/:baby_id/members
+func AddBabyMember(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req MemberRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", strings.TrimSpace(req.Username)).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	var count int64
+	if err := db.Model(&models.BabyMember{}).
+		Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).
+		Count(&count).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "user is already a member of this baby")
+	}
+
+	member := models.BabyMember{
+		BabyID: baby.ID,
+		UserID: user.ID,
+		Role:   models.BabyRole(req.Role),
+	}
+	if err := db.Create(&member).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add member")
+	}
+	member.User = user
+
+	return c.JSON(http.StatusCreated, convertMemberToResponse(member))
+}
+
+// UpdateBabyMember handles PUT /api/babies/:baby_id/members/:user_id
+func UpdateBabyMember(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req UpdateMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	member, err := getMemberFromPath(c, db, baby.ID)
+	if err != nil {
+		return err
+	}
+
+	role := models.BabyRole(req.Role)
+	if member.Role == models.BabyRoleOwner && role != models.BabyRoleOwner {
+		if err := ensureAnotherOwner(db, baby.ID); err != nil {
+			return err
+		}
+	}
+
+	// The owner's choice takes over from the OpenID Connect role mapping
+	if err := db.Model(member).Updates(map[string]interface{}{
+		"role":            role,
+		"managed_by_oidc": false,
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update member")
+	}
+
+	return c.JSON(http.StatusOK, convertMemberToResponse(*member))
+}
+
+// RemoveBabyMember handles DELETE /api/babies/:baby_id/members/:user_id
+func RemoveBabyMember(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	member, err := getMemberFromPath(c, db, baby.ID)
+	if err != nil {
+		return err
+	}
+
+	if member.Role == models.BabyRoleOwner {
+		if err := ensureAnotherOwner(db, baby.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := db.Where("baby_id = ? AND user_id = ?", member.BabyID, member.UserID).
+		Delete(&models.BabyMember{}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove member")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "member removed successfully",
+	})
+}
+
+// getMemberFromPath loads the membership identified by the user_id path param
+func getMemberFromPath(c echo.Context, db *gorm.DB, babyID uuid.UUID) (*models.BabyMember, error) {
+	memberUserID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	var member models.BabyMember
+	if err := db.Preload("User").
+		Where("baby_id = ? AND user_id = ?", babyID, memberUserID).
+		First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "member not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	return &member, nil
+}
+
+// ensureAnotherOwner returns an error unless the baby has more than one owner,
+// so that a baby is never left without anyone able to manage it
+func ensureAnotherOwner(db *gorm.DB, babyID uuid.UUID) error {
+	var owners int64
+	if err := db.Model(&models.BabyMember{}).
+		Where("baby_id = ? AND role = ?", babyID, models.BabyRoleOwner).
+		Count(&owners).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+	if owners <= 1 {
+		return echo.NewHTTPError(http.StatusConflict, "a baby must have at least one owner")
+	}
+	return nil
+}
+
+// convertMemberToResponse converts a model to response format
+func convertMemberToResponse(member models.BabyMember) MemberResponse {
+	return MemberResponse{
+		UserID:    member.UserID.String(),
+		Username:  member.User.Username,
+		Role:      string(member.Role),
+		CreatedAt: member.CreatedAt,
+	}
+}
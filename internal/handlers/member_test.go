@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// addTestMember shares the test baby with a new user holding the given role
+func addTestMember(t *testing.T, ctx *TestContext, role models.BabyRole) *models.User {
+	t.Helper()
+
+	user := createTestUser(t, ctx.DB)
+	member := models.BabyMember{BabyID: ctx.Baby.ID, UserID: user.ID, Role: role}
+	require.NoError(t, ctx.DB.Create(&member).Error)
+
+	return user
+}
+
+// asUser switches the authenticated user on an echo context
+func asUser(c echo.Context, user *models.User) {
+	c.Set("user_id", user.ID.String())
+	c.Set("username", user.Username)
+}
+
+func TestBabyRoles(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	viewer := addTestMember(t, ctx, models.BabyRoleViewer)
+	caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+	activity := createTestActivity(t, ctx, "feed")
+
+	newFeed := ActivityRequest{
+		Type:      "feed",
+		StartTime: time.Now(),
+		FeedData:  &FeedData{FeedType: "bottle", AmountML: floatPtr(90)},
+	}
+
+	t.Run("shared baby is listed with role", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/babies", nil)
+		asUser(c, viewer)
+
+		err := GetBabies(c)
+		require.NoError(t, err)
+
+		var babies []BabyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &babies))
+		require.Len(t, babies, 1)
+		assert.Equal(t, ctx.Baby.ID.String(), babies[0].ID)
+		assert.Equal(t, "viewer", babies[0].Role)
+	})
+
+	t.Run("viewer can read activities", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/activities/"+activity.ID.String(), nil)
+		c.SetParamNames("id")
+		c.SetParamValues(activity.ID.String())
+		asUser(c, viewer)
+
+		err := GetActivity(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("viewer cannot create activities", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "POST", "/api/activities", newFeed)
+		asUser(c, viewer)
+
+		err := CreateActivity(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, httpError.Code)
+	})
+
+	t.Run("caregiver can create activities", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "POST", "/api/activities", newFeed)
+		asUser(c, caregiver)
+
+		err := CreateActivity(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("caregiver cannot delete activities", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "DELETE", "/api/activities/"+activity.ID.String(), nil)
+		c.SetParamNames("id")
+		c.SetParamValues(activity.ID.String())
+		asUser(c, caregiver)
+
+		err := DeleteActivity(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, httpError.Code)
+	})
+
+	t.Run("caregiver cannot edit the baby profile", func(t *testing.T) {
+		name := "Renamed"
+		c, _ := createEchoContext(ctx, "PUT", "/api/babies/"+ctx.Baby.ID.String(), UpdateBabyRequest{Name: &name})
+		c.SetParamNames("baby_id")
+		c.SetParamValues(ctx.Baby.ID.String())
+		asUser(c, caregiver)
+
+		err := UpdateBaby(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, httpError.Code)
+	})
+}
+
+func TestBabyMembers(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	partner := createTestUser(t, ctx.DB)
+	babyID := ctx.Baby.ID.String()
+
+	t.Run("add member", func(t *testing.T) {
+		req := MemberRequest{Username: partner.Username, Role: "caregiver"}
+		c, rec := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/members", req)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+
+		err := AddBabyMember(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response MemberResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, partner.Username, response.Username)
+		assert.Equal(t, "caregiver", response.Role)
+	})
+
+	t.Run("add existing member conflicts", func(t *testing.T) {
+		req := MemberRequest{Username: partner.Username, Role: "viewer"}
+		c, _ := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/members", req)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+
+		err := AddBabyMember(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusConflict, httpError.Code)
+	})
+
+	t.Run("list members", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/babies/"+babyID+"/members", nil)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+
+		err := GetBabyMembers(c)
+		require.NoError(t, err)
+
+		var members []MemberResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &members))
+		require.Len(t, members, 2)
+		assert.Equal(t, "owner", members[0].Role)
+		assert.Equal(t, ctx.User.Username, members[0].Username)
+	})
+
+	t.Run("non-owner cannot manage members", func(t *testing.T) {
+		req := MemberRequest{Username: ctx.User.Username, Role: "viewer"}
+		c, _ := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/members", req)
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+		asUser(c, partner)
+
+		err := AddBabyMember(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, httpError.Code)
+	})
+
+	t.Run("last owner cannot be demoted", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "PUT", "/api/babies/"+babyID+"/members/"+ctx.User.ID.String(),
+			UpdateMemberRequest{Role: "viewer"})
+		c.SetParamNames("baby_id", "user_id")
+		c.SetParamValues(babyID, ctx.User.ID.String())
+
+		err := UpdateBabyMember(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusConflict, httpError.Code)
+	})
+
+	t.Run("update member role", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "PUT", "/api/babies/"+babyID+"/members/"+partner.ID.String(),
+			UpdateMemberRequest{Role: "viewer"})
+		c.SetParamNames("baby_id", "user_id")
+		c.SetParamValues(babyID, partner.ID.String())
+
+		err := UpdateBabyMember(c)
+		require.NoError(t, err)
+
+		var response MemberResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "viewer", response.Role)
+	})
+
+	t.Run("remove member", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "DELETE", "/api/babies/"+babyID+"/members/"+partner.ID.String(), nil)
+		c.SetParamNames("baby_id", "user_id")
+		c.SetParamValues(babyID, partner.ID.String())
+
+		err := RemoveBabyMember(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		_, err = getBabyByIDForUser(ctx.DB, babyID, partner.ID.String())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/notify"
+)
+
+// testNotification is sent to check that a channel works
+var testNotification = notify.Message{
+	Title: "Test notification",
+	Body:  "Notifications from Bambino will arrive here.",
+}
+
+// NotificationChannelRequest represents the request body for adding a
+// notification channel. Settings depend on the type.
+type NotificationChannelRequest struct {
+	Type     string          `json:"type" validate:"required,oneof=webhook ntfy gotify email webpush"`
+	Name     string          `json:"name,omitempty" validate:"max=100"`
+	Settings json.RawMessage `json:"settings" validate:"required"`
+	Enabled  *bool           `json:"enabled,omitempty"`
+}
+
+// UpdateNotificationChannelRequest represents the request body for updating
+// a notification channel
+type UpdateNotificationChannelRequest struct {
+	Name     *string         `json:"name,omitempty" validate:"omitempty,max=100"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+	Enabled  *bool           `json:"enabled,omitempty"`
+}
+
+// NotificationChannelResponse represents a notification channel
+type NotificationChannelResponse struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Settings  json.RawMessage `json:"settings"`
+	Enabled   bool            `json:"enabled"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// NotificationSetupResponse tells clients which channels can be used, and
+// the key browsers need to subscribe to Web Push
+type NotificationSetupResponse struct {
+	ChannelTypes   []string `json:"channel_types"`
+	VAPIDPublicKey string   `json:"vapid_public_key,omitempty"`
+}
+
+// GetNotificationSetup handles GET /api/notifications
+func GetNotificationSetup(c echo.Context) error {
+	notifier, ok := c.Get("notifier").(*notify.Notifier)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "notifications unavailable")
+	}
+
+	response := NotificationSetupResponse{ChannelTypes: make([]string, 0)}
+	for _, t := range notifier.Types() {
+		response.ChannelTypes = append(response.ChannelTypes, string(t))
+	}
+	if cfg, ok := c.Get("config").(*config.Config); ok && cfg.WebPushEnabled() {
+		response.VAPIDPublicKey = cfg.VAPIDPublicKey
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetNotificationChannels handles GET /api/notifications/channels, listing
+// the user's channels
+func GetNotificationChannels(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if err := checkNotificationAccess(c); err != nil {
+		return err
+	}
+
+	var channels []models.NotificationChannel
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&channels).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch notification channels")
+	}
+
+	response := make([]NotificationChannelResponse, len(channels))
+	for i, channel := range channels {
+		response[i] = convertNotificationChannelToResponse(channel)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// CreateNotificationChannel handles POST /api/notifications/channels
+func CreateNotificationChannel(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	notifier, ok := c.Get("notifier").(*notify.Notifier)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "notifications unavailable")
+	}
+
+	if err := checkNotificationAccess(c); err != nil {
+		return err
+	}
+
+	var req NotificationChannelRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	channelType := models.NotificationChannelType(req.Type)
+	if err := notifier.Validate(channelType, req.Settings); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	channel := models.NotificationChannel{
+		UserID:   uid,
+		Type:     channelType,
+		Name:     req.Name,
+		Settings: string(req.Settings),
+	}
+	if channel.Name == "" {
+		channel.Name = req.Type
+	}
+	if err := db.Create(&channel).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create notification channel")
+	}
+
+	// GORM skips zero values that have a column default on insert,
+	// so an explicit "false" has to be written separately
+	if req.Enabled != nil && !*req.Enabled {
+		if err := db.Model(&channel).Update("enabled", false).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create notification channel")
+		}
+	}
+
+	return c.JSON(http.StatusCreated, convertNotificationChannelToResponse(channel))
+}
+
+// UpdateNotificationChannel handles PUT /api/notifications/channels/:id
+func UpdateNotificationChannel(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	notifier, ok := c.Get("notifier").(*notify.Notifier)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "notifications unavailable")
+	}
+
+	var req UpdateNotificationChannelRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	channel, err := findNotificationChannel(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil && *req.Name != "" {
+		updates["name"] = *req.Name
+	}
+	if req.Settings != nil {
+		if err := notifier.Validate(channel.Type, req.Settings); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		updates["settings"] = string(req.Settings)
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if len(updates) > 0 {
+		if err := db.Model(channel).Updates(updates).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update notification channel")
+		}
+	}
+
+	if err := db.First(channel, "id = ?", channel.ID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch notification channel")
+	}
+
+	return c.JSON(http.StatusOK, convertNotificationChannelToResponse(*channel))
+}
+
+// DeleteNotificationChannel handles DELETE /api/notifications/channels/:id
+func DeleteNotificationChannel(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	channel, err := findNotificationChannel(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Delete(channel).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete notification channel")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "notification channel deleted successfully",
+	})
+}
+
+// TestNotificationChannel handles POST /api/notifications/channels/:id/test,
+// sending a test notification through the channel whether it is enabled or
+// not. A failure to send is reported as a bad gateway.
+func TestNotificationChannel(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	notifier, ok := c.Get("notifier").(*notify.Notifier)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "notifications unavailable")
+	}
+
+	channel, err := findNotificationChannel(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := notifier.Send(c.Request().Context(), channel, testNotification); err != nil {
+		if errors.Is(err, notify.ErrChannelGone) {
+			// The channel can't be used again, so stop sending to it
+			if err := db.Model(channel).Update("enabled", false).Error; err != nil {
+				c.Logger().Errorf("failed to disable notification channel: %v", err)
+			}
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, "failed to send test notification: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "test notification sent",
+	})
+}
+
+// checkNotificationAccess stops API tokens limited to one baby from
+// managing notifications, which are not tied to a baby
+func checkNotificationAccess(c echo.Context) error {
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens limited to a baby cannot manage notifications")
+	}
+	return nil
+}
+
+// findNotificationChannel loads the user's notification channel named in
+// the request
+func findNotificationChannel(c echo.Context, db *gorm.DB, userID string) (*models.NotificationChannel, error) {
+	if err := checkNotificationAccess(c); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid notification channel ID")
+	}
+
+	var channel models.NotificationChannel
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&channel).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "notification channel not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch notification channel")
+	}
+	return &channel, nil
+}
+
+func convertNotificationChannelToResponse(channel models.NotificationChannel) NotificationChannelResponse {
+	return NotificationChannelResponse{
+		ID:        channel.ID.String(),
+		Type:      string(channel.Type),
+		Name:      channel.Name,
+		Settings:  json.RawMessage(channel.Settings),
+		Enabled:   channel.Enabled,
+		CreatedAt: channel.CreatedAt,
+		UpdatedAt: channel.UpdatedAt,
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/notify"
+)
+
+// withNotifier adds a notifier for the test's configuration to a context
+func withNotifier(c echo.Context, ctx *TestContext) echo.Context {
+	c.Set("notifier", notify.New(ctx.DB, ctx.Config))
+	return c
+}
+
+func createNotificationChannel(t *testing.T, ctx *TestContext, req NotificationChannelRequest) NotificationChannelResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "POST", "/api/notifications/channels", req)
+	require.NoError(t, CreateNotificationChannel(withNotifier(c, ctx)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response NotificationChannelResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func testNotificationChannel(ctx *TestContext, id string) error {
+	c, _ := createEchoContext(ctx, "POST", "/api/notifications/channels/"+id+"/test", nil)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return TestNotificationChannel(withNotifier(c, ctx))
+}
+
+func TestNotificationChannels(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	// The receiver listens on loopback
+	ctx.Config.OutboundAllowedNetworks = "127.0.0.1"
+	receiver := newWebhookReceiver(t)
+	var channel NotificationChannelResponse
+
+	t.Run("setup lists the channel types available", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/notifications", nil)
+		require.NoError(t, GetNotificationSetup(withNotifier(c, ctx)))
+
+		var setup NotificationSetupResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &setup))
+		assert.Equal(t, []string{"gotify", "ntfy", "webhook"}, setup.ChannelTypes)
+		assert.Empty(t, setup.VAPIDPublicKey)
+	})
+
+	t.Run("create and list", func(t *testing.T) {
+		channel = createNotificationChannel(t, ctx, NotificationChannelRequest{
+			Type:     "webhook",
+			Settings: json.RawMessage(`{"url":"` + receiver.URL + `"}`),
+		})
+		assert.Equal(t, "webhook", channel.Type)
+		assert.Equal(t, "webhook", channel.Name)
+		assert.True(t, channel.Enabled)
+
+		enabled := false
+		disabled := createNotificationChannel(t, ctx, NotificationChannelRequest{
+			Type:     "ntfy",
+			Name:     "Phone",
+			Settings: json.RawMessage(`{"url":"https://ntfy.sh/bambino"}`),
+			Enabled:  &enabled,
+		})
+		assert.False(t, disabled.Enabled)
+
+		c, rec := createEchoContext(ctx, "GET", "/api/notifications/channels", nil)
+		require.NoError(t, GetNotificationChannels(c))
+		var listed []NotificationChannelResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+		require.Len(t, listed, 2)
+		assert.Equal(t, channel.ID, listed[0].ID)
+		assert.False(t, listed[1].Enabled)
+	})
+
+	t.Run("invalid channels are refused", func(t *testing.T) {
+		for _, req := range []NotificationChannelRequest{
+			{Type: "pigeon", Settings: json.RawMessage(`{}`)},
+			{Type: "webhook", Settings: json.RawMessage(`{"url":"ftp://example.com"}`)},
+			{Type: "gotify", Settings: json.RawMessage(`{"url":"https://gotify.example.com"}`)},
+			{Type: "ntfy", Settings: json.RawMessage(`{"url":"http://10.0.0.5/bambino"}`)},
+			// Email isn't set up in the test configuration
+			{Type: "email", Settings: json.RawMessage(`{"address":"parent@example.com"}`)},
+		} {
+			c, _ := createEchoContext(ctx, "POST", "/api/notifications/channels", req)
+			err := CreateNotificationChannel(withNotifier(c, ctx))
+			require.Error(t, err, req.Type)
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, req.Type)
+		}
+	})
+
+	t.Run("test sends a notification", func(t *testing.T) {
+		require.NoError(t, testNotificationChannel(ctx, channel.ID))
+		require.Equal(t, 1, receiver.received())
+
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(receiver.bodies[0], &payload))
+		assert.Equal(t, testNotification.Title, payload["title"])
+	})
+
+	t.Run("test reports failures", func(t *testing.T) {
+		receiver.respondWith(http.StatusServiceUnavailable)
+		defer receiver.respondWith(http.StatusNoContent)
+
+		err := testNotificationChannel(ctx, channel.ID)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadGateway, err.(*echo.HTTPError).Code)
+		assert.Equal(t, "failed to send test notification: unexpected status 503", err.(*echo.HTTPError).Message)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "PUT", "/api/notifications/channels/"+channel.ID, map[string]interface{}{
+			"name":    "Home server",
+			"enabled": false,
+		})
+		c.SetParamNames("id")
+		c.SetParamValues(channel.ID)
+		require.NoError(t, UpdateNotificationChannel(withNotifier(c, ctx)))
+
+		var updated NotificationChannelResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, "Home server", updated.Name)
+		assert.False(t, updated.Enabled)
+		assert.JSONEq(t, `{"url":"`+receiver.URL+`"}`, string(updated.Settings))
+
+		c, _ = createEchoContext(ctx, "PUT", "/api/notifications/channels/"+channel.ID, map[string]interface{}{
+			"settings": map[string]string{"url": "mailto:parent@example.com"},
+		})
+		c.SetParamNames("id")
+		c.SetParamValues(channel.ID)
+		err := UpdateNotificationChannel(withNotifier(c, ctx))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("notify user only uses enabled channels", func(t *testing.T) {
+		before := receiver.received()
+		require.NoError(t, notify.New(ctx.DB, ctx.Config).NotifyUser(context.Background(), ctx.User.ID, testNotification))
+		assert.Equal(t, before, receiver.received())
+
+		// Test notifications still go to disabled channels
+		require.NoError(t, testNotificationChannel(ctx, channel.ID))
+		assert.Equal(t, before+1, receiver.received())
+	})
+
+	t.Run("other users can't see or use the channel", func(t *testing.T) {
+		other := createTestUser(t, ctx.DB)
+		c, _ := createEchoContext(ctx, "POST", "/api/notifications/channels/"+channel.ID+"/test", nil)
+		asUser(c, other)
+		c.SetParamNames("id")
+		c.SetParamValues(channel.ID)
+		err := TestNotificationChannel(withNotifier(c, ctx))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("tokens limited to a baby can't manage notifications", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "GET", "/api/notifications/channels", nil)
+		c.Set("api_token", &models.APIToken{BabyID: &ctx.Baby.ID})
+		err := GetNotificationChannels(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "DELETE", "/api/notifications/channels/"+channel.ID, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(channel.ID)
+		require.NoError(t, DeleteNotificationChannel(c))
+
+		var count int64
+		require.NoError(t, ctx.DB.Model(&models.NotificationChannel{}).Where("id = ?", channel.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// OIDCStatusResponse tells the login page whether to offer OIDC login
+type OIDCStatusResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// OIDCIdentityResponse represents an identity provider account linked to a user
+type OIDCIdentityResponse struct {
+	ID          string     `json:"id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// oidcProviders caches provider discovery, keyed by issuer URL
+var (
+	oidcProvidersMu sync.Mutex
+	oidcProviders   = map[string]*oidc.Provider{}
+)
+
+// GetOIDCStatus handles GET /api/auth/oidc
+func GetOIDCStatus(c echo.Context) error {
+	cfg, ok := c.Get("config").(*config.Config)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+
+	return c.JSON(http.StatusOK, OIDCStatusResponse{Enabled: cfg.OIDCEnabled()})
+}
+
+// OIDCLogin handles GET /api/auth/oidc/login, sending the browser to the
+// identity provider. With ?link=true, a logged in user links the provider
+// account to their existing account instead.
+func OIDCLogin(c echo.Context) error {
+	cfg, provider, err := oidcSetup(c)
+	if err != nil {
+		return err
+	}
+
+	keyPairs, err := cfg.SessionKeyPairs()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+
+	state := &utils.OIDCLoginState{Verifier: oauth2.GenerateVerifier()}
+	if state.State, err = utils.GenerateToken(utils.DefaultTokenLength); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start login")
+	}
+	if state.Nonce, err = utils.GenerateToken(utils.DefaultTokenLength); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start login")
+	}
+
+	if c.QueryParam("link") == "true" {
+		userID, _, err := utils.GetUserSession(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+		}
+		state.LinkUserID = userID.String()
+	}
+
+	if err := utils.SetOIDCLoginState(c, keyPairs, cfg.Env == "production", state); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start login")
+	}
+
+	authURL := oidcOAuth2Config(c, cfg, provider).AuthCodeURL(state.State,
+		oidc.Nonce(state.Nonce),
+		oauth2.S256ChallengeOption(state.Verifier),
+	)
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback handles GET /api/auth/oidc/callback, where the identity
+// provider sends the browser back with an authorization code
+func OIDCCallback(c echo.Context) error {
+	cfg, provider, err := oidcSetup(c)
+	if err != nil {
+		return err
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	keyPairs, err := cfg.SessionKeyPairs()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+
+	state, err := utils.PopOIDCLoginState(c, keyPairs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "login expired, please try again")
+	}
+	if subtle.ConstantTimeCompare([]byte(state.State), []byte(c.QueryParam("state"))) != 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid login state")
+	}
+	if c.QueryParam("error") != "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "login was denied by the identity provider")
+	}
+
+	ctx := c.Request().Context()
+	token, err := oidcOAuth2Config(c, cfg, provider).Exchange(ctx, c.QueryParam("code"),
+		oauth2.VerifierOption(state.Verifier),
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "failed to exchange authorization code")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "identity provider did not return an ID token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: cfg.OIDCClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid ID token")
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(state.Nonce)) != 1 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid ID token")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid ID token")
+	}
+
+	role, err := oidcRole(cfg, claims)
+	if err != nil {
+		return err
+	}
+
+	var user *models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = resolveOIDCUser(tx, cfg, idToken, claims, state.LinkUserID)
+		if err != nil {
+			return err
+		}
+		if role != "" {
+			return applyOIDCRole(tx, cfg, user.ID, role)
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(*echo.HTTPError); ok {
+			return err
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to log in")
+	}
+
+	attempt := newLoginAttempt(c, user.Username)
+	attempt.UserID = &user.ID
+	if err := recordLoginAttempt(db, attempt, models.LoginResultSuccess); err != nil {
+		return err
+	}
+
+	if err := utils.CreateUserSession(c, user.ID, user.Username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "session creation error")
+	}
+
+	return c.Redirect(http.StatusFound, "/")
+}
+
+// GetOIDCIdentities handles GET /api/auth/oidc/identities
+func GetOIDCIdentities(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var identities []models.OIDCIdentity
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch identities")
+	}
+
+	response := make([]OIDCIdentityResponse, len(identities))
+	for i, identity := range identities {
+		response[i] = OIDCIdentityResponse{
+			ID:          identity.ID.String(),
+			Issuer:      identity.Issuer,
+			Subject:     identity.Subject,
+			LastLoginAt: identity.LastLoginAt,
+			CreatedAt:   identity.CreatedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeleteOIDCIdentity handles DELETE /api/auth/oidc/identities/:id, unlinking
+// an identity provider account
+func DeleteOIDCIdentity(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot manage linked accounts")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid identity ID")
+	}
+
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.OIDCIdentity{})
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to unlink identity")
+	}
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "identity not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Identity unlinked successfully",
+	})
+}
+
+// oidcSetup returns the configuration and provider for an OIDC request
+func oidcSetup(c echo.Context) (*config.Config, *oidc.Provider, error) {
+	cfg, ok := c.Get("config").(*config.Config)
+	if !ok {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+	if !cfg.OIDCEnabled() {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, "OIDC login is not configured")
+	}
+
+	provider, err := getOIDCProvider(c.Request().Context(), cfg.OIDCIssuerURL)
+	if err != nil {
+		c.Logger().Errorf("OIDC discovery failed for %s: %v", cfg.OIDCIssuerURL, err)
+		return nil, nil, echo.NewHTTPError(http.StatusBadGateway, "identity provider is unavailable")
+	}
+	return cfg, provider, nil
+}
+
+// getOIDCProvider fetches the issuer's discovery document the first time it
+// is needed, so the server can start while the provider is down
+func getOIDCProvider(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+
+	if provider, ok := oidcProviders[issuer]; ok {
+		return provider, nil
+	}
+
+	// The provider keeps using its context to refresh signing keys, so
+	// don't tie it to the request
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), issuer)
+	if err != nil {
+		return nil, err
+	}
+	oidcProviders[issuer] = provider
+	return provider, nil
+}
+
+func oidcOAuth2Config(c echo.Context, cfg *config.Config, provider *oidc.Provider) *oauth2.Config {
+	redirectURL := cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		redirectURL = c.Scheme() + "://" + c.Request().Host + "/api/auth/oidc/callback"
+	}
+
+	return &oauth2.Config{
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       cfg.OIDCScopeList(),
+	}
+}
+
+// oidcRole returns the highest baby role granted by the role claim, or an
+// empty role if role mapping is off. Users the mapping grants nothing to are
+// refused.
+func oidcRole(cfg *config.Config, claims map[string]interface{}) (models.BabyRole, error) {
+	if cfg.OIDCRoleClaim == "" {
+		return "", nil
+	}
+
+	mapping, err := cfg.OIDCRoles()
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+
+	var values []string
+	switch claim := claims[cfg.OIDCRoleClaim].(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var role models.BabyRole
+	for _, value := range values {
+		mapped := models.BabyRole(mapping[value])
+		if mapped.Valid() && !role.Includes(mapped) {
+			role = mapped
+		}
+	}
+	if role == "" {
+		return "", echo.NewHTTPError(http.StatusForbidden, "your account is not allowed to use this app")
+	}
+	return role, nil
+}
+
+// resolveOIDCUser returns the user linked to the ID token's subject. An
+// unknown subject is linked to linkUserID if set, and otherwise gets a new
+// user if auto-provisioning is on.
+func resolveOIDCUser(tx *gorm.DB, cfg *config.Config, idToken *oidc.IDToken, claims map[string]interface{}, linkUserID string) (*models.User, error) {
+	now := time.Now()
+
+	var identity models.OIDCIdentity
+	err := tx.Preload("User").
+		Where("issuer = ? AND subject = ?", idToken.Issuer, idToken.Subject).
+		First(&identity).Error
+	if err == nil {
+		if linkUserID != "" && identity.UserID.String() != linkUserID {
+			return nil, echo.NewHTTPError(http.StatusConflict, "this account is already linked to another user")
+		}
+		if err := tx.Model(&identity).UpdateColumn("last_login_at", now).Error; err != nil {
+			return nil, err
+		}
+		return &identity.User, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	var user models.User
+	if linkUserID != "" {
+		if err := tx.First(&user, "id = ?", linkUserID).Error; err != nil {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		}
+	} else {
+		if !cfg.OIDCAutoProvision {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "no account is linked to this identity")
+		}
+
+		username := oidcUsername(cfg, claims)
+		if username == "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "identity provider did not return a username")
+		}
+		// Refused rather than cut short, as the proxy login does
+		if len(username) > 50 {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "username from the identity provider is too long")
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+			return nil, err
+		}
+		if taken > 0 {
+			// Linking by username would let anyone who can pick that name at
+			// the provider take over the account
+			return nil, echo.NewHTTPError(http.StatusConflict, "username is already taken, log in and link your account instead")
+		}
+
+		// The provider handles logins
+		hash, err := utils.NewUnusablePasswordHash()
+		if err != nil {
+			return nil, err
+		}
+
+		user = models.User{Username: username, PasswordHash: hash}
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	identity = models.OIDCIdentity{
+		UserID:      user.ID,
+		Issuer:      idToken.Issuer,
+		Subject:     idToken.Subject,
+		LastLoginAt: &now,
+	}
+	if err := tx.Create(&identity).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// oidcUsername picks a username from the configured claim, falling back to
+// the email address
+func oidcUsername(cfg *config.Config, claims map[string]interface{}) string {
+	for _, name := range []string{cfg.OIDCUsernameClaim, "email"} {
+		if value, ok := claims[name].(string); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// applyOIDCRole gives the user the mapped role on the babies listed in
+// OIDC_ROLE_BABIES, adding them where they aren't a member yet. Memberships
+// the mapping granted follow the provider from then on, but a role an owner
+// gave through an invite or the member API is left alone, as are babies that
+// aren't listed. The last owner of a baby is never demoted.
+func applyOIDCRole(tx *gorm.DB, cfg *config.Config, userID uuid.UUID, role models.BabyRole) error {
+	babyIDs, err := cfg.OIDCRoleBabyIDs()
+	if err != nil || len(babyIDs) == 0 {
+		return err
+	}
+
+	var babies []models.Baby
+	if err := tx.Where("id IN ?", babyIDs).Find(&babies).Error; err != nil {
+		return err
+	}
+
+	for _, baby := range babies {
+		var member models.BabyMember
+		err := tx.Where("baby_id = ? AND user_id = ?", baby.ID, userID).First(&member).Error
+		if err == gorm.ErrRecordNotFound {
+			member = models.BabyMember{BabyID: baby.ID, UserID: userID, Role: role, ManagedByOIDC: true}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !member.ManagedByOIDC || member.Role == role {
+			continue
+		}
+		if member.Role == models.BabyRoleOwner {
+			if err := ensureAnotherOwner(tx, baby.ID); err != nil {
+				continue
+			}
+		}
+		if err := tx.Model(&member).Update("role", role).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+const stubClientID = "bambino-test"
+
+// stubIssuer is a minimal OpenID Connect provider serving discovery, JWKS,
+// authorization and token endpoints. The authorization endpoint logs in
+// whoever the test set with nextClaims.
+type stubIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu         sync.Mutex
+	nextClaims map[string]interface{}
+	nextNonce  string
+	grants     map[string]stubGrant
+}
+
+type stubGrant struct {
+	challenge   string
+	redirectURI string
+	claims      map[string]interface{}
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	s := &stubIssuer{t: t, key: key, grants: map[string]stubGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+func (s *stubIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeStubJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.server.URL,
+		"authorization_endpoint":                s.server.URL + "/authorize",
+		"token_endpoint":                        s.server.URL + "/token",
+		"jwks_uri":                              s.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *stubIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeStubJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *stubIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	assert.Equal(s.t, "code", q.Get("response_type"))
+	assert.Equal(s.t, stubClientID, q.Get("client_id"))
+	assert.Equal(s.t, "S256", q.Get("code_challenge_method"))
+	assert.Contains(s.t, strings.Fields(q.Get("scope")), "openid")
+
+	s.mu.Lock()
+	claims := map[string]interface{}{}
+	for k, v := range s.nextClaims {
+		claims[k] = v
+	}
+	claims["nonce"] = q.Get("nonce")
+	if s.nextNonce != "" {
+		claims["nonce"] = s.nextNonce
+	}
+
+	code := "code-" + time.Now().Format("150405.000000000")
+	s.grants[code] = stubGrant{
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+		claims:      claims,
+	}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	require.NoError(s.t, err)
+	params := url.Values{"code": {code}, "state": {q.Get("state")}}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *stubIssuer) token(w http.ResponseWriter, r *http.Request) {
+	require.NoError(s.t, r.ParseForm())
+
+	s.mu.Lock()
+	grant, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !ok || r.PostForm.Get("redirect_uri") != grant.redirectURI {
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	// PKCE: the verifier must hash to the challenge sent to /authorize
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": s.server.URL,
+		"aud": stubClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+
+	writeStubJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     s.sign(claims),
+	})
+}
+
+// sign returns claims as an RS256 JWT
+func (s *stubIssuer) sign(claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	require.NoError(s.t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(s.t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	require.NoError(s.t, err)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeStubJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// oidcLogin goes through the login flow as the user described by claims. With
+// a session cookie, the identity is linked to that user instead.
+func oidcLogin(t *testing.T, e *echo.Echo, issuer *stubIssuer, claims map[string]interface{}, session *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	path := "/api/auth/oidc/login"
+	if session != nil {
+		path += "?link=true"
+	}
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if session != nil {
+		req.AddCookie(session)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+
+	var stateCookie *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == utils.OIDCStateCookieName {
+			stateCookie = cookie
+		}
+	}
+	require.NotNil(t, stateCookie)
+
+	// Log in at the provider, which redirects back with a code
+	issuer.mu.Lock()
+	issuer.nextClaims = claims
+	issuer.mu.Unlock()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rec.Header().Get(echo.HeaderLocation))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get(echo.HeaderLocation))
+	require.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	req.AddCookie(stateCookie)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOIDCLogin(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	issuer := newStubIssuer(t)
+	ctx.Config.SessionSecret = "test-session-secret"
+	ctx.Config.OIDCIssuerURL = issuer.server.URL
+	ctx.Config.OIDCClientID = stubClientID
+	ctx.Config.OIDCClientSecret = "client-secret"
+	ctx.Config.OIDCScopes = "openid profile email"
+	ctx.Config.OIDCUsernameClaim = "preferred_username"
+	ctx.Config.OIDCRoleClaim = "groups"
+	ctx.Config.OIDCRoleMapping = "parents=owner,family=caregiver,friends=viewer"
+	ctx.Config.OIDCRoleBabies = ctx.Baby.ID.String()
+	ctx.Config.OIDCAutoProvision = true
+
+	// A baby from another household, which the provider doesn't manage
+	neighbour := createTestUser(t, ctx.DB)
+	otherBaby := &models.Baby{UserID: neighbour.ID, Name: "Neighbour's Baby", BirthDate: time.Now().AddDate(0, -2, 0)}
+	require.NoError(t, ctx.DB.Create(otherBaby).Error)
+
+	e := newSessionServer(ctx)
+
+	babyRole := func(t *testing.T, username string) models.BabyRole {
+		t.Helper()
+		var member models.BabyMember
+		require.NoError(t, ctx.DB.Joins("User").
+			Where("baby_id = ? AND User.username = ?", ctx.Baby.ID, username).
+			First(&member).Error)
+		return member.Role
+	}
+
+	t.Run("new user is provisioned with the mapped role", func(t *testing.T) {
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-1",
+			"preferred_username": "grandma",
+			"groups":             []string{"book-club", "family"},
+		}, nil)
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		assert.Equal(t, "/", rec.Header().Get(echo.HeaderLocation))
+
+		cookie := responseCookie(rec)
+		require.NotNil(t, cookie)
+		assert.Equal(t, http.StatusOK, cookieRequest(e, http.MethodGet, "/api/auth/me", cookie).Code)
+
+		assert.Equal(t, models.BabyRoleCaregiver, babyRole(t, "grandma"))
+
+		var identity models.OIDCIdentity
+		require.NoError(t, ctx.DB.Where("subject = ?", "subject-1").First(&identity).Error)
+		assert.Equal(t, issuer.server.URL, identity.Issuer)
+	})
+
+	t.Run("returning user is found by subject and role follows the claim", func(t *testing.T) {
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-1",
+			"preferred_username": "renamed-at-provider",
+			"groups":             "friends",
+		}, nil)
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+
+		var count int64
+		ctx.DB.Model(&models.User{}).Where("username = ?", "renamed-at-provider").Count(&count)
+		assert.Equal(t, int64(0), count)
+		assert.Equal(t, models.BabyRoleViewer, babyRole(t, "grandma"))
+	})
+
+	t.Run("babies that aren't listed are left alone", func(t *testing.T) {
+		var count int64
+		require.NoError(t, ctx.DB.Model(&models.BabyMember{}).Where("baby_id = ?", otherBaby.ID).Count(&count).Error)
+		assert.Equal(t, int64(1), count, "only the neighbour belongs to their baby")
+	})
+
+	t.Run("a role given by an owner is kept", func(t *testing.T) {
+		var grandma models.User
+		require.NoError(t, ctx.DB.Where("username = ?", "grandma").First(&grandma).Error)
+
+		babyID := ctx.Baby.ID.String()
+		c, _ := createEchoContext(ctx, "PUT", "/api/babies/"+babyID+"/members/"+grandma.ID.String(),
+			UpdateMemberRequest{Role: "caregiver"})
+		c.SetParamNames("baby_id", "user_id")
+		c.SetParamValues(babyID, grandma.ID.String())
+		require.NoError(t, UpdateBabyMember(c))
+
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-1",
+			"preferred_username": "grandma",
+			"groups":             "friends",
+		}, nil)
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		assert.Equal(t, models.BabyRoleCaregiver, babyRole(t, "grandma"))
+	})
+
+	t.Run("user without a mapped role is refused", func(t *testing.T) {
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-2",
+			"preferred_username": "stranger",
+			"groups":             []string{"book-club"},
+		}, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Nil(t, responseCookie(rec))
+
+		var count int64
+		ctx.DB.Model(&models.User{}).Where("username = ?", "stranger").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("usernames that are too long are refused", func(t *testing.T) {
+		// 26 characters, but 52 bytes
+		long := strings.Repeat("é", 26)
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-long",
+			"preferred_username": long,
+			"groups":             []string{"parents"},
+		}, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var count int64
+		ctx.DB.Model(&models.User{}).Where("username LIKE ?", "é%").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("existing username is not taken over", func(t *testing.T) {
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-3",
+			"preferred_username": ctx.User.Username,
+			"groups":             []string{"parents"},
+		}, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Nil(t, responseCookie(rec))
+	})
+
+	t.Run("ID token with the wrong nonce is rejected", func(t *testing.T) {
+		issuer.mu.Lock()
+		issuer.nextNonce = "replayed-nonce"
+		issuer.mu.Unlock()
+		defer func() {
+			issuer.mu.Lock()
+			issuer.nextNonce = ""
+			issuer.mu.Unlock()
+		}()
+
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":    "subject-1",
+			"groups": []string{"family"},
+		}, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("callback with a forged state is rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusFound, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code=anything&state=forged", nil)
+		for _, cookie := range rec.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("logged in user links and unlinks an identity", func(t *testing.T) {
+		createPasswordUser(t, ctx, "parent", "password123")
+		session := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+
+		rec := oidcLogin(t, e, issuer, map[string]interface{}{
+			"sub":                "subject-4",
+			"preferred_username": "parent-at-provider",
+			"groups":             []string{"parents"},
+		}, session)
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		cookie := responseCookie(rec)
+		require.NotNil(t, cookie)
+
+		assert.Equal(t, models.BabyRoleOwner, babyRole(t, "parent"))
+
+		rec = cookieRequest(e, http.MethodGet, "/api/auth/oidc/identities", cookie)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var identities []OIDCIdentityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &identities))
+		require.Len(t, identities, 1)
+		assert.Equal(t, "subject-4", identities[0].Subject)
+
+		rec = cookieRequest(e, http.MethodDelete, "/api/auth/oidc/identities/"+identities[0].ID, cookie)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var count int64
+		ctx.DB.Model(&models.OIDCIdentity{}).Where("subject = ?", "subject-4").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("not found when OIDC is not configured", func(t *testing.T) {
+		ctx.Config.OIDCIssuerURL = ""
+		defer func() { ctx.Config.OIDCIssuerURL = issuer.server.URL }()
+
+		rec := cookieRequest(e, http.MethodGet, "/api/auth/oidc/login", &http.Cookie{Name: "unused"})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
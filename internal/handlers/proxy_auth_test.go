@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// newFakeAuthProxy starts the API behind a reverse proxy that, like an SSO
+// gateway, sets the Remote-User header to the logged in user
+func newFakeAuthProxy(t *testing.T, ctx *TestContext, username string) *httptest.Server {
+	t.Helper()
+
+	app := httptest.NewServer(newAPIServer(ctx))
+	t.Cleanup(app.Close)
+
+	target, err := url.Parse(app.URL)
+	require.NoError(t, err)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Header.Set("Remote-User", username)
+	}
+
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getCurrentUsername(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url + "/api/auth/me")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		Username string `json:"username"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	}
+	return resp.StatusCode, body.Username
+}
+
+func TestProxyAuth(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	ctx.Config.AuthProxyHeader = "Remote-User"
+	ctx.Config.AuthProxyTrustedProxies = "127.0.0.1/32, ::1"
+	ctx.Config.AuthProxyAutoProvision = true
+
+	t.Run("existing user is logged in by the proxy", func(t *testing.T) {
+		proxy := newFakeAuthProxy(t, ctx, ctx.User.Username)
+
+		code, username := getCurrentUsername(t, proxy.URL)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, ctx.User.Username, username)
+	})
+
+	t.Run("unknown user is provisioned", func(t *testing.T) {
+		proxy := newFakeAuthProxy(t, ctx, "grandma")
+
+		code, username := getCurrentUsername(t, proxy.URL)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "grandma", username)
+
+		// A second request reuses the same account
+		code, _ = getCurrentUsername(t, proxy.URL)
+		require.Equal(t, http.StatusOK, code)
+
+		var count int64
+		ctx.DB.Model(&models.User{}).Where("username = ?", "grandma").Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("header from an untrusted address is ignored", func(t *testing.T) {
+		e := newAPIServer(ctx)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.RemoteAddr = "203.0.113.9:4321"
+		req.Header.Set("Remote-User", ctx.User.Username)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		// Forwarding headers don't make a client look like the proxy
+		req.Header.Set("X-Forwarded-For", "127.0.0.1")
+		req.Header.Set("X-Real-IP", "127.0.0.1")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("unknown user is rejected without auto-provisioning", func(t *testing.T) {
+		ctx.Config.AuthProxyAutoProvision = false
+		defer func() { ctx.Config.AuthProxyAutoProvision = true }()
+
+		proxy := newFakeAuthProxy(t, ctx, "stranger")
+
+		code, _ := getCurrentUsername(t, proxy.URL)
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		var count int64
+		ctx.DB.Model(&models.User{}).Where("username = ?", "stranger").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("header is ignored when proxy auth is off", func(t *testing.T) {
+		ctx.Config.AuthProxyHeader = ""
+		defer func() { ctx.Config.AuthProxyHeader = "Remote-User" }()
+
+		proxy := newFakeAuthProxy(t, ctx, ctx.User.Username)
+
+		code, _ := getCurrentUsername(t, proxy.URL)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+}
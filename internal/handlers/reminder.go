@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/reminders"
+)
+
+// ReminderRequest represents the request body for creating a reminder. A
+// reminder either has a cron schedule, or falls due delay_minutes after the
+// latest activity of activity_type starts.
+type ReminderRequest struct {
+	BabyID       string `json:"baby_id,omitempty" validate:"omitempty,uuid"`
+	Title        string `json:"title" validate:"required,max=100"`
+	Notes        string `json:"notes,omitempty" validate:"max=1000"`
+	Schedule     string `json:"schedule,omitempty" validate:"max=100"`
+	TimeZone     string `json:"time_zone,omitempty" validate:"max=64"`
+	ActivityType string `json:"activity_type,omitempty" validate:"omitempty,oneof=feed pump diaper sleep growth health milestone"`
+	DelayMinutes int    `json:"delay_minutes,omitempty" validate:"omitempty,min=1,max=10080"`
+	Enabled      *bool  `json:"enabled,omitempty"`
+}
+
+// UpdateReminderRequest represents the request body for updating a
+// reminder. An empty schedule or zero delay_minutes clears that rule.
+type UpdateReminderRequest struct {
+	Title        *string `json:"title,omitempty" validate:"omitempty,max=100"`
+	Notes        *string `json:"notes,omitempty" validate:"omitempty,max=1000"`
+	Schedule     *string `json:"schedule,omitempty" validate:"omitempty,max=100"`
+	TimeZone     *string `json:"time_zone,omitempty" validate:"omitempty,max=64"`
+	ActivityType *string `json:"activity_type,omitempty" validate:"omitempty,oneof=feed pump diaper sleep growth health milestone"`
+	DelayMinutes *int    `json:"delay_minutes,omitempty" validate:"omitempty,min=0,max=10080"`
+	Enabled      *bool   `json:"enabled,omitempty"`
+}
+
+// SnoozeReminderRequest represents the request body for snoozing a reminder
+type SnoozeReminderRequest struct {
+	Minutes int `json:"minutes,omitempty" validate:"omitempty,min=1,max=1440"`
+}
+
+// ReminderResponse represents a reminder. NextDueAt is when it will next be
+// sent, and is empty while it waits for an activity; Paused is set while a
+// timer for its activity is running.
+type ReminderResponse struct {
+	ID             string     `json:"id"`
+	BabyID         string     `json:"baby_id"`
+	Title          string     `json:"title"`
+	Notes          string     `json:"notes,omitempty"`
+	Schedule       string     `json:"schedule,omitempty"`
+	TimeZone       string     `json:"time_zone,omitempty"`
+	ActivityType   string     `json:"activity_type,omitempty"`
+	DelayMinutes   int        `json:"delay_minutes,omitempty"`
+	Enabled        bool       `json:"enabled"`
+	NextDueAt      *time.Time `json:"next_due_at"`
+	Paused         bool       `json:"paused"`
+	SnoozedUntil   *time.Time `json:"snoozed_until,omitempty"`
+	LastSentAt     *time.Time `json:"last_sent_at,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// CreateReminder handles POST /api/reminders. Reminders go to the user who
+// creates them, so any member of a baby may set them.
+func CreateReminder(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req ReminderRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, req.BabyID, models.BabyRoleViewer)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	reminder := models.Reminder{
+		UserID:       uid,
+		BabyID:       baby.ID,
+		Title:        req.Title,
+		Notes:        req.Notes,
+		Schedule:     req.Schedule,
+		TimeZone:     req.TimeZone,
+		ActivityType: models.ActivityType(req.ActivityType),
+		DelayMinutes: req.DelayMinutes,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if err := scheduleReminder(&reminder); err != nil {
+		return err
+	}
+
+	if err := db.Create(&reminder).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create reminder")
+	}
+
+	// GORM skips zero values that have a column default on insert,
+	// so an explicit "false" has to be written separately
+	if !reminder.Enabled {
+		if err := db.Model(&reminder).Update("enabled", false).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create reminder")
+		}
+	}
+
+	return respondWithReminder(c, db, http.StatusCreated, &reminder)
+}
+
+// GetReminders handles GET /api/reminders, listing the user's reminders
+func GetReminders(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	query := db.Where("user_id = ?", userID)
+
+	// API tokens limited to one baby cannot see the others
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		query = query.Where("baby_id = ?", *token.BabyID)
+	}
+
+	if babyID := c.QueryParam("baby_id"); babyID != "" {
+		id, err := uuid.Parse(babyID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid baby ID")
+		}
+		query = query.Where("baby_id = ?", id)
+	}
+
+	var list []models.Reminder
+	if err := query.Order("created_at ASC").Find(&list).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reminders")
+	}
+
+	response := make([]ReminderResponse, len(list))
+	for i := range list {
+		r, err := convertReminderToResponse(db, &list[i])
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reminders")
+		}
+		response[i] = r
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// UpdateReminder handles PUT /api/reminders/:id. Clearing the schedule and
+// setting delay_minutes, or the other way round, changes the kind of
+// reminder.
+func UpdateReminder(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req UpdateReminderRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	reminder, err := findReminder(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	if req.Title != nil && *req.Title != "" {
+		reminder.Title = *req.Title
+	}
+	if req.Notes != nil {
+		reminder.Notes = *req.Notes
+	}
+	if req.Schedule != nil {
+		reminder.Schedule = *req.Schedule
+	}
+	if req.TimeZone != nil {
+		reminder.TimeZone = *req.TimeZone
+	}
+	if req.ActivityType != nil {
+		reminder.ActivityType = models.ActivityType(*req.ActivityType)
+	}
+	if req.DelayMinutes != nil {
+		reminder.DelayMinutes = *req.DelayMinutes
+	}
+	if req.Enabled != nil {
+		// A reminder switched back on only follows activities from then on
+		if *req.Enabled && !reminder.Enabled {
+			reminder.EnabledAt = time.Now()
+		}
+		reminder.Enabled = *req.Enabled
+	}
+
+	// Work out the next run afresh, so that a changed schedule, or one
+	// switched back on, doesn't send for times that have already passed
+	if err := scheduleReminder(reminder); err != nil {
+		return err
+	}
+
+	if err := db.Model(reminder).
+		Select("title", "notes", "schedule", "time_zone", "activity_type", "delay_minutes", "enabled", "enabled_at", "next_run_at").
+		Updates(reminder).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update reminder")
+	}
+
+	return respondWithReminder(c, db, http.StatusOK, reminder)
+}
+
+// DeleteReminder handles DELETE /api/reminders/:id
+func DeleteReminder(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	reminder, err := findReminder(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Delete(reminder).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete reminder")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "reminder deleted successfully",
+	})
+}
+
+// AcknowledgeReminder handles POST /api/reminders/:id/ack, marking the
+// last reminder sent as dealt with and dropping any snooze
+func AcknowledgeReminder(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	reminder, err := findReminder(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	if reminder.LastSentAt == nil {
+		return echo.NewHTTPError(http.StatusConflict, "reminder has not been sent yet")
+	}
+
+	if err := db.Model(reminder).Updates(map[string]interface{}{
+		"acknowledged_at": time.Now(),
+		"snoozed_until":   nil,
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to acknowledge reminder")
+	}
+
+	return respondWithReminder(c, db, http.StatusOK, reminder)
+}
+
+// SnoozeReminder handles POST /api/reminders/:id/snooze, sending the
+// reminder again after the given number of minutes, or 15 by default. A
+// snooze takes the place of the reminder's next run until it is sent.
+func SnoozeReminder(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req SnoozeReminderRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	reminder, err := findReminder(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	if !reminder.Enabled {
+		return echo.NewHTTPError(http.StatusConflict, "reminder is disabled")
+	}
+
+	snooze := reminders.DefaultSnooze
+	if req.Minutes > 0 {
+		snooze = time.Duration(req.Minutes) * time.Minute
+	}
+
+	if err := db.Model(reminder).Updates(map[string]interface{}{
+		"snoozed_until":   time.Now().Add(snooze),
+		"acknowledged_at": nil,
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to snooze reminder")
+	}
+
+	return respondWithReminder(c, db, http.StatusOK, reminder)
+}
+
+// scheduleReminder checks a reminder's rule and works out its next run
+func scheduleReminder(reminder *models.Reminder) error {
+	if reminder.TimeZone == "" {
+		reminder.TimeZone = "UTC"
+	}
+
+	switch {
+	case reminder.Schedule != "" && reminder.DelayMinutes > 0:
+		return echo.NewHTTPError(http.StatusBadRequest, "a reminder can have a schedule or delay_minutes, not both")
+	case reminder.Schedule == "" && reminder.DelayMinutes == 0:
+		return echo.NewHTTPError(http.StatusBadRequest, "a reminder needs a schedule or delay_minutes")
+	case reminder.FollowsActivity() && reminder.ActivityType == "":
+		return echo.NewHTTPError(http.StatusBadRequest, "activity_type is required with delay_minutes")
+	}
+
+	next, err := reminders.NextRun(reminder, time.Now())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	reminder.NextRunAt = next
+	return nil
+}
+
+// findReminder loads the user's reminder named in the request
+func findReminder(c echo.Context, db *gorm.DB, userID string) (*models.Reminder, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid reminder ID")
+	}
+
+	query := db.Where("id = ? AND user_id = ?", id, userID)
+
+	// API tokens limited to one baby cannot reach the others
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		query = query.Where("baby_id = ?", *token.BabyID)
+	}
+
+	var reminder models.Reminder
+	if err := query.First(&reminder).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "reminder not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reminder")
+	}
+	return &reminder, nil
+}
+
+// respondWithReminder reloads a reminder and writes it out
+func respondWithReminder(c echo.Context, db *gorm.DB, status int, reminder *models.Reminder) error {
+	// Load into a fresh reminder, as GORM leaves fields that are now NULL
+	// as they were
+	var saved models.Reminder
+	if err := db.First(&saved, "id = ?", reminder.ID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reminder")
+	}
+
+	response, err := convertReminderToResponse(db, &saved)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reminder")
+	}
+	return c.JSON(status, response)
+}
+
+func convertReminderToResponse(db *gorm.DB, reminder *models.Reminder) (ReminderResponse, error) {
+	due, err := reminders.DueAt(db, reminder)
+	if err != nil {
+		return ReminderResponse{}, err
+	}
+	paused, err := reminders.Paused(db, reminder)
+	if err != nil {
+		return ReminderResponse{}, err
+	}
+
+	response := ReminderResponse{
+		ID:             reminder.ID.String(),
+		BabyID:         reminder.BabyID.String(),
+		Title:          reminder.Title,
+		Notes:          reminder.Notes,
+		Schedule:       reminder.Schedule,
+		ActivityType:   string(reminder.ActivityType),
+		DelayMinutes:   reminder.DelayMinutes,
+		Enabled:        reminder.Enabled,
+		NextDueAt:      due,
+		Paused:         paused,
+		SnoozedUntil:   reminder.SnoozedUntil,
+		LastSentAt:     reminder.LastSentAt,
+		AcknowledgedAt: reminder.AcknowledgedAt,
+		CreatedAt:      reminder.CreatedAt,
+		UpdatedAt:      reminder.UpdatedAt,
+	}
+	if !reminder.FollowsActivity() {
+		response.TimeZone = reminder.TimeZone
+	}
+	return response, nil
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+func createReminder(t *testing.T, ctx *TestContext, req ReminderRequest) ReminderResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "POST", "/api/reminders", req)
+	require.NoError(t, CreateReminder(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response ReminderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+// reminderAction runs a handler for a reminder, returning the reminder it
+// responds with
+func reminderAction(ctx *TestContext, handler echo.HandlerFunc, method, id string, body interface{}) (ReminderResponse, error) {
+	c, rec := createEchoContext(ctx, method, "/api/reminders/"+id, body)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+
+	var response ReminderResponse
+	if err := handler(c); err != nil {
+		return response, err
+	}
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	return response, err
+}
+
+func TestReminders(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	var scheduled, feed ReminderResponse
+
+	t.Run("create a scheduled reminder", func(t *testing.T) {
+		scheduled = createReminder(t, ctx, ReminderRequest{
+			BabyID:   ctx.Baby.ID.String(),
+			Title:    "Vitamin D",
+			Schedule: "0 9 * * *",
+			TimeZone: "Africa/Lusaka",
+		})
+		assert.True(t, scheduled.Enabled)
+		assert.Equal(t, "Africa/Lusaka", scheduled.TimeZone)
+		require.NotNil(t, scheduled.NextDueAt)
+		assert.True(t, scheduled.NextDueAt.After(time.Now()))
+		assert.Equal(t, 7, scheduled.NextDueAt.UTC().Hour())
+	})
+
+	t.Run("create a reminder that follows feeds", func(t *testing.T) {
+		feed = createReminder(t, ctx, ReminderRequest{
+			BabyID:       ctx.Baby.ID.String(),
+			Title:        "Feed due",
+			ActivityType: "feed",
+			DelayMinutes: 180,
+		})
+		assert.Nil(t, feed.NextDueAt)
+		assert.False(t, feed.Paused)
+
+		// A feed with its timer still running
+		activity := &models.Activity{BabyID: ctx.Baby.ID, Type: models.ActivityTypeFeed, StartTime: time.Now()}
+		require.NoError(t, ctx.DB.Create(activity).Error)
+
+		c, rec := createEchoContext(ctx, "GET", "/api/reminders", nil)
+		require.NoError(t, GetReminders(c))
+		var listed []ReminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+		require.Len(t, listed, 2)
+		assert.Equal(t, feed.ID, listed[1].ID)
+		assert.True(t, listed[1].Paused)
+		require.NotNil(t, listed[1].NextDueAt)
+		assert.WithinDuration(t, activity.StartTime.Add(3*time.Hour), *listed[1].NextDueAt, time.Second)
+	})
+
+	t.Run("invalid rules are refused", func(t *testing.T) {
+		for name, req := range map[string]ReminderRequest{
+			"no rule":           {Title: "Nothing"},
+			"both rules":        {Title: "Both", Schedule: "@daily", ActivityType: "feed", DelayMinutes: 60},
+			"no activity type":  {Title: "Feed due", DelayMinutes: 60},
+			"invalid schedule":  {Title: "Vitamin D", Schedule: "every morning"},
+			"unknown time zone": {Title: "Vitamin D", Schedule: "0 9 * * *", TimeZone: "Mars/Olympus_Mons"},
+		} {
+			req.BabyID = ctx.Baby.ID.String()
+			c, _ := createEchoContext(ctx, "POST", "/api/reminders", req)
+			err := CreateReminder(c)
+			require.Error(t, err, name)
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, name)
+		}
+	})
+
+	t.Run("update changes the rule", func(t *testing.T) {
+		schedule, zero := "30 8 * * 1-5", 0
+		updated, err := reminderAction(ctx, UpdateReminder, "PUT", feed.ID, UpdateReminderRequest{
+			Schedule:     &schedule,
+			DelayMinutes: &zero,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, schedule, updated.Schedule)
+		assert.Zero(t, updated.DelayMinutes)
+		require.NotNil(t, updated.NextDueAt)
+		assert.Equal(t, 30, updated.NextDueAt.Minute())
+
+		disabled := false
+		updated, err = reminderAction(ctx, UpdateReminder, "PUT", feed.ID, UpdateReminderRequest{Enabled: &disabled})
+		require.NoError(t, err)
+		assert.False(t, updated.Enabled)
+		assert.Nil(t, updated.NextDueAt)
+
+		// Switched back on, it only follows activities from then on
+		var before models.Reminder
+		require.NoError(t, ctx.DB.First(&before, "id = ?", feed.ID).Error)
+		enabled := true
+		_, err = reminderAction(ctx, UpdateReminder, "PUT", feed.ID, UpdateReminderRequest{Enabled: &enabled})
+		require.NoError(t, err)
+		var after models.Reminder
+		require.NoError(t, ctx.DB.First(&after, "id = ?", feed.ID).Error)
+		assert.True(t, after.EnabledAt.After(before.EnabledAt))
+
+		_, err = reminderAction(ctx, UpdateReminder, "PUT", feed.ID, UpdateReminderRequest{Enabled: &disabled})
+		require.NoError(t, err)
+
+		_, err = reminderAction(ctx, SnoozeReminder, "POST", feed.ID, nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("snooze", func(t *testing.T) {
+		snoozed, err := reminderAction(ctx, SnoozeReminder, "POST", scheduled.ID, SnoozeReminderRequest{Minutes: 30})
+		require.NoError(t, err)
+		require.NotNil(t, snoozed.SnoozedUntil)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), *snoozed.SnoozedUntil, 5*time.Second)
+		assert.Equal(t, snoozed.SnoozedUntil, snoozed.NextDueAt)
+
+		_, err = reminderAction(ctx, SnoozeReminder, "POST", scheduled.ID, SnoozeReminderRequest{Minutes: 2000})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("acknowledge", func(t *testing.T) {
+		_, err := reminderAction(ctx, AcknowledgeReminder, "POST", scheduled.ID, nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, err.(*echo.HTTPError).Code)
+
+		require.NoError(t, ctx.DB.Model(&models.Reminder{}).Where("id = ?", scheduled.ID).
+			UpdateColumn("last_sent_at", time.Now()).Error)
+
+		acknowledged, err := reminderAction(ctx, AcknowledgeReminder, "POST", scheduled.ID, nil)
+		require.NoError(t, err)
+		assert.NotNil(t, acknowledged.AcknowledgedAt)
+		assert.Nil(t, acknowledged.SnoozedUntil)
+	})
+
+	t.Run("other users can't see the reminder", func(t *testing.T) {
+		other := addTestMember(t, ctx, models.BabyRoleCaregiver)
+		c, _ := createEchoContext(ctx, "POST", "/api/reminders/"+scheduled.ID+"/ack", nil)
+		asUser(c, other)
+		c.SetParamNames("id")
+		c.SetParamValues(scheduled.ID)
+		err := AcknowledgeReminder(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c, _ := createEchoContext(ctx, "DELETE", "/api/reminders/"+scheduled.ID, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(scheduled.ID)
+		require.NoError(t, DeleteReminder(c))
+
+		var count int64
+		require.NoError(t, ctx.DB.Model(&models.Reminder{}).Where("id = ?", scheduled.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// RevisionResponse represents an entry in an activity's history
+type RevisionResponse struct {
+	ID         string          `json:"id"`
+	ActivityID string          `json:"activity_id"`
+	BabyID     string          `json:"baby_id"`
+	Action     string          `json:"action"`
+	User       *ActivityAuthor `json:"user,omitempty"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditLogResponse represents a page of the audit log
+type AuditLogResponse struct {
+	Revisions  []RevisionResponse `json:"revisions"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"page_size"`
+	TotalPages int                `json:"total_pages"`
+}
+
+// GetActivityHistory handles GET /api/activities/:id/history, listing every
+// change made to an activity, oldest first. Deleted activities keep their
+// history.
+func GetActivityHistory(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
+	}
+
+	// Get the baby the activity belongs to
+	baby, err := resolveActivityBaby(c, db, userID, id, models.BabyRoleViewer)
+	if err != nil {
+		return activityLookupError(err)
+	}
+
+	var revisions []models.ActivityRevision
+	if err := db.Preload("User").
+		Where("activity_id = ? AND baby_id = ?", id, baby.ID).
+		Order("created_at ASC").
+		Find(&revisions).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch history")
+	}
+	if len(revisions) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+	}
+
+	response := make([]RevisionResponse, len(revisions))
+	for i, revision := range revisions {
+		response[i] = convertRevisionToResponse(revision)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetAuditLog handles GET /api/audit, the change history of activities across
+// every baby the user owns, newest first. It can be filtered by baby_id,
+// user_id, action, activity type and a start_date/end_date range.
+func GetAuditLog(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	ownedBabies := db.Model(&models.BabyMember{}).
+		Select("baby_id").
+		Where("user_id = ? AND role = ?", userID, models.BabyRoleOwner)
+	query := db.Model(&models.ActivityRevision{}).Where("baby_id IN (?)", ownedBabies)
+
+	// API tokens limited to one baby cannot see the others
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		query = query.Where("baby_id = ?", *token.BabyID)
+	}
+
+	if babyID := c.QueryParam("baby_id"); babyID != "" {
+		id, err := uuid.Parse(babyID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid baby ID")
+		}
+		query = query.Where("baby_id = ?", id)
+	}
+
+	if actor := c.QueryParam("user_id"); actor != "" {
+		id, err := uuid.Parse(actor)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+		}
+		query = query.Where("user_id = ?", id)
+	}
+
+	if action := c.QueryParam("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	if activityType := c.QueryParam("type"); activityType != "" {
+		query = query.Where("activity_id IN (?)",
+			db.Unscoped().Model(&models.Activity{}).Select("id").Where("type = ?", activityType))
+	}
+
+	if startDate := c.QueryParam("start_date"); startDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", startDate); err == nil {
+			query = query.Where("created_at >= ?", parsedDate)
+		}
+	}
+
+	if endDate := c.QueryParam("end_date"); endDate != "" {
+		if parsedDate, err := time.Parse("2006-01-02", endDate); err == nil {
+			// Add 24 hours to include the entire end date
+			query = query.Where("created_at < ?", parsedDate.Add(24*time.Hour))
+		}
+	}
+
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.QueryParam("page_size"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count revisions")
+	}
+
+	var revisions []models.ActivityRevision
+	if err := query.Preload("User").
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&revisions).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch revisions")
+	}
+
+	response := AuditLogResponse{
+		Revisions:  make([]RevisionResponse, len(revisions)),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}
+	for i, revision := range revisions {
+		response.Revisions[i] = convertRevisionToResponse(revision)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// loadActivitySnapshot loads an activity, including one in the trash, in its
+// API representation
+func loadActivitySnapshot(tx *gorm.DB, id uuid.UUID) (*ActivityResponse, error) {
+	var activity models.Activity
+	if err := tx.Unscoped().
+		Preload("FeedActivity").
+		Preload("PumpActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("GrowthMeasurement").
+		Preload("HealthRecord").
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		First(&activity, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	snapshot := convertActivityToResponse(activity)
+	return &snapshot, nil
+}
+
+// recordActivityRevision appends a change to the revision log. It should run
+// in the same transaction as the change itself.
+func recordActivityRevision(tx *gorm.DB, action models.RevisionAction, babyID, activityID uuid.UUID, userID string, before, after *ActivityResponse) error {
+	revision := models.ActivityRevision{
+		ActivityID: activityID,
+		BabyID:     babyID,
+		UserID:     parseAuthorID(userID),
+		Action:     action,
+	}
+
+	if before != nil {
+		data, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		revision.Before = string(data)
+	}
+	if after != nil {
+		data, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		revision.After = string(data)
+	}
+
+	return tx.Create(&revision).Error
+}
+
+// recordActivityChange logs a change from before to the activity's current state
+func recordActivityChange(tx *gorm.DB, action models.RevisionAction, babyID, activityID uuid.UUID, userID string, before *ActivityResponse) error {
+	after, err := loadActivitySnapshot(tx, activityID)
+	if err != nil {
+		return err
+	}
+	return recordActivityRevision(tx, action, babyID, activityID, userID, before, after)
+}
+
+// convertRevisionToResponse converts a model to response format
+func convertRevisionToResponse(revision models.ActivityRevision) RevisionResponse {
+	resp := RevisionResponse{
+		ID:         revision.ID.String(),
+		ActivityID: revision.ActivityID.String(),
+		BabyID:     revision.BabyID.String(),
+		Action:     string(revision.Action),
+		User:       convertAuthorToResponse(revision.User),
+		CreatedAt:  revision.CreatedAt,
+	}
+	if revision.Before != "" {
+		resp.Before = json.RawMessage(revision.Before)
+	}
+	if revision.After != "" {
+		resp.After = json.RawMessage(revision.After)
+	}
+	return resp
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+func getActivityHistory(t *testing.T, ctx *TestContext, id string) []RevisionResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "GET", "/api/activities/"+id+"/history", nil)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	require.NoError(t, GetActivityHistory(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var history []RevisionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	return history
+}
+
+func revisionSnapshot(t *testing.T, data json.RawMessage) ActivityResponse {
+	t.Helper()
+
+	var snapshot ActivityResponse
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	return snapshot
+}
+
+func TestActivityHistory(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	// Create a feed
+	c, rec := createEchoContext(ctx, "POST", "/api/activities", ActivityRequest{
+		Type:      "feed",
+		StartTime: time.Now().Add(-time.Hour),
+		FeedData:  &FeedData{FeedType: "bottle", AmountML: floatPtr(90)},
+	})
+	require.NoError(t, CreateActivity(c))
+	var created ActivityResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+	// It turns out to have been a pump
+	c, _ = createEchoContext(ctx, "PUT", "/api/activities/"+created.ID, ActivityRequest{
+		Type:      "pump",
+		StartTime: time.Now().Add(-time.Hour),
+		PumpData:  &PumpData{Breast: "both", AmountML: floatPtr(60)},
+	})
+	c.SetParamNames("id")
+	c.SetParamValues(created.ID)
+	require.NoError(t, UpdateActivity(c))
+
+	c, _ = createEchoContext(ctx, "DELETE", "/api/activities/"+created.ID, nil)
+	c.SetParamNames("id")
+	c.SetParamValues(created.ID)
+	require.NoError(t, DeleteActivity(c))
+
+	c, _ = createEchoContext(ctx, "POST", "/api/activities/"+created.ID+"/restore", nil)
+	c.SetParamNames("id")
+	c.SetParamValues(created.ID)
+	require.NoError(t, RestoreActivity(c))
+
+	t.Run("every change is recorded in order", func(t *testing.T) {
+		history := getActivityHistory(t, ctx, created.ID)
+		require.Len(t, history, 4)
+
+		actions := make([]string, len(history))
+		for i, revision := range history {
+			actions[i] = revision.Action
+			require.NotNil(t, revision.User)
+			assert.Equal(t, "testuser", revision.User.Username)
+			assert.Equal(t, ctx.Baby.ID.String(), revision.BabyID)
+		}
+		assert.Equal(t, []string{"create", "update", "delete", "restore"}, actions)
+	})
+
+	t.Run("snapshots show the activity before and after", func(t *testing.T) {
+		history := getActivityHistory(t, ctx, created.ID)
+
+		assert.JSONEq(t, "null", string(history[0].Before))
+		after := revisionSnapshot(t, history[0].After)
+		require.NotNil(t, after.FeedData)
+		assert.Equal(t, float64(90), *after.FeedData.AmountML)
+
+		before := revisionSnapshot(t, history[1].Before)
+		assert.Equal(t, "feed", before.Type)
+		require.NotNil(t, before.FeedData)
+		after = revisionSnapshot(t, history[1].After)
+		assert.Equal(t, "pump", after.Type)
+		assert.Nil(t, after.FeedData)
+		require.NotNil(t, after.PumpData)
+
+		assert.Equal(t, "pump", revisionSnapshot(t, history[2].Before).Type)
+		assert.JSONEq(t, "null", string(history[2].After))
+
+		assert.NotNil(t, revisionSnapshot(t, history[3].Before).DeletedAt)
+		assert.Nil(t, revisionSnapshot(t, history[3].After).DeletedAt)
+	})
+
+	t.Run("stopping a timer is recorded", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "POST", "/api/activities/timer/start", TimerStartRequest{
+			Type:      "sleep",
+			SleepData: &SleepData{Location: "crib"},
+		})
+		require.NoError(t, StartActivityTimer(c))
+		var timer ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &timer))
+
+		c, _ = createEchoContext(ctx, "PUT", "/api/activities/timer/"+timer.ID+"/stop", TimerStopRequest{Quality: intPtr(4)})
+		c.SetParamNames("id")
+		c.SetParamValues(timer.ID)
+		require.NoError(t, StopActivityTimer(c))
+
+		history := getActivityHistory(t, ctx, timer.ID)
+		require.Len(t, history, 2)
+		assert.Equal(t, "create", history[0].Action)
+		assert.Equal(t, "timer_stop", history[1].Action)
+		assert.Nil(t, revisionSnapshot(t, history[1].Before).EndTime)
+		assert.NotNil(t, revisionSnapshot(t, history[1].After).EndTime)
+	})
+
+	t.Run("history of another baby's activity is not found", func(t *testing.T) {
+		other := createTestUser(t, ctx.DB)
+		require.NoError(t, ctx.DB.Create(&models.Baby{
+			UserID:    other.ID,
+			Name:      "Other Baby",
+			BirthDate: time.Now().AddDate(0, 0, -60),
+		}).Error)
+
+		c, _ := createEchoContext(ctx, "GET", "/api/activities/"+created.ID+"/history", nil)
+		c.SetParamNames("id")
+		c.SetParamValues(created.ID)
+		asUser(c, other)
+		err := GetActivityHistory(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	for _, activityType := range []string{"feed", "diaper", "diaper"} {
+		c, _ := createEchoContext(ctx, "POST", "/api/activities", ActivityRequest{
+			Type:       activityType,
+			StartTime:  time.Now(),
+			FeedData:   &FeedData{FeedType: "bottle"},
+			DiaperData: &DiaperData{Wet: true},
+		})
+		require.NoError(t, CreateActivity(c))
+	}
+
+	getAuditLog := func(t *testing.T, query string, caller *models.User) AuditLogResponse {
+		t.Helper()
+
+		c, rec := createEchoContext(ctx, "GET", "/api/audit"+query, nil)
+		if caller != nil {
+			asUser(c, caller)
+		}
+		require.NoError(t, GetAuditLog(c))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response AuditLogResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("owners see changes across their babies", func(t *testing.T) {
+		audit := getAuditLog(t, "", nil)
+		assert.Equal(t, int64(3), audit.Total)
+		assert.Len(t, audit.Revisions, 3)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		assert.Equal(t, int64(2), getAuditLog(t, "?type=diaper", nil).Total)
+		assert.Equal(t, int64(3), getAuditLog(t, "?action=create", nil).Total)
+		assert.Equal(t, int64(0), getAuditLog(t, "?action=delete", nil).Total)
+		assert.Equal(t, int64(3), getAuditLog(t, "?user_id="+ctx.User.ID.String(), nil).Total)
+
+		page := getAuditLog(t, "?page=2&page_size=2", nil)
+		assert.Equal(t, 2, page.TotalPages)
+		assert.Len(t, page.Revisions, 1)
+	})
+
+	t.Run("other members see nothing", func(t *testing.T) {
+		caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+		audit := getAuditLog(t, "", caregiver)
+		assert.Equal(t, int64(0), audit.Total)
+		assert.Empty(t, audit.Revisions)
+	})
+}
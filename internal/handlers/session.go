@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// SessionResponse represents a logged-in device
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// GetSessions handles GET /api/auth/sessions
+func GetSessions(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var records []models.Session
+	if err := db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&records).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch sessions")
+	}
+
+	currentHash := ""
+	if key := utils.CurrentSessionKey(c); key != "" {
+		currentHash = utils.HashToken(key)
+	}
+
+	response := make([]SessionResponse, len(records))
+	for i, record := range records {
+		response[i] = SessionResponse{
+			ID:         record.ID.String(),
+			UserAgent:  record.UserAgent,
+			IPAddress:  record.IPAddress,
+			CreatedAt:  record.CreatedAt,
+			LastSeenAt: record.LastSeenAt,
+			ExpiresAt:  record.ExpiresAt,
+			Current:    record.TokenHash == currentHash,
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeleteSession handles DELETE /api/auth/sessions/:id
+func DeleteSession(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot manage sessions")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid session ID")
+	}
+
+	var record models.Session
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch session")
+	}
+
+	// Revoking the current session is just a logout
+	if key := utils.CurrentSessionKey(c); key != "" && utils.HashToken(key) == record.TokenHash {
+		if err := utils.DestroyUserSession(c); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session")
+		}
+	} else if err := db.Delete(&record).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Session revoked successfully",
+	})
+}
+
+// DeleteAllSessions handles DELETE /api/auth/sessions, logging the user out
+// everywhere. With ?keep_current=true the session making the request stays
+// logged in.
+func DeleteAllSessions(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot manage sessions")
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	keepCurrent := c.QueryParam("keep_current") == "true"
+	exceptKey := ""
+	if keepCurrent {
+		exceptKey = utils.CurrentSessionKey(c)
+	}
+
+	if err := utils.DeleteUserSessions(db, uid, exceptKey); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	if !keepCurrent {
+		// Also clear the cookie; a cookie-only session has no row to delete
+		if err := utils.DestroyUserSession(c); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke sessions")
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Sessions revoked successfully",
+	})
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	authMiddleware "github.com/engineervix/bambino/internal/middleware"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// newSessionServer returns an echo instance using the database session store
+func newSessionServer(ctx *TestContext) *echo.Echo {
+	e := echo.New()
+	// Test requests come from 192.0.2.1, which stands in for the proxy
+	e.IPExtractor = utils.ClientIPExtractor([]*net.IPNet{{IP: net.IPv4(192, 0, 2, 1), Mask: net.CIDRMask(32, 32)}})
+	e.Use(session.Middleware(utils.CreateSessionStore(utils.SessionConfig{
+		KeyPairs:    [][]byte{[]byte("test-secret")},
+		MaxAge:      3600,
+		HttpOnly:    true,
+		DB:          ctx.DB,
+		IPExtractor: e.IPExtractor,
+	})))
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", ctx.DB)
+			c.Set("config", ctx.Config)
+			return next(c)
+		}
+	})
+
+	e.POST("/api/auth/login", Login)
+	e.POST("/api/auth/login/2fa", VerifyTwoFactorLogin)
+	e.POST("/api/auth/logout", Logout)
+	e.GET("/api/auth/oidc/login", OIDCLogin)
+	e.GET("/api/auth/oidc/callback", OIDCCallback)
+
+	auth := e.Group("/api/auth")
+	auth.Use(authMiddleware.RequireAuthJSON())
+	auth.GET("/me", GetCurrentUser)
+	auth.GET("/logins", GetLoginHistory)
+	auth.GET("/2fa", GetTwoFactorStatus)
+	auth.POST("/2fa/setup", SetupTwoFactor)
+	auth.POST("/2fa/enable", EnableTwoFactor)
+	auth.POST("/2fa/disable", DisableTwoFactor)
+	auth.POST("/2fa/recovery-codes", RegenerateRecoveryCodes)
+	auth.PUT("/password", ChangePassword)
+	auth.GET("/sessions", GetSessions)
+	auth.DELETE("/sessions", DeleteAllSessions)
+	auth.DELETE("/sessions/:id", DeleteSession)
+	auth.GET("/oidc/identities", GetOIDCIdentities)
+	auth.DELETE("/oidc/identities/:id", DeleteOIDCIdentity)
+
+	return e
+}
+
+// createPasswordUser creates a user who can log in with the given password
+func createPasswordUser(t *testing.T, ctx *TestContext, username, password string) *models.User {
+	t.Helper()
+
+	hash, err := utils.HashPassword(password)
+	require.NoError(t, err)
+
+	user := &models.User{
+		ID:           uuid.New(),
+		Username:     username,
+		PasswordHash: hash,
+	}
+	require.NoError(t, ctx.DB.Create(user).Error)
+	return user
+}
+
+// loginDevice logs in and returns the session cookie
+func loginDevice(t *testing.T, e *echo.Echo, username, password, userAgent, ip string) *http.Cookie {
+	t.Helper()
+
+	body := `{"username":"` + username + `","password":"` + password + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Real-IP", ip)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == utils.SessionName {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie set")
+	return nil
+}
+
+func cookieRequest(e *echo.Echo, method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func listSessions(t *testing.T, e *echo.Echo, cookie *http.Cookie) []SessionResponse {
+	t.Helper()
+
+	rec := cookieRequest(e, http.MethodGet, "/api/auth/sessions", cookie)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var sessions []SessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sessions))
+	return sessions
+}
+
+func TestSessions(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newSessionServer(ctx)
+	user := createPasswordUser(t, ctx, "parent", "password123")
+
+	t.Run("sessions are stored server-side with device details", func(t *testing.T) {
+		laptop := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+		phone := loginDevice(t, e, "parent", "password123", "Phone", "192.0.2.20")
+
+		// The cookie holds an opaque key, not the session values
+		assert.NotContains(t, laptop.Value, user.ID.String())
+
+		sessions := listSessions(t, e, laptop)
+		require.Len(t, sessions, 2)
+
+		byAgent := map[string]SessionResponse{}
+		for _, s := range sessions {
+			byAgent[s.UserAgent] = s
+		}
+		assert.True(t, byAgent["Laptop"].Current)
+		assert.False(t, byAgent["Phone"].Current)
+		assert.Equal(t, "192.0.2.10", byAgent["Laptop"].IPAddress)
+		assert.Equal(t, "192.0.2.20", byAgent["Phone"].IPAddress)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), byAgent["Phone"].ExpiresAt, time.Minute)
+
+		// Revoking the phone logs it out straight away
+		rec := cookieRequest(e, http.MethodDelete, "/api/auth/sessions/"+byAgent["Phone"].ID, laptop)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = cookieRequest(e, http.MethodGet, "/api/auth/me", phone)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = cookieRequest(e, http.MethodGet, "/api/auth/me", laptop)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		cookieRequest(e, http.MethodPost, "/api/auth/logout", laptop)
+	})
+
+	t.Run("logout deletes the session", func(t *testing.T) {
+		cookie := loginDevice(t, e, "parent", "password123", "Tablet", "192.0.2.30")
+
+		rec := cookieRequest(e, http.MethodPost, "/api/auth/logout", cookie)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = cookieRequest(e, http.MethodGet, "/api/auth/me", cookie)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var count int64
+		ctx.DB.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("cannot revoke another user's session", func(t *testing.T) {
+		createPasswordUser(t, ctx, "nanny", "password123")
+		nanny := loginDevice(t, e, "nanny", "password123", "Nanny", "192.0.2.40")
+		parent := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+
+		nannySessions := listSessions(t, e, nanny)
+		require.Len(t, nannySessions, 1)
+
+		rec := cookieRequest(e, http.MethodDelete, "/api/auth/sessions/"+nannySessions[0].ID, parent)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = cookieRequest(e, http.MethodGet, "/api/auth/me", nanny)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		cookieRequest(e, http.MethodDelete, "/api/auth/sessions", parent)
+	})
+
+	t.Run("log out everywhere", func(t *testing.T) {
+		laptop := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+		phone := loginDevice(t, e, "parent", "password123", "Phone", "192.0.2.20")
+		tablet := loginDevice(t, e, "parent", "password123", "Tablet", "192.0.2.30")
+
+		// Keep the current session
+		rec := cookieRequest(e, http.MethodDelete, "/api/auth/sessions?keep_current=true", laptop)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, cookieRequest(e, http.MethodGet, "/api/auth/me", phone).Code)
+		assert.Equal(t, http.StatusUnauthorized, cookieRequest(e, http.MethodGet, "/api/auth/me", tablet).Code)
+		assert.Equal(t, http.StatusOK, cookieRequest(e, http.MethodGet, "/api/auth/me", laptop).Code)
+
+		// And then everything, including this one
+		rec = cookieRequest(e, http.MethodDelete, "/api/auth/sessions", laptop)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, http.StatusUnauthorized, cookieRequest(e, http.MethodGet, "/api/auth/me", laptop).Code)
+	})
+
+	t.Run("expired sessions are rejected and purged", func(t *testing.T) {
+		cookie := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+
+		require.NoError(t, ctx.DB.Model(&models.Session{}).
+			Where("user_id = ?", user.ID).
+			UpdateColumn("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		rec := cookieRequest(e, http.MethodGet, "/api/auth/me", cookie)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		store := utils.NewDBStore(ctx.DB, []byte("test-secret"))
+		require.NoError(t, store.PurgeExpired())
+
+		var count int64
+		ctx.DB.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newSessionServer(ctx)
+	createPasswordUser(t, ctx, "parent", "password123")
+
+	changePassword := func(cookie *http.Cookie, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/password", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	laptop := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+	phone := loginDevice(t, e, "parent", "password123", "Phone", "192.0.2.20")
+
+	t.Run("wrong current password is rejected", func(t *testing.T) {
+		rec := changePassword(laptop, `{"current_password":"wrong","new_password":"newpassword456"}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("short new password is rejected", func(t *testing.T) {
+		rec := changePassword(laptop, `{"current_password":"password123","new_password":"short"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("password is changed and other sessions logged out", func(t *testing.T) {
+		rec := changePassword(laptop, `{"current_password":"password123","new_password":"newpassword456"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		assert.Equal(t, http.StatusOK, cookieRequest(e, http.MethodGet, "/api/auth/me", laptop).Code)
+		assert.Equal(t, http.StatusUnauthorized, cookieRequest(e, http.MethodGet, "/api/auth/me", phone).Code)
+
+		var user models.User
+		require.NoError(t, ctx.DB.Where("username = ?", "parent").First(&user).Error)
+		valid, err := utils.VerifyPassword("newpassword456", user.PasswordHash)
+		require.NoError(t, err)
+		assert.True(t, valid)
+
+		loginDevice(t, e, "parent", "newpassword456", "Phone", "192.0.2.20")
+	})
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+const (
+	// defaultShareLinkTTL is how long a share link stays valid when no expiry is given
+	defaultShareLinkTTL = 7 * 24 * time.Hour
+	// maxShareRangeDays limits the date range a share link can cover
+	maxShareRangeDays = 366
+)
+
+// ShareLinkRequest represents the request body for creating a share link
+type ShareLinkRequest struct {
+	Label          string `json:"label" validate:"max=100"`
+	StartDate      string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate        string `json:"end_date" validate:"required,datetime=2006-01-02"`
+	ExpiresInHours int    `json:"expires_in_hours" validate:"omitempty,min=1,max=8760"`
+}
+
+// ShareLinkResponse represents a share link
+type ShareLinkResponse struct {
+	ID        string     `json:"id"`
+	Label     string     `json:"label,omitempty"`
+	Token     string     `json:"token"`
+	StartDate string     `json:"start_date"`
+	EndDate   string     `json:"end_date"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ShareAccessResponse represents a single recorded access of a share link
+type ShareAccessResponse struct {
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	Path       string    `json:"path"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// SharedBabyResponse describes the baby and date range a share link gives access to
+type SharedBabyResponse struct {
+	Baby      BabyResponse `json:"baby"`
+	Label     string       `json:"label,omitempty"`
+	StartDate string       `json:"start_date"`
+	EndDate   string       `json:"end_date"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// SharedStatsResponse represents the daily breakdown over a share link's date range
+type SharedStatsResponse struct {
+	StartDate      string             `json:"start_date"`
+	EndDate        string             `json:"end_date"`
+	DailyAverages  map[string]float64 `json:"daily_averages"`
+	DailyBreakdown []DailyDataPoint   `json:"daily_breakdown"`
+}
+
+// CreateShareLink handles POST /api/babies/:baby_id/shares
+func CreateShareLink(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req ShareLinkRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	startDate, _ := time.Parse("2006-01-02", req.StartDate)
+	endDate, _ := time.Parse("2006-01-02", req.EndDate)
+	if endDate.Before(startDate) {
+		return echo.NewHTTPError(http.StatusBadRequest, "end date must not be before start date")
+	}
+	if endDate.Sub(startDate) >= maxShareRangeDays*24*time.Hour {
+		return echo.NewHTTPError(http.StatusBadRequest, "date range cannot exceed one year")
+	}
+
+	creatorID, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	ttl := defaultShareLinkTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	link := models.ShareLink{
+		BabyID:      baby.ID,
+		CreatedByID: creatorID,
+		Label:       req.Label,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := db.Create(&link).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create share link")
+	}
+
+	return c.JSON(http.StatusCreated, convertShareLinkToResponse(c, link))
+}
+
+// GetShareLinks handles GET /api/babies/:baby_id/shares
+func GetShareLinks(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	var links []models.ShareLink
+	if err := db.Where("baby_id = ?", baby.ID).
+		Order("created_at DESC").
+		Find(&links).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch share links")
+	}
+
+	response := make([]ShareLinkResponse, len(links))
+	for i, link := range links {
+		response[i] = convertShareLinkToResponse(c, link)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// RevokeShareLink handles DELETE /api/babies/:baby_id/shares/:id
+func RevokeShareLink(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	link, err := getShareLinkFromPath(c, db, baby.ID)
+	if err != nil {
+		return err
+	}
+
+	// Keep the link so its access log stays available
+	if link.RevokedAt == nil {
+		if err := db.Model(link).Update("revoked_at", time.Now()).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke share link")
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "share link revoked successfully",
+	})
+}
+
+// GetShareLinkAccesses handles GET /api/babies/:baby_id/shares/:id/accesses
+func GetShareLinkAccesses(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleOwner)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	link, err := getShareLinkFromPath(c, db, baby.ID)
+	if err != nil {
+		return err
+	}
+
+	var accesses []models.ShareAccessLog
+	if err := db.Where("share_link_id = ?", link.ID).
+		Order("created_at DESC").
+		Find(&accesses).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch access log")
+	}
+
+	response := make([]ShareAccessResponse, len(accesses))
+	for i, access := range accesses {
+		response[i] = ShareAccessResponse{
+			IPAddress:  access.IPAddress,
+			UserAgent:  access.UserAgent,
+			Path:       access.Path,
+			AccessedAt: access.CreatedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetSharedBaby handles GET /api/share/:token
+func GetSharedBaby(c echo.Context) error {
+	link, ok := c.Get("share_link").(*models.ShareLink)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "share link not found in context")
+	}
+
+	return c.JSON(http.StatusOK, SharedBabyResponse{
+		Baby:      convertBabyToResponse(link.Baby, models.BabyRoleViewer),
+		Label:     link.Label,
+		StartDate: link.StartDate.Format("2006-01-02"),
+		EndDate:   link.EndDate.Format("2006-01-02"),
+		ExpiresAt: link.ExpiresAt,
+	})
+}
+
+// GetSharedActivities handles GET /api/share/:token/activities
+func GetSharedActivities(c echo.Context) error {
+	link, ok := c.Get("share_link").(*models.ShareLink)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "share link not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	// Parse query parameters
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.QueryParam("page_size"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := sharedActivitiesQuery(db, link)
+	if activityType := c.QueryParam("type"); activityType != "" {
+		query = query.Where("type = ?", activityType)
+	}
+
+	var total int64
+	if err := query.Model(&models.Activity{}).Count(&total).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count activities")
+	}
+
+	var activities []models.Activity
+	if err := query.
+		Preload("FeedActivity").
+		Preload("PumpActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("GrowthMeasurement").
+		Preload("HealthRecord").
+		Preload("Milestone").
+		Order("start_time DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&activities).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activities")
+	}
+
+	// Authors are not preloaded, so caregiver names are not shared
+	activityResponses := make([]ActivityResponse, len(activities))
+	for i, activity := range activities {
+		activityResponses[i] = convertActivityToResponse(activity)
+	}
+
+	return c.JSON(http.StatusOK, ActivityListResponse{
+		Activities: activityResponses,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+// GetSharedGrowth handles GET /api/share/:token/growth
+func GetSharedGrowth(c echo.Context) error {
+	link, ok := c.Get("share_link").(*models.ShareLink)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "share link not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var measurements []models.Activity
+	if err := sharedActivitiesQuery(db, link).
+		Preload("GrowthMeasurement").
+		Where("type = ?", models.ActivityTypeGrowth).
+		Order("start_time ASC").
+		Find(&measurements).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch growth measurements")
+	}
+
+	response := make([]ActivityResponse, len(measurements))
+	for i, measurement := range measurements {
+		response[i] = convertActivityToResponse(measurement)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetSharedStats handles GET /api/share/:token/stats
+func GetSharedStats(c echo.Context) error {
+	link, ok := c.Get("share_link").(*models.ShareLink)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "share link not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	// Adjust for client's timezone offset, as in GetWeeklyStats
+	var location *time.Location = time.UTC
+	if tzOffsetMinutes, err := strconv.Atoi(c.QueryParam("tz_offset")); err == nil {
+		location = time.FixedZone("user_tz", -tzOffsetMinutes*60)
+	}
+
+	year, month, day := link.StartDate.Date()
+	startDate := time.Date(year, month, day, 0, 0, 0, 0, location)
+	days := int(link.EndDate.Sub(link.StartDate).Hours()/24) + 1
+	endDate := startDate.AddDate(0, 0, days)
+
+	var activities []models.Activity
+	if err := db.Preload("FeedActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("PumpActivity").
+		Where("baby_id = ? AND start_time >= ? AND start_time < ?", link.BabyID, startDate.UTC(), endDate.UTC()).
+		Find(&activities).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activities")
+	}
+
+	dailyBreakdown, averages := summarizeDays(activities, startDate, days, location)
+
+	return c.JSON(http.StatusOK, SharedStatsResponse{
+		StartDate:      startDate.Format("2006-01-02"),
+		EndDate:        endDate.AddDate(0, 0, -1).Format("2006-01-02"),
+		DailyAverages:  averages,
+		DailyBreakdown: dailyBreakdown,
+	})
+}
+
+// sharedActivitiesQuery scopes a query to the baby and date range of a share link
+func sharedActivitiesQuery(db *gorm.DB, link *models.ShareLink) *gorm.DB {
+	return db.Where("baby_id = ? AND start_time >= ? AND start_time < ?",
+		link.BabyID, link.StartDate, link.EndDate.Add(24*time.Hour))
+}
+
+// getShareLinkFromPath loads the share link identified by the id path param
+func getShareLinkFromPath(c echo.Context, db *gorm.DB, babyID uuid.UUID) (*models.ShareLink, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid share link ID")
+	}
+
+	var link models.ShareLink
+	if err := db.Where("id = ? AND baby_id = ?", id, babyID).First(&link).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "share link not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	return &link, nil
+}
+
+// convertShareLinkToResponse converts a model to response format, signing its token
+// with the newest session signing key
+func convertShareLinkToResponse(c echo.Context, link models.ShareLink) ShareLinkResponse {
+	var secret []byte
+	if cfg, ok := c.Get("config").(*config.Config); ok {
+		if keys := cfg.SigningKeys(); len(keys) > 0 {
+			secret = keys[0]
+		}
+	}
+
+	return ShareLinkResponse{
+		ID:        link.ID.String(),
+		Label:     link.Label,
+		Token:     utils.SignToken(secret, link.ID.String()),
+		StartDate: link.StartDate.Format("2006-01-02"),
+		EndDate:   link.EndDate.Format("2006-01-02"),
+		ExpiresAt: link.ExpiresAt,
+		RevokedAt: link.RevokedAt,
+		CreatedAt: link.CreatedAt,
+	}
+}
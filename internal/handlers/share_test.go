@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	authMiddleware "github.com/engineervix/bambino/internal/middleware"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// newShareServer returns an echo instance serving the public share routes
+func newShareServer(ctx *TestContext) *echo.Echo {
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", ctx.DB)
+			c.Set("config", ctx.Config)
+			return next(c)
+		}
+	})
+
+	share := e.Group("/api/share/:token")
+	share.Use(authMiddleware.RequireShareToken())
+	share.GET("", GetSharedBaby)
+	share.GET("/activities", GetSharedActivities)
+	share.GET("/growth", GetSharedGrowth)
+	share.GET("/stats", GetSharedStats)
+
+	return e
+}
+
+// createTestShareLink creates a share link through the API
+func createTestShareLink(t *testing.T, ctx *TestContext, req ShareLinkRequest) ShareLinkResponse {
+	t.Helper()
+
+	babyID := ctx.Baby.ID.String()
+	c, rec := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/shares", req)
+	c.SetParamNames("baby_id")
+	c.SetParamValues(babyID)
+
+	require.NoError(t, CreateShareLink(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response ShareLinkResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotEmpty(t, response.Token)
+
+	return response
+}
+
+func TestShareLinks(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+	ctx.Config.SessionSecret = "test-secret"
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	// One activity inside the shared range and one before it
+	inside := &models.Activity{
+		BabyID:            ctx.Baby.ID,
+		Type:              models.ActivityTypeGrowth,
+		StartTime:         today.Add(2 * time.Hour),
+		GrowthMeasurement: &models.GrowthMeasurement{WeightKG: floatPtr(4.2)},
+	}
+	require.NoError(t, ctx.DB.Create(inside).Error)
+	outside := &models.Activity{
+		BabyID:    ctx.Baby.ID,
+		Type:      models.ActivityTypeDiaper,
+		StartTime: today.AddDate(0, 0, -10),
+	}
+	require.NoError(t, ctx.DB.Create(outside).Error)
+
+	link := createTestShareLink(t, ctx, ShareLinkRequest{
+		Label:     "Dr. Smith",
+		StartDate: today.AddDate(0, 0, -2).Format("2006-01-02"),
+		EndDate:   today.Format("2006-01-02"),
+	})
+
+	e := newShareServer(ctx)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("User-Agent", "test-browser")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("baby info", func(t *testing.T) {
+		rec := get("/api/share/" + link.Token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response SharedBabyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, ctx.Baby.Name, response.Baby.Name)
+		assert.Equal(t, "Dr. Smith", response.Label)
+	})
+
+	t.Run("activities are limited to the date range", func(t *testing.T) {
+		rec := get("/api/share/" + link.Token + "/activities")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response ActivityListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Activities, 1)
+		assert.Equal(t, inside.ID.String(), response.Activities[0].ID)
+	})
+
+	t.Run("growth history", func(t *testing.T) {
+		rec := get("/api/share/" + link.Token + "/growth")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response []ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response, 1)
+		assert.Equal(t, 4.2, *response[0].GrowthData.WeightKG)
+	})
+
+	t.Run("stats cover each day of the range", func(t *testing.T) {
+		rec := get("/api/share/" + link.Token + "/stats")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response SharedStatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.DailyBreakdown, 3)
+		assert.Equal(t, link.StartDate, response.StartDate)
+		assert.Equal(t, link.EndDate, response.EndDate)
+	})
+
+	t.Run("tampered token is rejected", func(t *testing.T) {
+		rec := get("/api/share/" + link.ID + ".bogus")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("every access is logged", func(t *testing.T) {
+		babyID := ctx.Baby.ID.String()
+		c, rec := createEchoContext(ctx, "GET", "/api/babies/"+babyID+"/shares/"+link.ID+"/accesses", nil)
+		c.SetParamNames("baby_id", "id")
+		c.SetParamValues(babyID, link.ID)
+
+		require.NoError(t, GetShareLinkAccesses(c))
+
+		var accesses []ShareAccessResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &accesses))
+		assert.Len(t, accesses, 4)
+		assert.Equal(t, "test-browser", accesses[0].UserAgent)
+	})
+
+	t.Run("long client IP headers fit the access log", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/share/"+link.Token, nil)
+		req.Header.Set(echo.HeaderXRealIP, strings.Repeat("1", 100))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var access models.ShareAccessLog
+		require.NoError(t, ctx.DB.Where("ip_address LIKE ?", "111%").First(&access).Error)
+		assert.Len(t, access.IPAddress, 45)
+	})
+
+	t.Run("revoked link is rejected", func(t *testing.T) {
+		babyID := ctx.Baby.ID.String()
+		c, _ := createEchoContext(ctx, "DELETE", "/api/babies/"+babyID+"/shares/"+link.ID, nil)
+		c.SetParamNames("baby_id", "id")
+		c.SetParamValues(babyID, link.ID)
+		require.NoError(t, RevokeShareLink(c))
+
+		rec := get("/api/share/" + link.Token)
+		assert.Equal(t, http.StatusGone, rec.Code)
+	})
+
+	t.Run("expired link is rejected", func(t *testing.T) {
+		expired := createTestShareLink(t, ctx, ShareLinkRequest{
+			StartDate: today.Format("2006-01-02"),
+			EndDate:   today.Format("2006-01-02"),
+		})
+		require.NoError(t, ctx.DB.Model(&models.ShareLink{}).
+			Where("id = ?", expired.ID).
+			UpdateColumn("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		rec := get("/api/share/" + expired.Token)
+		assert.Equal(t, http.StatusGone, rec.Code)
+	})
+
+	t.Run("end date before start date", func(t *testing.T) {
+		babyID := ctx.Baby.ID.String()
+		c, _ := createEchoContext(ctx, "POST", "/api/babies/"+babyID+"/shares", ShareLinkRequest{
+			StartDate: today.Format("2006-01-02"),
+			EndDate:   today.AddDate(0, 0, -1).Format("2006-01-02"),
+		})
+		c.SetParamNames("baby_id")
+		c.SetParamValues(babyID)
+
+		err := CreateShareLink(c)
+		httpError, ok := err.(*echo.HTTPError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 
@@ -109,12 +110,11 @@ func GetDailyStats(c echo.Context) error {
 	// Get start and end of the day in the user's timezone
 	year, month, day := targetDate.Date()
 	startOfDay := time.Date(year, month, day, 0, 0, 0, 0, location)
-	endOfDay := startOfDay.AddDate(0, 0, 1)
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleViewer)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Baby not found")
+		return babyLookupError(err)
 	}
 
 	// Validate that the requested date is not before baby's birth date
@@ -122,80 +122,11 @@ func GetDailyStats(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Cannot query dates before baby's birth date")
 	}
 
-	// Get activities for the day, querying in UTC
-	var activities []models.Activity
-	err = db.Preload("FeedActivity").
-		Preload("DiaperActivity").
-		Preload("SleepActivity").
-		Preload("PumpActivity").
-		Where("baby_id = ? AND start_time >= ? AND start_time < ?", baby.ID, startOfDay.UTC(), endOfDay.UTC()).
-		Find(&activities).Error
+	response, err := DailyStats(db, baby.ID, startOfDay)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch activities")
 	}
 
-	// Calculate statistics
-	counts := make(map[string]int)
-	totals := make(map[string]float64)
-	lastActivities := make(map[string]*time.Time)
-
-	// Track diaper breakdown
-	var diaperBreakdown *DiaperBreakdown
-	wetCount := 0
-	dirtyCount := 0
-
-	for _, activity := range activities {
-		activityType := string(activity.Type)
-		counts[activityType]++
-
-		// Update last activity time
-		if lastActivities[activityType] == nil || activity.StartTime.After(*lastActivities[activityType]) {
-			lastActivities[activityType] = &activity.StartTime
-		}
-
-		// Calculate totals based on activity type
-		switch activity.Type {
-		case models.ActivityTypeFeed:
-			if activity.FeedActivity != nil && activity.FeedActivity.AmountML != nil {
-				totals["feed_amount_ml"] += *activity.FeedActivity.AmountML
-			}
-		case models.ActivityTypePump:
-			if activity.PumpActivity != nil && activity.PumpActivity.AmountML != nil {
-				totals["pump_amount_ml"] += *activity.PumpActivity.AmountML
-			}
-		case models.ActivityTypeDiaper:
-			if activity.DiaperActivity != nil {
-				if activity.DiaperActivity.Wet {
-					wetCount++
-				}
-				if activity.DiaperActivity.Dirty {
-					dirtyCount++
-				}
-			}
-		case models.ActivityTypeSleep:
-			if activity.EndTime != nil {
-				duration := activity.EndTime.Sub(activity.StartTime).Hours()
-				totals["sleep_hours"] += duration
-			}
-		}
-	}
-
-	// Set diaper breakdown if there are any diapers
-	if counts["diaper"] > 0 {
-		diaperBreakdown = &DiaperBreakdown{
-			Wet:   wetCount,
-			Dirty: dirtyCount,
-		}
-	}
-
-	response := DailyStatsResponse{
-		Date:            startOfDay.Format("2006-01-02"),
-		Counts:          counts,
-		Totals:          totals,
-		LastActivities:  lastActivities,
-		DiaperBreakdown: diaperBreakdown,
-	}
-
 	return c.JSON(http.StatusOK, response)
 }
 
@@ -204,76 +135,13 @@ func GetRecentStats(c echo.Context) error {
 	db := c.Get("db").(*gorm.DB)
 	userID := c.Get("user_id").(string)
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleViewer)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Baby not found")
-	}
-
-	response := RecentStatsResponse{}
-
-	// Get last feed
-	var lastFeed models.Activity
-	err = db.Preload("FeedActivity").
-		Where("baby_id = ? AND type = ?", baby.ID, models.ActivityTypeFeed).
-		Order("start_time DESC").
-		First(&lastFeed).Error
-	if err == nil {
-		hoursAgo := time.Since(lastFeed.StartTime).Hours()
-		feedInfo := &LastFeedInfo{
-			Time:     lastFeed.StartTime,
-			HoursAgo: hoursAgo,
-		}
-		if lastFeed.FeedActivity != nil {
-			feedInfo.Type = string(lastFeed.FeedActivity.FeedType)
-			feedInfo.AmountML = lastFeed.FeedActivity.AmountML
-		}
-		response.LastFeed = feedInfo
-	}
-
-	// Get last diaper
-	var lastDiaper models.Activity
-	err = db.Preload("DiaperActivity").
-		Where("baby_id = ? AND type = ?", baby.ID, models.ActivityTypeDiaper).
-		Order("start_time DESC").
-		First(&lastDiaper).Error
-	if err == nil {
-		hoursAgo := time.Since(lastDiaper.StartTime).Hours()
-		diaperInfo := &LastDiaperInfo{
-			Time:     lastDiaper.StartTime,
-			HoursAgo: hoursAgo,
-		}
-		if lastDiaper.DiaperActivity != nil {
-			diaperInfo.Wet = lastDiaper.DiaperActivity.Wet
-			diaperInfo.Dirty = lastDiaper.DiaperActivity.Dirty
-		}
-		response.LastDiaper = diaperInfo
+		return babyLookupError(err)
 	}
 
-	// Check if currently sleeping (last sleep activity with no end time)
-	var currentSleep models.Activity
-	err = db.Where("baby_id = ? AND type = ? AND end_time IS NULL", baby.ID, models.ActivityTypeSleep).
-		Order("start_time DESC").
-		First(&currentSleep).Error
-	response.CurrentlySleeping = (err == nil)
-
-	// Get last completed sleep
-	if !response.CurrentlySleeping {
-		var lastSleep models.Activity
-		err = db.Where("baby_id = ? AND type = ? AND end_time IS NOT NULL", baby.ID, models.ActivityTypeSleep).
-			Order("start_time DESC").
-			First(&lastSleep).Error
-		if err == nil {
-			sleepInfo := &LastSleepInfo{
-				Ended: lastSleep.EndTime,
-			}
-			if lastSleep.EndTime != nil {
-				duration := lastSleep.EndTime.Sub(lastSleep.StartTime).Hours()
-				sleepInfo.DurationHours = &duration
-			}
-			response.LastSleep = sleepInfo
-		}
-	}
+	response := RecentStats(db, baby.ID)
 
 	return c.JSON(http.StatusOK, response)
 }
@@ -320,10 +188,10 @@ func GetWeeklyStats(c echo.Context) error {
 	// Start date is 7 days before the end date
 	startDate := endDate.AddDate(0, 0, -7)
 
-	// Get user's baby
-	baby, err := getUserBaby(db, userID)
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, "", models.BabyRoleViewer)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Baby not found")
+		return babyLookupError(err)
 	}
 
 	// Validate that the requested date is not before baby's birth date
@@ -343,27 +211,73 @@ func GetWeeklyStats(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch activities")
 	}
 
+	// Calculate daily breakdown and averages with proper timezone handling
+	dailyBreakdown, averages := summarizeDays(activities, startDate, 7, location)
+
+	// Get growth measurements for the past 7 days
+	var growthInfo *WeeklyGrowthInfo
+	var firstGrowth, lastGrowth models.Activity
+
+	err1 := db.Preload("GrowthMeasurement").
+		Where("baby_id = ? AND type = ? AND start_time >= ? AND start_time < ?", baby.ID, models.ActivityTypeGrowth, startDate.UTC(), endDate.UTC()).
+		Order("start_time ASC").
+		First(&firstGrowth).Error
+
+	err2 := db.Preload("GrowthMeasurement").
+		Where("baby_id = ? AND type = ? AND start_time >= ? AND start_time < ?", baby.ID, models.ActivityTypeGrowth, startDate.UTC(), endDate.UTC()).
+		Order("start_time DESC").
+		First(&lastGrowth).Error
+
+	if err1 == nil && err2 == nil && firstGrowth.ID != lastGrowth.ID {
+		growthInfo = &WeeklyGrowthInfo{}
+
+		if firstGrowth.GrowthMeasurement != nil && lastGrowth.GrowthMeasurement != nil {
+			if firstGrowth.GrowthMeasurement.WeightKG != nil && lastGrowth.GrowthMeasurement.WeightKG != nil {
+				weightChange := *lastGrowth.GrowthMeasurement.WeightKG - *firstGrowth.GrowthMeasurement.WeightKG
+				growthInfo.WeightChangeKG = &weightChange
+			}
+			if firstGrowth.GrowthMeasurement.HeightCM != nil && lastGrowth.GrowthMeasurement.HeightCM != nil {
+				heightChange := *lastGrowth.GrowthMeasurement.HeightCM - *firstGrowth.GrowthMeasurement.HeightCM
+				growthInfo.HeightChangeCM = &heightChange
+			}
+		}
+	}
+
+	response := WeeklyStatsResponse{
+		StartDate:      startDate.Format("2006-01-02"),
+		EndDate:        endDate.Format("2006-01-02"),
+		DailyAverages:  averages,
+		DailyBreakdown: dailyBreakdown,
+		GrowthThisWeek: growthInfo,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// summarizeDays buckets activities into consecutive days starting at startDate
+// and returns the per-day breakdown along with daily averages over the period
+func summarizeDays(activities []models.Activity, startDate time.Time, days int, location *time.Location) ([]DailyDataPoint, map[string]float64) {
 	// Calculate daily totals with proper timezone handling
 	dailyCounts := make(map[string][]int)
 	dailyTotals := make(map[string][]float64)
 
-	// Initialize arrays for 7 days
+	// Initialize arrays for each day
 	activityTypes := []string{"feed", "diaper", "sleep", "pump"}
 	for _, actType := range activityTypes {
-		dailyCounts[actType] = make([]int, 7)
+		dailyCounts[actType] = make([]int, days)
 	}
 
 	// Initialize maps for totals that need more than just counts
-	dailyTotals["sleep_hours"] = make([]float64, 7)
-	dailyTotals["feed_amount_ml"] = make([]float64, 7)
-	dailyTotals["pump_amount_ml"] = make([]float64, 7)
+	dailyTotals["sleep_hours"] = make([]float64, days)
+	dailyTotals["feed_amount_ml"] = make([]float64, days)
+	dailyTotals["pump_amount_ml"] = make([]float64, days)
 
 	for _, activity := range activities {
 		// Convert activity time to user's timezone for proper day calculation
 		activityTime := activity.StartTime.In(location)
 		dayIndex := int(activityTime.Sub(startDate).Hours() / 24)
 
-		if dayIndex >= 0 && dayIndex < 7 {
+		if dayIndex >= 0 && dayIndex < days {
 			activityType := string(activity.Type)
 			// Only count activity types that we're tracking in daily stats
 			if _, exists := dailyCounts[activityType]; exists {
@@ -398,7 +312,7 @@ func GetWeeklyStats(c echo.Context) error {
 		for _, count := range counts {
 			total += count
 		}
-		averages[actType+"_per_day"] = float64(total) / 7.0
+		averages[actType+"_per_day"] = float64(total) / float64(days)
 	}
 
 	// Amount/duration averages
@@ -408,12 +322,12 @@ func GetWeeklyStats(c echo.Context) error {
 	} else {
 		averages["feed_amount_ml_per_feed"] = 0
 	}
-	averages["feed_amount_ml_per_day"] = sumFloats(dailyTotals["feed_amount_ml"]) / 7.0
-	averages["sleep_hours_per_day"] = sumFloats(dailyTotals["sleep_hours"]) / 7.0
+	averages["feed_amount_ml_per_day"] = sumFloats(dailyTotals["feed_amount_ml"]) / float64(days)
+	averages["sleep_hours_per_day"] = sumFloats(dailyTotals["sleep_hours"]) / float64(days)
 
 	// Prepare daily breakdown
-	dailyBreakdown := make([]DailyDataPoint, 7)
-	for i := 0; i < 7; i++ {
+	dailyBreakdown := make([]DailyDataPoint, days)
+	for i := 0; i < days; i++ {
 		date := startDate.AddDate(0, 0, i)
 		diaperCount := dailyCounts["diaper"][i]
 		feedCount := dailyCounts["feed"][i]
@@ -427,44 +341,7 @@ func GetWeeklyStats(c echo.Context) error {
 		}
 	}
 
-	// Get growth measurements for the past 7 days
-	var growthInfo *WeeklyGrowthInfo
-	var firstGrowth, lastGrowth models.Activity
-
-	err1 := db.Preload("GrowthMeasurement").
-		Where("baby_id = ? AND type = ? AND start_time >= ? AND start_time < ?", baby.ID, models.ActivityTypeGrowth, startDate.UTC(), endDate.UTC()).
-		Order("start_time ASC").
-		First(&firstGrowth).Error
-
-	err2 := db.Preload("GrowthMeasurement").
-		Where("baby_id = ? AND type = ? AND start_time >= ? AND start_time < ?", baby.ID, models.ActivityTypeGrowth, startDate.UTC(), endDate.UTC()).
-		Order("start_time DESC").
-		First(&lastGrowth).Error
-
-	if err1 == nil && err2 == nil && firstGrowth.ID != lastGrowth.ID {
-		growthInfo = &WeeklyGrowthInfo{}
-
-		if firstGrowth.GrowthMeasurement != nil && lastGrowth.GrowthMeasurement != nil {
-			if firstGrowth.GrowthMeasurement.WeightKG != nil && lastGrowth.GrowthMeasurement.WeightKG != nil {
-				weightChange := *lastGrowth.GrowthMeasurement.WeightKG - *firstGrowth.GrowthMeasurement.WeightKG
-				growthInfo.WeightChangeKG = &weightChange
-			}
-			if firstGrowth.GrowthMeasurement.HeightCM != nil && lastGrowth.GrowthMeasurement.HeightCM != nil {
-				heightChange := *lastGrowth.GrowthMeasurement.HeightCM - *firstGrowth.GrowthMeasurement.HeightCM
-				growthInfo.HeightChangeCM = &heightChange
-			}
-		}
-	}
-
-	response := WeeklyStatsResponse{
-		StartDate:      startDate.Format("2006-01-02"),
-		EndDate:        endDate.Format("2006-01-02"),
-		DailyAverages:  averages,
-		DailyBreakdown: dailyBreakdown,
-		GrowthThisWeek: growthInfo,
-	}
-
-	return c.JSON(http.StatusOK, response)
+	return dailyBreakdown, averages
 }
 
 func sumInts(slice []int) int {
@@ -482,3 +359,152 @@ func sumFloats(slice []float64) float64 {
 	}
 	return total
 }
+
+// DailyStats summarizes a baby's activities over the day starting at
+// startOfDay, which also gives the timezone the day is in
+func DailyStats(db *gorm.DB, babyID uuid.UUID, startOfDay time.Time) (*DailyStatsResponse, error) {
+	// Get activities for the day, querying in UTC
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+	var activities []models.Activity
+	err := db.Preload("FeedActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("PumpActivity").
+		Where("baby_id = ? AND start_time >= ? AND start_time < ?", babyID, startOfDay.UTC(), endOfDay.UTC()).
+		Find(&activities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate statistics
+	counts := make(map[string]int)
+	totals := make(map[string]float64)
+	lastActivities := make(map[string]*time.Time)
+
+	// Track diaper breakdown
+	var diaperBreakdown *DiaperBreakdown
+	wetCount := 0
+	dirtyCount := 0
+
+	for _, activity := range activities {
+		activityType := string(activity.Type)
+		counts[activityType]++
+
+		// Update last activity time
+		if lastActivities[activityType] == nil || activity.StartTime.After(*lastActivities[activityType]) {
+			lastActivities[activityType] = &activity.StartTime
+		}
+
+		// Calculate totals based on activity type
+		switch activity.Type {
+		case models.ActivityTypeFeed:
+			if activity.FeedActivity != nil && activity.FeedActivity.AmountML != nil {
+				totals["feed_amount_ml"] += *activity.FeedActivity.AmountML
+			}
+		case models.ActivityTypePump:
+			if activity.PumpActivity != nil && activity.PumpActivity.AmountML != nil {
+				totals["pump_amount_ml"] += *activity.PumpActivity.AmountML
+			}
+		case models.ActivityTypeDiaper:
+			if activity.DiaperActivity != nil {
+				if activity.DiaperActivity.Wet {
+					wetCount++
+				}
+				if activity.DiaperActivity.Dirty {
+					dirtyCount++
+				}
+			}
+		case models.ActivityTypeSleep:
+			if activity.EndTime != nil {
+				duration := activity.EndTime.Sub(activity.StartTime).Hours()
+				totals["sleep_hours"] += duration
+			}
+		}
+	}
+
+	// Set diaper breakdown if there are any diapers
+	if counts["diaper"] > 0 {
+		diaperBreakdown = &DiaperBreakdown{
+			Wet:   wetCount,
+			Dirty: dirtyCount,
+		}
+	}
+
+	return &DailyStatsResponse{
+		Date:            startOfDay.Format("2006-01-02"),
+		Counts:          counts,
+		Totals:          totals,
+		LastActivities:  lastActivities,
+		DiaperBreakdown: diaperBreakdown,
+	}, nil
+}
+
+// RecentStats looks up when a baby last fed, had a diaper change and slept
+func RecentStats(db *gorm.DB, babyID uuid.UUID) *RecentStatsResponse {
+	response := &RecentStatsResponse{}
+
+	// Get last feed
+	var lastFeed models.Activity
+	err := db.Preload("FeedActivity").
+		Where("baby_id = ? AND type = ?", babyID, models.ActivityTypeFeed).
+		Order("start_time DESC").
+		First(&lastFeed).Error
+	if err == nil {
+		hoursAgo := time.Since(lastFeed.StartTime).Hours()
+		feedInfo := &LastFeedInfo{
+			Time:     lastFeed.StartTime,
+			HoursAgo: hoursAgo,
+		}
+		if lastFeed.FeedActivity != nil {
+			feedInfo.Type = string(lastFeed.FeedActivity.FeedType)
+			feedInfo.AmountML = lastFeed.FeedActivity.AmountML
+		}
+		response.LastFeed = feedInfo
+	}
+
+	// Get last diaper
+	var lastDiaper models.Activity
+	err = db.Preload("DiaperActivity").
+		Where("baby_id = ? AND type = ?", babyID, models.ActivityTypeDiaper).
+		Order("start_time DESC").
+		First(&lastDiaper).Error
+	if err == nil {
+		hoursAgo := time.Since(lastDiaper.StartTime).Hours()
+		diaperInfo := &LastDiaperInfo{
+			Time:     lastDiaper.StartTime,
+			HoursAgo: hoursAgo,
+		}
+		if lastDiaper.DiaperActivity != nil {
+			diaperInfo.Wet = lastDiaper.DiaperActivity.Wet
+			diaperInfo.Dirty = lastDiaper.DiaperActivity.Dirty
+		}
+		response.LastDiaper = diaperInfo
+	}
+
+	// Check if currently sleeping (last sleep activity with no end time)
+	var currentSleep models.Activity
+	err = db.Where("baby_id = ? AND type = ? AND end_time IS NULL", babyID, models.ActivityTypeSleep).
+		Order("start_time DESC").
+		First(&currentSleep).Error
+	response.CurrentlySleeping = (err == nil)
+
+	// Get last completed sleep
+	if !response.CurrentlySleeping {
+		var lastSleep models.Activity
+		err = db.Where("baby_id = ? AND type = ? AND end_time IS NOT NULL", babyID, models.ActivityTypeSleep).
+			Order("start_time DESC").
+			First(&lastSleep).Error
+		if err == nil {
+			sleepInfo := &LastSleepInfo{
+				Ended: lastSleep.EndTime,
+			}
+			if lastSleep.EndTime != nil {
+				duration := lastSleep.EndTime.Sub(lastSleep.StartTime).Hours()
+				sleepInfo.DurationHours = &duration
+			}
+			response.LastSleep = sleepInfo
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+const (
+	syncOpCreate = "create"
+	syncOpUpdate = "update"
+	syncOpDelete = "delete"
+
+	// syncStatusApplied means the change was made
+	syncStatusApplied = "applied"
+	// syncStatusUnchanged means there was nothing to do, e.g. for a create
+	// that had already been synced
+	syncStatusUnchanged = "unchanged"
+	// syncStatusConflict means the activity changed on the server after the
+	// client's change was made, so the server's version was kept
+	syncStatusConflict = "conflict"
+	// syncStatusNotFound means the activity to update doesn't exist
+	syncStatusNotFound = "not_found"
+	// syncStatusForbidden means the user's role on the baby doesn't allow the
+	// change, e.g. a caregiver deleting an activity
+	syncStatusForbidden = "forbidden"
+
+	// syncTokenOverlap is subtracted from the time a sync token is issued at.
+	// A transaction that started before the sync but committed after it
+	// still has an earlier timestamp, so the last few seconds of changes are
+	// sent again to make sure none are missed.
+	syncTokenOverlap = 5 * time.Second
+)
+
+// syncEventTypes are the events published for each kind of change
+var syncEventTypes = map[string]string{
+	syncOpCreate: events.ActivityCreated,
+	syncOpUpdate: events.ActivityUpdated,
+	syncOpDelete: events.ActivityDeleted,
+}
+
+// SyncRequest is a batch of changes made on a client while it was offline
+type SyncRequest struct {
+	BabyID string `json:"baby_id,omitempty"`
+	// SyncToken is the token returned by the client's last sync, if any
+	SyncToken string       `json:"sync_token,omitempty"`
+	Changes   []SyncChange `json:"changes" validate:"max=500,dive"`
+}
+
+// SyncChange is a single change made on a client. UpdatedAt is when it was
+// made, and Activity is required for creates and updates.
+type SyncChange struct {
+	Op        string           `json:"op" validate:"required,oneof=create update delete"`
+	ID        string           `json:"id" validate:"required,uuid"`
+	UpdatedAt time.Time        `json:"updated_at" validate:"required"`
+	Activity  *ActivityRequest `json:"activity,omitempty"`
+}
+
+// SyncResult reports what happened to a change. Activity is the server's
+// version of the activity, if it exists.
+type SyncResult struct {
+	ID       string            `json:"id"`
+	Op       string            `json:"op"`
+	Status   string            `json:"status"`
+	Activity *ActivityResponse `json:"activity,omitempty"`
+}
+
+// SyncResponse represents the response for a sync
+type SyncResponse struct {
+	Results []SyncResult `json:"results"`
+	// Changes are the activities changed since the client's sync token.
+	// Deleted activities have deleted_at set.
+	Changes []ActivityResponse `json:"changes"`
+	// Reset is set when Changes holds every activity rather than only those
+	// changed since the token, because there was no token or it is too old
+	// to tell what has been purged from the trash since
+	Reset     bool   `json:"reset"`
+	SyncToken string `json:"sync_token"`
+}
+
+// Sync handles POST /api/sync. It applies a batch of changes made offline in
+// a single transaction, and returns every activity changed since the
+// client's last sync along with a token for the next one. An update or
+// delete is refused as a conflict if the activity was changed on the server
+// after the client made its change.
+func Sync(c echo.Context) error {
+	// Get user from context
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	// Get database from context
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	cfg, ok := c.Get("config").(*config.Config)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+
+	// Parse request
+	var req SyncRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	for i, change := range req.Changes {
+		if change.Op == syncOpDelete {
+			continue
+		}
+		if change.Activity == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("change %d: activity is required", i))
+		}
+		if err := validateActivityRequest(change.Activity); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("change %d: %v", i, err))
+		}
+	}
+
+	var since time.Time
+	if req.SyncToken != "" {
+		var err error
+		if since, err = parseSyncToken(req.SyncToken); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid sync token")
+		}
+	}
+
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, req.BabyID, models.BabyRoleCaregiver)
+	if err != nil {
+		return babyLookupError(err)
+	}
+	role, err := getBabyRole(db, baby.ID, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check baby access")
+	}
+
+	syncedAt := time.Now()
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	results := make([]SyncResult, len(req.Changes))
+	for i, change := range req.Changes {
+		result, err := applySyncChange(tx, baby, userID, role, change)
+		if err != nil {
+			tx.Rollback()
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				return echo.NewHTTPError(httpErr.Code, fmt.Sprintf("change %d: %v", i, httpErr.Message))
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to sync activities")
+		}
+		results[i] = *result
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sync activities")
+	}
+
+	for _, result := range results {
+		if result.Status == syncStatusApplied {
+			publishEvent(c, baby.ID, syncEventTypes[result.Op], result.Activity)
+		}
+	}
+
+	// Find what changed since the last sync. Without a token, or with one
+	// from before the oldest trash was purged, the client gets everything.
+	reset := since.IsZero()
+	if days := cfg.ActivityTrashRetentionDays; days > 0 && since.Before(syncedAt.AddDate(0, 0, -days)) {
+		reset = true
+	}
+
+	query := db.Preload("FeedActivity").
+		Preload("PumpActivity").
+		Preload("DiaperActivity").
+		Preload("SleepActivity").
+		Preload("GrowthMeasurement").
+		Preload("HealthRecord").
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		Where("baby_id = ?", baby.ID)
+	if !reset {
+		query = query.Unscoped().Where("updated_at >= ? OR deleted_at >= ?", since, since)
+	}
+
+	var activities []models.Activity
+	if err := query.Order("updated_at ASC").Find(&activities).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch activities")
+	}
+
+	response := SyncResponse{
+		Results:   results,
+		Changes:   make([]ActivityResponse, len(activities)),
+		Reset:     reset,
+		SyncToken: formatSyncToken(syncedAt.Add(-syncTokenOverlap)),
+	}
+	for i, activity := range activities {
+		response.Changes[i] = convertActivityToResponse(activity)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// applySyncChange applies one change from a sync in tx. Like DeleteActivity,
+// only owners can delete activities.
+func applySyncChange(tx *gorm.DB, baby *models.Baby, userID string, role models.BabyRole, change SyncChange) (*SyncResult, error) {
+	id := uuid.MustParse(change.ID)
+	result := &SyncResult{ID: id.String(), Op: change.Op}
+
+	var activity models.Activity
+	err := tx.Unscoped().Where("id = ?", id).First(&activity).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	exists := err == nil
+	// Like CreateActivity, this doesn't give away that another baby's
+	// activity has the ID
+	if exists && activity.BabyID != baby.ID {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid activity ID")
+	}
+
+	var current *ActivityResponse
+	if exists {
+		if current, err = loadActivitySnapshot(tx, id); err != nil {
+			return nil, err
+		}
+		result.Activity = current
+	}
+
+	switch change.Op {
+	case syncOpCreate:
+		// Already created by an earlier sync
+		if exists {
+			result.Status = syncStatusUnchanged
+			return result, nil
+		}
+
+		author := parseAuthorID(userID)
+		activity = models.Activity{
+			ID:          id,
+			BabyID:      baby.ID,
+			Type:        models.ActivityType(change.Activity.Type),
+			StartTime:   change.Activity.StartTime,
+			EndTime:     change.Activity.EndTime,
+			Notes:       change.Activity.Notes,
+			CreatedByID: author,
+			UpdatedByID: author,
+		}
+		if err := tx.Create(&activity).Error; err != nil {
+			return nil, err
+		}
+		if err := createActivitySpecificRecord(tx, &activity, change.Activity); err != nil {
+			return nil, err
+		}
+		if err := recordActivityChange(tx, models.RevisionActionCreate, baby.ID, id, userID, nil); err != nil {
+			return nil, err
+		}
+
+	case syncOpUpdate:
+		if !exists {
+			result.Status = syncStatusNotFound
+			return result, nil
+		}
+		if activity.DeletedAt.Valid || activity.UpdatedAt.After(change.UpdatedAt) {
+			result.Status = syncStatusConflict
+			return result, nil
+		}
+
+		if err := claimNextVersion(tx, &models.Activity{}, id, activity.Version); err != nil {
+			return nil, err
+		}
+		activity.Type = models.ActivityType(change.Activity.Type)
+		activity.StartTime = change.Activity.StartTime
+		activity.EndTime = change.Activity.EndTime
+		activity.Notes = change.Activity.Notes
+		activity.UpdatedByID = parseAuthorID(userID)
+		activity.Version++
+		if err := tx.Save(&activity).Error; err != nil {
+			return nil, err
+		}
+		if err := deleteActivitySpecificRecord(tx, id); err != nil {
+			return nil, err
+		}
+		if err := createActivitySpecificRecord(tx, &activity, change.Activity); err != nil {
+			return nil, err
+		}
+		if err := recordActivityChange(tx, models.RevisionActionUpdate, baby.ID, id, userID, current); err != nil {
+			return nil, err
+		}
+
+	case syncOpDelete:
+		// Already deleted, here or by an earlier sync
+		if !exists || activity.DeletedAt.Valid {
+			result.Status = syncStatusUnchanged
+			return result, nil
+		}
+		if activity.UpdatedAt.After(change.UpdatedAt) {
+			result.Status = syncStatusConflict
+			return result, nil
+		}
+		if role != models.BabyRoleOwner {
+			result.Status = syncStatusForbidden
+			return result, nil
+		}
+
+		if err := claimNextVersion(tx, &models.Activity{}, id, activity.Version); err != nil {
+			return nil, err
+		}
+		if err := tx.Delete(&activity).Error; err != nil {
+			return nil, err
+		}
+		if err := recordActivityRevision(tx, models.RevisionActionDelete, baby.ID, id, userID, current, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Status = syncStatusApplied
+	if result.Activity, err = loadActivitySnapshot(tx, id); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// formatSyncToken returns an opaque token for changes from t onwards
+func formatSyncToken(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 36)
+}
+
+// parseSyncToken returns the time a sync token was issued for
+func parseSyncToken(token string) (time.Time, error) {
+	nanos, err := strconv.ParseInt(token, 36, 64)
+	if err != nil || nanos <= 0 {
+		return time.Time{}, fmt.Errorf("invalid sync token")
+	}
+	return time.Unix(0, nanos), nil
+}
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	authMiddleware "github.com/engineervix/bambino/internal/middleware"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+func postSync(t *testing.T, ctx *TestContext, req SyncRequest) (SyncResponse, error) {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "POST", "/api/sync", req)
+	if err := Sync(c); err != nil {
+		return SyncResponse{}, err
+	}
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response SyncResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response, nil
+}
+
+func TestCreateActivityWithClientID(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	id := uuid.New().String()
+	req := ActivityRequest{
+		ID:         id,
+		Type:       "diaper",
+		StartTime:  time.Now(),
+		DiaperData: &DiaperData{Wet: true},
+	}
+
+	c, rec := createEchoContext(ctx, "POST", "/api/activities", req)
+	require.NoError(t, CreateActivity(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var created ActivityResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, id, created.ID)
+
+	// Sending it again returns the activity without creating another
+	c, rec = createEchoContext(ctx, "POST", "/api/activities", req)
+	require.NoError(t, CreateActivity(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var count int64
+	ctx.DB.Model(&models.Activity{}).Where("baby_id = ?", ctx.Baby.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	t.Run("an ID used by another baby is refused", func(t *testing.T) {
+		other := createTestUser(t, ctx.DB)
+		require.NoError(t, ctx.DB.Create(&models.Baby{
+			UserID:    other.ID,
+			Name:      "Other Baby",
+			BirthDate: time.Now().AddDate(0, 0, -60),
+		}).Error)
+
+		c, _ := createEchoContext(ctx, "POST", "/api/activities", req)
+		asUser(c, other)
+		err := CreateActivity(c)
+		require.Error(t, err)
+
+		// The same as for an ID that isn't a UUID, so the activity's
+		// existence isn't given away
+		invalid := req
+		invalid.ID = "not-a-uuid"
+		c, _ = createEchoContext(ctx, "POST", "/api/activities", invalid)
+		asUser(c, other)
+		invalidErr := CreateActivity(c)
+		require.Error(t, invalidErr)
+		assert.Equal(t, invalidErr.(*echo.HTTPError).Code, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("a retry that races the first request gets the activity", func(t *testing.T) {
+		req := req
+		req.ID = uuid.New().String()
+
+		// Another request creates the activity just before this one does
+		raced := false
+		require.NoError(t, ctx.DB.Callback().Create().Before("gorm:create").Register("test:race", func(tx *gorm.DB) {
+			if raced || tx.Statement.Table != "activities" {
+				return
+			}
+			raced = true
+			require.NoError(t, ctx.DB.Create(&models.Activity{
+				ID:        uuid.MustParse(req.ID),
+				BabyID:    ctx.Baby.ID,
+				Type:      models.ActivityTypeDiaper,
+				StartTime: req.StartTime,
+			}).Error)
+		}))
+		defer ctx.DB.Callback().Create().Remove("test:race")
+
+		c, rec := createEchoContext(ctx, "POST", "/api/activities", req)
+		require.NoError(t, CreateActivity(c))
+		assert.True(t, raced)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, req.ID, response.ID)
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+	})
+
+	t.Run("IDs must be UUIDs", func(t *testing.T) {
+		req := req
+		req.ID = "not-a-uuid"
+		c, _ := createEchoContext(ctx, "POST", "/api/activities", req)
+		err := CreateActivity(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestSync(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+	ctx.Config.ActivityTrashRetentionDays = 30
+
+	existing := createTestActivity(t, ctx, "diaper")
+	feedID := uuid.New().String()
+	sleepID := uuid.New().String()
+	offline := time.Now().Add(-time.Hour)
+
+	batch := SyncRequest{
+		Changes: []SyncChange{
+			{
+				Op:        syncOpCreate,
+				ID:        feedID,
+				UpdatedAt: offline,
+				Activity: &ActivityRequest{
+					Type:      "feed",
+					StartTime: offline,
+					FeedData:  &FeedData{FeedType: "bottle", AmountML: floatPtr(90)},
+				},
+			},
+			{
+				Op:        syncOpCreate,
+				ID:        sleepID,
+				UpdatedAt: offline,
+				Activity:  &ActivityRequest{Type: "sleep", StartTime: offline},
+			},
+		},
+	}
+
+	var token string
+
+	t.Run("first sync applies changes and returns everything", func(t *testing.T) {
+		response, err := postSync(t, ctx, batch)
+		require.NoError(t, err)
+
+		require.Len(t, response.Results, 2)
+		for _, result := range response.Results {
+			assert.Equal(t, syncStatusApplied, result.Status)
+			require.NotNil(t, result.Activity)
+		}
+		assert.Equal(t, float64(90), *response.Results[0].Activity.FeedData.AmountML)
+
+		assert.True(t, response.Reset)
+		assert.Len(t, response.Changes, 3)
+		assert.NotEmpty(t, response.SyncToken)
+		token = response.SyncToken
+	})
+
+	t.Run("replaying the batch changes nothing", func(t *testing.T) {
+		response, err := postSync(t, ctx, batch)
+		require.NoError(t, err)
+		for _, result := range response.Results {
+			assert.Equal(t, syncStatusUnchanged, result.Status)
+		}
+
+		var count int64
+		ctx.DB.Model(&models.Activity{}).Where("baby_id = ?", ctx.Baby.ID).Count(&count)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("changes older than the server's are conflicts", func(t *testing.T) {
+		response, err := postSync(t, ctx, SyncRequest{
+			SyncToken: token,
+			Changes: []SyncChange{
+				{
+					Op:        syncOpUpdate,
+					ID:        existing.ID.String(),
+					UpdatedAt: existing.UpdatedAt.Add(-time.Minute),
+					Activity: &ActivityRequest{
+						Type:       "diaper",
+						StartTime:  existing.StartTime,
+						Notes:      "stale",
+						DiaperData: &DiaperData{Dirty: true},
+					},
+				},
+				{
+					Op:        syncOpDelete,
+					ID:        existing.ID.String(),
+					UpdatedAt: existing.UpdatedAt.Add(-time.Minute),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		for _, result := range response.Results {
+			assert.Equal(t, syncStatusConflict, result.Status)
+			require.NotNil(t, result.Activity)
+			assert.NotEqual(t, "stale", result.Activity.Notes)
+		}
+		assert.False(t, response.Reset)
+	})
+
+	t.Run("newer changes are applied and synced back", func(t *testing.T) {
+		response, err := postSync(t, ctx, SyncRequest{
+			SyncToken: token,
+			Changes: []SyncChange{
+				{
+					Op:        syncOpUpdate,
+					ID:        feedID,
+					UpdatedAt: time.Now(),
+					Activity: &ActivityRequest{
+						Type:      "feed",
+						StartTime: offline,
+						FeedData:  &FeedData{FeedType: "bottle", AmountML: floatPtr(120)},
+					},
+				},
+				{Op: syncOpDelete, ID: sleepID, UpdatedAt: time.Now()},
+				{Op: syncOpUpdate, ID: uuid.New().String(), UpdatedAt: time.Now(), Activity: &ActivityRequest{
+					Type: "sleep", StartTime: offline,
+				}},
+			},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, response.Results, 3)
+		assert.Equal(t, syncStatusApplied, response.Results[0].Status)
+		assert.Equal(t, float64(120), *response.Results[0].Activity.FeedData.AmountML)
+		assert.Equal(t, syncStatusApplied, response.Results[1].Status)
+		assert.NotNil(t, response.Results[1].Activity.DeletedAt)
+		assert.Equal(t, syncStatusNotFound, response.Results[2].Status)
+
+		changed := map[string]ActivityResponse{}
+		for _, activity := range response.Changes {
+			changed[activity.ID] = activity
+		}
+		require.Contains(t, changed, feedID)
+		require.Contains(t, changed, sleepID)
+		assert.NotNil(t, changed[sleepID].DeletedAt)
+
+		history := getActivityHistory(t, ctx, feedID)
+		assert.Equal(t, []string{"create", "update"}, []string{history[0].Action, history[1].Action})
+	})
+
+	t.Run("caregivers can't delete activities", func(t *testing.T) {
+		caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+		c, rec := createEchoContext(ctx, "POST", "/api/sync", SyncRequest{
+			Changes: []SyncChange{
+				{Op: syncOpDelete, ID: existing.ID.String(), UpdatedAt: time.Now()},
+			},
+		})
+		asUser(c, caregiver)
+		require.NoError(t, Sync(c))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response SyncResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Results, 1)
+		assert.Equal(t, syncStatusForbidden, response.Results[0].Status)
+
+		var activity models.Activity
+		require.NoError(t, ctx.DB.First(&activity, "id = ?", existing.ID).Error)
+		assert.False(t, activity.DeletedAt.Valid)
+	})
+
+	t.Run("a bad change rolls back the whole batch", func(t *testing.T) {
+		goodID := uuid.New().String()
+		_, err := postSync(t, ctx, SyncRequest{
+			Changes: []SyncChange{
+				{Op: syncOpCreate, ID: goodID, UpdatedAt: time.Now(), Activity: &ActivityRequest{
+					Type: "sleep", StartTime: time.Now(),
+				}},
+				{Op: syncOpCreate, ID: uuid.New().String(), UpdatedAt: time.Now(), Activity: &ActivityRequest{
+					Type: "feed", StartTime: time.Now(),
+				}},
+			},
+		})
+		require.Error(t, err)
+		httpErr := err.(*echo.HTTPError)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Contains(t, httpErr.Message, "change 1")
+
+		var count int64
+		ctx.DB.Unscoped().Model(&models.Activity{}).Where("id = ?", goodID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("invalid sync token", func(t *testing.T) {
+		_, err := postSync(t, ctx, SyncRequest{SyncToken: "!!"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", ctx.DB)
+			c.Set("config", ctx.Config)
+			c.Set("user_id", ctx.User.ID.String())
+			c.Set("username", ctx.User.Username)
+			return next(c)
+		}
+	})
+	e.Use(authMiddleware.Idempotency())
+	e.POST("/api/activities", CreateActivity)
+	e.PUT("/api/activities/:id", UpdateActivity)
+
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(authMiddleware.IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(key, body string) *httptest.ResponseRecorder {
+		return send("POST", "/api/activities", key, body)
+	}
+	countActivities := func() int64 {
+		var count int64
+		ctx.DB.Model(&models.Activity{}).Where("baby_id = ?", ctx.Baby.ID).Count(&count)
+		return count
+	}
+
+	body := `{"type": "diaper", "start_time": "2024-01-01T10:00:00Z", "diaper_data": {"wet": true}}`
+
+	first := post("key-1", body)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(authMiddleware.IdempotentReplayedHeader))
+
+	t.Run("a retry gets the same response", func(t *testing.T) {
+		retry := post("key-1", body)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(authMiddleware.IdempotentReplayedHeader))
+		assert.JSONEq(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, int64(1), countActivities())
+	})
+
+	t.Run("a key can't be used for a different request", func(t *testing.T) {
+		rec := post("key-1", strings.Replace(body, "10:00", "11:00", 1))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, int64(1), countActivities())
+	})
+
+	t.Run("a new key makes a new request", func(t *testing.T) {
+		rec := post("key-2", body)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, int64(2), countActivities())
+	})
+
+	t.Run("errors are replayed too", func(t *testing.T) {
+		rec := post("key-3", `{"type": "diaper"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = post("key-3", `{"type": "diaper"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get(authMiddleware.IdempotentReplayedHeader))
+	})
+
+	t.Run("old keys expire", func(t *testing.T) {
+		require.NoError(t, ctx.DB.Model(&models.IdempotencyKey{}).
+			Where("idempotency_key = ?", "key-1").
+			UpdateColumn("created_at", time.Now().Add(-25*time.Hour)).Error)
+
+		rec := post("key-1", body)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get(authMiddleware.IdempotentReplayedHeader))
+		assert.Equal(t, int64(3), countActivities())
+	})
+
+	t.Run("a replayed update keeps its ETag", func(t *testing.T) {
+		activity := createTestActivity(t, ctx, "diaper")
+		path := "/api/activities/" + activity.ID.String()
+		update := `{"type": "diaper", "start_time": "2024-01-01T12:00:00Z", "diaper_data": {"dirty": true}}`
+
+		first := send("PUT", path, "key-4", update)
+		require.Equal(t, http.StatusOK, first.Code)
+		require.NotEmpty(t, first.Header().Get("ETag"))
+
+		retry := send("PUT", path, "key-4", update)
+		assert.Equal(t, http.StatusOK, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(authMiddleware.IdempotentReplayedHeader))
+		assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"))
+	})
+
+	t.Run("bodies that are too large are refused", func(t *testing.T) {
+		rec := post("key-5", `{"notes": "`+strings.Repeat("a", 3<<20)+`"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
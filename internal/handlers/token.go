@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// APITokenRequest represents the request body for creating an API token
+type APITokenRequest struct {
+	Name          string `json:"name" validate:"required,max=100"`
+	Scope         string `json:"scope" validate:"omitempty,oneof=read write"`
+	BabyID        string `json:"baby_id,omitempty" validate:"omitempty,uuid"`
+	ExpiresInDays int    `json:"expires_in_days" validate:"omitempty,min=1,max=3650"`
+}
+
+// APITokenResponse represents an API token. The token itself is only
+// returned when it is created, since only its hash is stored.
+type APITokenResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scope      string     `json:"scope"`
+	BabyID     *string    `json:"baby_id,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// apiTokenDisplayLength is how much of a token is kept to identify it in listings
+const apiTokenDisplayLength = 12
+
+// CreateAPIToken handles POST /api/auth/tokens
+func CreateAPIToken(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot manage API tokens")
+	}
+
+	var req APITokenRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	scope := models.APITokenScopeWrite
+	if req.Scope != "" {
+		scope = models.APITokenScope(req.Scope)
+	}
+
+	token := models.APIToken{
+		UserID: uid,
+		Name:   req.Name,
+		Scope:  scope,
+	}
+
+	// Limiting a token to a baby requires access to that baby
+	if req.BabyID != "" {
+		baby, err := getBabyByIDForUser(db, req.BabyID, userID)
+		if err != nil {
+			return babyLookupError(err)
+		}
+		token.BabyID = &baby.ID
+	}
+
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	raw, err := utils.GenerateAPIToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate API token")
+	}
+	token.TokenHash = utils.HashToken(raw)
+	token.Prefix = raw[:apiTokenDisplayLength]
+
+	if err := db.Create(&token).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create API token")
+	}
+
+	response := convertAPITokenToResponse(token)
+	response.Token = raw
+	return c.JSON(http.StatusCreated, response)
+}
+
+// GetAPITokens handles GET /api/auth/tokens
+func GetAPITokens(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var tokens []models.APIToken
+	if err := db.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch API tokens")
+	}
+
+	response := make([]APITokenResponse, len(tokens))
+	for i, token := range tokens {
+		response[i] = convertAPITokenToResponse(token)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPIToken handles DELETE /api/auth/tokens/:id
+func RevokeAPIToken(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot manage API tokens")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid token ID")
+	}
+
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke API token")
+	}
+
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "API token not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "API token revoked successfully",
+	})
+}
+
+// convertAPITokenToResponse converts a model to response format
+func convertAPITokenToResponse(token models.APIToken) APITokenResponse {
+	resp := APITokenResponse{
+		ID:         token.ID.String(),
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scope:      string(token.Scope),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+	if token.BabyID != nil {
+		babyID := token.BabyID.String()
+		resp.BabyID = &babyID
+	}
+	return resp
+}
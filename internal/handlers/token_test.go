@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	authMiddleware "github.com/engineervix/bambino/internal/middleware"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// newAPIServer returns an echo instance serving a subset of the protected API
+func newAPIServer(ctx *TestContext) *echo.Echo {
+	e := echo.New()
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", ctx.DB)
+			c.Set("config", ctx.Config)
+			return next(c)
+		}
+	})
+
+	api := e.Group("/api")
+	api.Use(authMiddleware.RequireAuthJSON())
+	api.GET("/auth/me", GetCurrentUser)
+	api.POST("/auth/tokens", CreateAPIToken)
+	api.GET("/babies", GetBabies)
+	api.GET("/activities", GetActivities)
+	api.POST("/activities", CreateActivity)
+
+	return e
+}
+
+// createTestAPIToken creates an API token for the test user through the API
+func createTestAPIToken(t *testing.T, ctx *TestContext, req APITokenRequest) APITokenResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "POST", "/api/auth/tokens", req)
+	require.NoError(t, CreateAPIToken(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response APITokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotEmpty(t, response.Token)
+
+	return response
+}
+
+func bearerRequest(e *echo.Echo, method, path, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPITokens(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newAPIServer(ctx)
+	feed := `{"type":"feed","start_time":"` + time.Now().Format(time.RFC3339) + `","feed_data":{"feed_type":"bottle","amount_ml":90}}`
+
+	t.Run("token is stored hashed and listed without secret", func(t *testing.T) {
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Shortcut"})
+		assert.Equal(t, "write", token.Scope)
+		assert.True(t, strings.HasPrefix(token.Token, "bmb_"))
+
+		var stored models.APIToken
+		require.NoError(t, ctx.DB.First(&stored, "id = ?", token.ID).Error)
+		assert.NotEqual(t, token.Token, stored.TokenHash)
+
+		c, rec := createEchoContext(ctx, "GET", "/api/auth/tokens", nil)
+		require.NoError(t, GetAPITokens(c))
+
+		var tokens []APITokenResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+		require.Len(t, tokens, 1)
+		assert.Empty(t, tokens[0].Token)
+		assert.Equal(t, token.Token[:12], tokens[0].Prefix)
+	})
+
+	t.Run("bearer token authenticates requests", func(t *testing.T) {
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Home Assistant"})
+
+		rec := bearerRequest(e, http.MethodGet, "/api/auth/me", token.Token, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var user UserResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+		assert.Equal(t, ctx.User.Username, user.Username)
+
+		rec = bearerRequest(e, http.MethodPost, "/api/activities", token.Token, feed)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var stored models.APIToken
+		require.NoError(t, ctx.DB.First(&stored, "id = ?", token.ID).Error)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("invalid token is rejected", func(t *testing.T) {
+		rec := bearerRequest(e, http.MethodGet, "/api/activities", "bmb_not-a-token", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("read-only token cannot write", func(t *testing.T) {
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Dashboard", Scope: "read"})
+
+		rec := bearerRequest(e, http.MethodGet, "/api/activities", token.Token, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = bearerRequest(e, http.MethodPost, "/api/activities", token.Token, feed)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("tokens cannot create tokens", func(t *testing.T) {
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Script"})
+
+		rec := bearerRequest(e, http.MethodPost, "/api/auth/tokens", token.Token, `{"name":"escalated"}`)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Temporary", ExpiresInDays: 1})
+		require.NoError(t, ctx.DB.Model(&models.APIToken{}).
+			Where("id = ?", token.ID).
+			UpdateColumn("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		rec := bearerRequest(e, http.MethodGet, "/api/activities", token.Token, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("token limited to one baby", func(t *testing.T) {
+		otherBaby := &models.Baby{
+			UserID:    ctx.User.ID,
+			Name:      "Newest Baby",
+			BirthDate: time.Now().AddDate(0, 0, -1),
+		}
+		require.NoError(t, ctx.DB.Create(otherBaby).Error)
+
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Nursery", BabyID: ctx.Baby.ID.String()})
+
+		// Listing only shows the token's baby
+		rec := bearerRequest(e, http.MethodGet, "/api/babies", token.Token, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var babies []BabyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &babies))
+		require.Len(t, babies, 1)
+		assert.Equal(t, ctx.Baby.ID.String(), babies[0].ID)
+
+		// Without a baby ID the token's baby is used instead of the newest one
+		rec = bearerRequest(e, http.MethodPost, "/api/activities", token.Token, feed)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var created ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		var activity models.Activity
+		require.NoError(t, ctx.DB.First(&activity, "id = ?", created.ID).Error)
+		assert.Equal(t, ctx.Baby.ID, activity.BabyID)
+
+		// Other babies are not reachable
+		rec = bearerRequest(e, http.MethodGet, "/api/activities?baby_id="+otherBaby.ID.String(), token.Token, "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("revoke token", func(t *testing.T) {
+		token := createTestAPIToken(t, ctx, APITokenRequest{Name: "Stream Deck"})
+
+		c, _ := createEchoContext(ctx, "DELETE", "/api/auth/tokens/"+token.ID, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(token.ID)
+		require.NoError(t, RevokeAPIToken(c))
+
+		rec := bearerRequest(e, http.MethodGet, "/api/activities", token.Token, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
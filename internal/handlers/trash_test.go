@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+func deleteTestActivity(t *testing.T, ctx *TestContext, id uuid.UUID) {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "DELETE", "/api/activities/"+id.String(), nil)
+	c.SetParamNames("id")
+	c.SetParamValues(id.String())
+	require.NoError(t, DeleteActivity(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func getActivityTrash(t *testing.T, ctx *TestContext) ActivityTrashResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "GET", "/api/activities/trash", nil)
+	require.NoError(t, GetActivityTrash(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response ActivityTrashResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func TestActivityTrash(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+	ctx.Config.ActivityTrashRetentionDays = 30
+
+	amount := 120.0
+	feed := createTestActivity(t, ctx, "feed")
+	require.NoError(t, ctx.DB.Create(&models.FeedActivity{
+		ActivityID: feed.ID,
+		FeedType:   models.FeedTypeBottle,
+		AmountML:   &amount,
+	}).Error)
+	kept := createTestActivity(t, ctx, "diaper")
+	kept.StartTime = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, ctx.DB.Save(kept).Error)
+
+	deleteTestActivity(t, ctx, feed.ID)
+
+	t.Run("deleted activity is hidden everywhere else", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/activities", nil)
+		require.NoError(t, GetActivities(c))
+		var list ActivityListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Equal(t, int64(1), list.Total)
+		assert.Equal(t, kept.ID.String(), list.Activities[0].ID)
+
+		c, _ = createEchoContext(ctx, "GET", "/api/activities/"+feed.ID.String(), nil)
+		c.SetParamNames("id")
+		c.SetParamValues(feed.ID.String())
+		err := GetActivity(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+
+		c, rec = createEchoContext(ctx, "GET", "/api/stats/recent", nil)
+		require.NoError(t, GetRecentStats(c))
+		var stats RecentStatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+		assert.Nil(t, stats.LastFeed)
+		assert.NotNil(t, stats.LastDiaper)
+	})
+
+	t.Run("trash lists deleted activities with their details", func(t *testing.T) {
+		trash := getActivityTrash(t, ctx)
+		assert.Equal(t, 30, trash.RetentionDays)
+		require.Len(t, trash.Activities, 1)
+		assert.Equal(t, feed.ID.String(), trash.Activities[0].ID)
+		require.NotNil(t, trash.Activities[0].DeletedAt)
+		require.NotNil(t, trash.Activities[0].FeedData)
+		assert.Equal(t, amount, *trash.Activities[0].FeedData.AmountML)
+	})
+
+	t.Run("restore puts the activity back", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "POST", "/api/activities/"+feed.ID.String()+"/restore", nil)
+		c.SetParamNames("id")
+		c.SetParamValues(feed.ID.String())
+		require.NoError(t, RestoreActivity(c))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var restored ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &restored))
+		assert.Nil(t, restored.DeletedAt)
+		require.NotNil(t, restored.FeedData)
+
+		assert.Empty(t, getActivityTrash(t, ctx).Activities)
+
+		// It can't be restored twice
+		c, _ = createEchoContext(ctx, "POST", "/api/activities/"+feed.ID.String()+"/restore", nil)
+		c.SetParamNames("id")
+		c.SetParamValues(feed.ID.String())
+		err := RestoreActivity(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("only owners can see the trash and restore", func(t *testing.T) {
+		caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+
+		c, _ := createEchoContext(ctx, "GET", "/api/activities/trash", nil)
+		asUser(c, caregiver)
+		err := GetActivityTrash(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("purge removes old trash for good", func(t *testing.T) {
+		deleteTestActivity(t, ctx, feed.ID)
+		deleteTestActivity(t, ctx, kept.ID)
+
+		// Only the feed has been in the trash long enough
+		require.NoError(t, ctx.DB.Unscoped().Model(&models.Activity{}).
+			Where("id = ?", feed.ID).
+			UpdateColumn("deleted_at", time.Now().AddDate(0, 0, -31)).Error)
+
+		require.NoError(t, utils.PurgeTrashedActivities(ctx.DB, time.Now().AddDate(0, 0, -30)))
+
+		var count int64
+		ctx.DB.Unscoped().Model(&models.Activity{}).Where("id = ?", feed.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		ctx.DB.Model(&models.FeedActivity{}).Where("activity_id = ?", feed.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		trash := getActivityTrash(t, ctx)
+		require.Len(t, trash.Activities, 1)
+		assert.Equal(t, kept.ID.String(), trash.Activities[0].ID)
+	})
+}
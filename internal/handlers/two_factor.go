@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// TwoFactorStatusResponse describes a user's two-factor authentication
+type TwoFactorStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorSetupResponse holds a new TOTP secret to add to an authenticator
+// app, usually by showing the provisioning URI as a QR code
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorCodeRequest represents a request carrying a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorDisableRequest represents the request body for turning off
+// two-factor authentication
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// RecoveryCodesResponse returns recovery codes. They are only shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// GetTwoFactorStatus handles GET /api/auth/2fa
+func GetTwoFactorStatus(c echo.Context) error {
+	db, user, err := twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+
+	var remaining int64
+	if err := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&remaining).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count recovery codes")
+	}
+
+	return c.JSON(http.StatusOK, TwoFactorStatusResponse{
+		Enabled:                user.TOTPEnabled(),
+		EnabledAt:              user.TOTPEnabledAt,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// SetupTwoFactor handles POST /api/auth/2fa/setup. It generates a new secret,
+// which only takes effect once confirmed through EnableTwoFactor.
+func SetupTwoFactor(c echo.Context) error {
+	db, user, err := twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled() {
+		return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate secret")
+	}
+
+	if err := db.Model(user).Update("totp_secret", secret).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save secret")
+	}
+
+	return c.JSON(http.StatusOK, TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, user.Username),
+	})
+}
+
+// EnableTwoFactor handles POST /api/auth/2fa/enable. The user proves their
+// authenticator app works by giving a code, and gets their recovery codes.
+func EnableTwoFactor(c echo.Context) error {
+	db, user, err := twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if user.TOTPEnabled() {
+		return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "two-factor authentication has not been set up")
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid two-factor code")
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"totp_enabled_at": now,
+		"totp_last_step":  step,
+	}).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to enable two-factor authentication")
+	}
+
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create recovery codes")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to enable two-factor authentication")
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor handles POST /api/auth/2fa/disable
+func DisableTwoFactor(c echo.Context) error {
+	db, user, err := twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorDisableRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if !user.TOTPEnabled() {
+		return echo.NewHTTPError(http.StatusBadRequest, "two-factor authentication is not enabled")
+	}
+
+	valid, err := utils.VerifyPassword(req.Password, user.PasswordHash)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "password verification error")
+	}
+	if !valid {
+		return echo.NewHTTPError(http.StatusUnauthorized, "password is incorrect")
+	}
+
+	ok, err := verifySecondFactor(db, user, req.Code)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify two-factor code")
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid two-factor code")
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to disable two-factor authentication")
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete recovery codes")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to disable two-factor authentication")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes handles POST /api/auth/2fa/recovery-codes,
+// replacing all existing recovery codes
+func RegenerateRecoveryCodes(c echo.Context) error {
+	db, user, err := twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if !user.TOTPEnabled() {
+		return echo.NewHTTPError(http.StatusBadRequest, "two-factor authentication is not enabled")
+	}
+
+	ok, err := verifySecondFactor(db, user, req.Code)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify two-factor code")
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid two-factor code")
+	}
+
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create recovery codes")
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyTwoFactorLogin handles POST /api/auth/login/2fa, the second step of
+// logging in for users with two-factor authentication. It accepts either a
+// TOTP code or a recovery code.
+func VerifyTwoFactorLogin(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "code is required")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	userID, err := utils.GetPendingTwoFactorUser(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "no pending login, sign in again")
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusUnauthorized, "no pending login, sign in again")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	// Codes are throttled like passwords
+	attempt := newLoginAttempt(c, user.Username)
+	attempt.UserID = &user.ID
+	if err := checkLoginThrottle(c, db, attempt); err != nil {
+		return err
+	}
+
+	valid, err := verifySecondFactor(db, &user, req.Code)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify two-factor code")
+	}
+	if !valid {
+		if err := recordLoginAttempt(db, attempt, models.LoginResultInvalidCredentials); err != nil {
+			return err
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid two-factor code")
+	}
+
+	if err := utils.CreateUserSession(c, user.ID, user.Username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "session creation error")
+	}
+
+	if err := recordLoginAttempt(db, attempt, models.LoginResultSuccess); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, LoginResponse{
+		Message:  "login successful",
+		Username: user.Username,
+	})
+}
+
+// twoFactorUser loads the current user for the two-factor management
+// endpoints, which need a real login rather than an API token
+func twoFactorUser(c echo.Context) (*gorm.DB, *models.User, error) {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return nil, nil, echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	if _, ok := c.Get("api_token").(*models.APIToken); ok {
+		return nil, nil, echo.NewHTTPError(http.StatusForbidden, "API tokens cannot manage two-factor authentication")
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		}
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "database error")
+	}
+
+	return db, &user, nil
+}
+
+// verifySecondFactor checks a TOTP code, falling back to a recovery code.
+// Accepted codes are used up: the TOTP time step can't be replayed and the
+// recovery code is marked as used.
+func verifySecondFactor(db *gorm.DB, user *models.User, code string) (bool, error) {
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		// Guard against two requests racing with the same code
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			UpdateColumn("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TOTPLastStep = step
+		return result.RowsAffected > 0, nil
+	}
+
+	normalized := utils.NormalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(normalized)).
+		UpdateColumn("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and issues new ones
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		records[i] = models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+		}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
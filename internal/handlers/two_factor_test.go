@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// postJSON sends a JSON POST with an optional session cookie
+func postJSON(e *echo.Echo, path string, cookie *http.Cookie, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Real-IP", "192.0.2.10")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// responseCookie returns the session cookie set by a response, if any
+func responseCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == utils.SessionName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, step)
+	require.NoError(t, err)
+	return code
+}
+
+func TestTwoFactorAuth(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	e := newSessionServer(ctx)
+	createPasswordUser(t, ctx, "parent", "password123")
+	cookie := loginDevice(t, e, "parent", "password123", "Laptop", "192.0.2.10")
+	credentials := `{"username":"parent","password":"password123"}`
+
+	var secret string
+	var recoveryCodes []string
+	step := utils.TOTPStep(time.Now())
+
+	t.Run("enrol", func(t *testing.T) {
+		rec := postJSON(e, "/api/auth/2fa/setup", cookie, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var setup TwoFactorSetupResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &setup))
+		assert.True(t, strings.HasPrefix(setup.ProvisioningURI, "otpauth://totp/Bambino:parent?"))
+		assert.Contains(t, setup.ProvisioningURI, "secret="+setup.Secret)
+		secret = setup.Secret
+
+		// Not enforced until confirmed
+		rec = postJSON(e, "/api/auth/login", nil, credentials)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "two_factor_required")
+
+		rec = postJSON(e, "/api/auth/2fa/enable", cookie, `{"code":"000000"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = postJSON(e, "/api/auth/2fa/enable", cookie, `{"code":"`+totpCode(t, secret, step)+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var codes RecoveryCodesResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &codes))
+		assert.Len(t, codes.RecoveryCodes, 10)
+		recoveryCodes = codes.RecoveryCodes
+
+		rec = cookieRequest(e, http.MethodGet, "/api/auth/2fa", cookie)
+		var status TwoFactorStatusResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.True(t, status.Enabled)
+		assert.Equal(t, int64(10), status.RecoveryCodesRemaining)
+
+		rec = postJSON(e, "/api/auth/2fa/setup", cookie, "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("login needs a code", func(t *testing.T) {
+		rec := postJSON(e, "/api/auth/login", nil, credentials)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response LoginResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.True(t, response.TwoFactorRequired)
+
+		pending := responseCookie(rec)
+		require.NotNil(t, pending)
+
+		// The password alone doesn't log in
+		assert.Equal(t, http.StatusUnauthorized, cookieRequest(e, http.MethodGet, "/api/auth/me", pending).Code)
+
+		// The code used to enrol can't be replayed
+		rec = postJSON(e, "/api/auth/login/2fa", pending, `{"code":"`+totpCode(t, secret, step)+`"}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = postJSON(e, "/api/auth/login/2fa", pending, `{"code":"`+totpCode(t, secret, step+1)+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		session := responseCookie(rec)
+		require.NotNil(t, session)
+		assert.Equal(t, http.StatusOK, cookieRequest(e, http.MethodGet, "/api/auth/me", session).Code)
+	})
+
+	t.Run("recovery codes work once", func(t *testing.T) {
+		rec := postJSON(e, "/api/auth/login", nil, credentials)
+		pending := responseCookie(rec)
+		require.NotNil(t, pending)
+
+		code := strings.ToUpper(recoveryCodes[0])
+		rec = postJSON(e, "/api/auth/login/2fa", pending, `{"code":"`+code+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = postJSON(e, "/api/auth/login", nil, credentials)
+		pending = responseCookie(rec)
+		rec = postJSON(e, "/api/auth/login/2fa", pending, `{"code":"`+code+`"}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("code step needs a pending login", func(t *testing.T) {
+		rec := postJSON(e, "/api/auth/login/2fa", nil, `{"code":"`+recoveryCodes[1]+`"}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		rec := postJSON(e, "/api/auth/2fa/disable", cookie, `{"password":"wrong","code":"`+recoveryCodes[1]+`"}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = postJSON(e, "/api/auth/2fa/disable", cookie, `{"password":"password123","code":"`+recoveryCodes[1]+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = postJSON(e, "/api/auth/login", nil, credentials)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "two_factor_required")
+	})
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// webhookSecretPrefix marks webhook secrets so they are easy to recognise
+const webhookSecretPrefix = "whsec_"
+
+// WebhookRequest represents the request body for creating a webhook
+type WebhookRequest struct {
+	BabyID        string   `json:"baby_id,omitempty" validate:"omitempty,uuid"`
+	URL           string   `json:"url" validate:"required,url,max=2048"`
+	ActivityTypes []string `json:"activity_types,omitempty" validate:"dive,oneof=feed pump diaper sleep growth health milestone"`
+}
+
+// UpdateWebhookRequest represents the request body for updating a webhook
+type UpdateWebhookRequest struct {
+	URL           *string   `json:"url,omitempty" validate:"omitempty,url,max=2048"`
+	ActivityTypes *[]string `json:"activity_types,omitempty" validate:"omitempty,dive,oneof=feed pump diaper sleep growth health milestone"`
+	Active        *bool     `json:"active,omitempty"`
+}
+
+// WebhookResponse represents a webhook. The secret is only returned when
+// the webhook is created.
+type WebhookResponse struct {
+	ID            string    `json:"id"`
+	BabyID        string    `json:"baby_id"`
+	URL           string    `json:"url"`
+	Secret        string    `json:"secret,omitempty"`
+	ActivityTypes []string  `json:"activity_types"`
+	Active        bool      `json:"active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryResponse represents an attempt to send an event to a webhook
+type WebhookDeliveryResponse struct {
+	ID             string          `json:"id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookDeliveryListResponse represents a page of a webhook's deliveries
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int64                     `json:"total"`
+	Page       int                       `json:"page"`
+	PageSize   int                       `json:"page_size"`
+	TotalPages int                       `json:"total_pages"`
+}
+
+// CreateWebhook handles POST /api/webhooks. Caregivers and owners may have
+// a baby's activity events sent to a webhook.
+func CreateWebhook(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req WebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := checkWebhookURL(c, req.URL); err != nil {
+		return err
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user ID")
+	}
+
+	// Get the baby the request is scoped to
+	baby, err := resolveBaby(c, db, userID, req.BabyID, models.BabyRoleCaregiver)
+	if err != nil {
+		return babyLookupError(err)
+	}
+
+	secret, err := utils.GenerateToken(utils.DefaultTokenLength)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate webhook secret")
+	}
+
+	hook := models.Webhook{
+		UserID:        uid,
+		BabyID:        baby.ID,
+		URL:           req.URL,
+		Secret:        webhookSecretPrefix + secret,
+		ActivityTypes: strings.Join(req.ActivityTypes, ","),
+		Active:        true,
+	}
+	if err := db.Create(&hook).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create webhook")
+	}
+
+	response := convertWebhookToResponse(hook)
+	response.Secret = hook.Secret
+	return c.JSON(http.StatusCreated, response)
+}
+
+// GetWebhooks handles GET /api/webhooks, listing the user's webhooks
+func GetWebhooks(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	query := db.Where("user_id = ?", userID)
+
+	// API tokens limited to one baby cannot see the others
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		query = query.Where("baby_id = ?", *token.BabyID)
+	}
+
+	if babyID := c.QueryParam("baby_id"); babyID != "" {
+		id, err := uuid.Parse(babyID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid baby ID")
+		}
+		query = query.Where("baby_id = ?", id)
+	}
+
+	var hooks []models.Webhook
+	if err := query.Order("created_at DESC").Find(&hooks).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch webhooks")
+	}
+
+	response := make([]WebhookResponse, len(hooks))
+	for i, hook := range hooks {
+		response[i] = convertWebhookToResponse(hook)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// UpdateWebhook handles PUT /api/webhooks/:id
+func UpdateWebhook(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.URL != nil {
+		if err := checkWebhookURL(c, *req.URL); err != nil {
+			return err
+		}
+	}
+
+	hook, err := findWebhook(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != nil {
+		// Pointing a webhook somewhere new takes the same role as making one
+		role, err := getBabyRole(db, hook.BabyID, userID)
+		if err != nil || !role.Includes(models.BabyRoleCaregiver) {
+			return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions for this baby")
+		}
+		updates["url"] = *req.URL
+	}
+	if req.ActivityTypes != nil {
+		updates["activity_types"] = strings.Join(*req.ActivityTypes, ",")
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if len(updates) > 0 {
+		if err := db.Model(hook).Updates(updates).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update webhook")
+		}
+	}
+
+	if err := db.First(hook, "id = ?", hook.ID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch webhook")
+	}
+
+	return c.JSON(http.StatusOK, convertWebhookToResponse(*hook))
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/:id, dropping any deliveries
+// still queued for it
+func DeleteWebhook(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	hook, err := findWebhook(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	// Don't rely on the foreign key cascade, which SQLite only applies when
+	// foreign keys are switched on
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete webhook")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "webhook deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries handles GET /api/webhooks/:id/deliveries, the log of
+// events sent or waiting to be sent to a webhook, newest first. It can be
+// filtered by status.
+func GetWebhookDeliveries(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+	}
+
+	hook, err := findWebhook(c, db, userID)
+	if err != nil {
+		return err
+	}
+
+	query := db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.QueryParam("page_size"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count deliveries")
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&deliveries).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch deliveries")
+	}
+
+	response := WebhookDeliveryListResponse{
+		Deliveries: make([]WebhookDeliveryResponse, len(deliveries)),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}
+	for i, delivery := range deliveries {
+		response.Deliveries[i] = convertWebhookDeliveryToResponse(delivery)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// findWebhook loads the user's webhook named in the request
+func findWebhook(c echo.Context, db *gorm.DB, userID string) (*models.Webhook, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid webhook ID")
+	}
+
+	query := db.Where("id = ? AND user_id = ?", id, userID)
+
+	// API tokens limited to one baby cannot reach the others
+	if token, ok := c.Get("api_token").(*models.APIToken); ok && token.BabyID != nil {
+		query = query.Where("baby_id = ?", *token.BabyID)
+	}
+
+	var hook models.Webhook
+	if err := query.First(&hook).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch webhook")
+	}
+	return &hook, nil
+}
+
+// checkWebhookURL makes sure a URL can be sent webhooks. Addresses on the
+// server's own network are refused unless the configuration allows them.
+func checkWebhookURL(c echo.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "webhook URL must use http or https")
+	}
+
+	cfg, ok := c.Get("config").(*config.Config)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+	networks, err := cfg.OutboundNetworks()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+	}
+	if err := utils.NewOutboundPolicy(networks).CheckURL(raw); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "webhook URL must not point to a "+
+			"loopback, private or link-local address")
+	}
+	return nil
+}
+
+func convertWebhookToResponse(hook models.Webhook) WebhookResponse {
+	types := make([]string, 0)
+	for _, t := range hook.Types() {
+		types = append(types, string(t))
+	}
+
+	return WebhookResponse{
+		ID:            hook.ID.String(),
+		BabyID:        hook.BabyID.String(),
+		URL:           hook.URL,
+		ActivityTypes: types,
+		Active:        hook.Active,
+		CreatedAt:     hook.CreatedAt,
+		UpdatedAt:     hook.UpdatedAt,
+	}
+}
+
+func convertWebhookDeliveryToResponse(delivery models.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             delivery.ID.String(),
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		Payload:        json.RawMessage(delivery.Payload),
+		CreatedAt:      delivery.CreatedAt,
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return resp
+}
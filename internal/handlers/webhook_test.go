@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/webhooks"
+)
+
+// webhookReceiver records the requests sent to it, answering with status
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{status: http.StatusNoContent}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		w.WriteHeader(receiver.status)
+		if receiver.status >= 300 {
+			w.Write([]byte("try again later"))
+		}
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) respondWith(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func createWebhook(t *testing.T, ctx *TestContext, req WebhookRequest) WebhookResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "POST", "/api/webhooks", req)
+	require.NoError(t, CreateWebhook(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response WebhookResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func getWebhookDeliveries(t *testing.T, ctx *TestContext, id string) []WebhookDeliveryResponse {
+	t.Helper()
+
+	c, rec := createEchoContext(ctx, "GET", "/api/webhooks/"+id+"/deliveries", nil)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	require.NoError(t, GetWebhookDeliveries(c))
+
+	var response WebhookDeliveryListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Deliveries
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	var hook WebhookResponse
+
+	t.Run("create returns the secret once", func(t *testing.T) {
+		hook = createWebhook(t, ctx, WebhookRequest{
+			URL:           "https://example.com/hook",
+			ActivityTypes: []string{"sleep", "feed"},
+		})
+		assert.Equal(t, ctx.Baby.ID.String(), hook.BabyID)
+		assert.Equal(t, []string{"sleep", "feed"}, hook.ActivityTypes)
+		assert.True(t, hook.Active)
+		assert.Contains(t, hook.Secret, webhookSecretPrefix)
+
+		c, rec := createEchoContext(ctx, "GET", "/api/webhooks", nil)
+		require.NoError(t, GetWebhooks(c))
+		var listed []WebhookResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+		require.Len(t, listed, 1)
+		assert.Equal(t, hook.ID, listed[0].ID)
+		assert.Empty(t, listed[0].Secret)
+	})
+
+	t.Run("invalid webhooks are refused", func(t *testing.T) {
+		for _, req := range []WebhookRequest{
+			{URL: "not a url"},
+			{URL: "ftp://example.com/hook"},
+			{URL: "https://example.com/hook", ActivityTypes: []string{"nap"}},
+			{URL: "http://127.0.0.1:8080/hook"},
+			{URL: "http://10.0.0.5/hook"},
+			{URL: "http://169.254.169.254/latest/meta-data"},
+			{URL: "http://[::1]/hook"},
+		} {
+			c, _ := createEchoContext(ctx, "POST", "/api/webhooks", req)
+			err := CreateWebhook(c)
+			require.Error(t, err, req)
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		}
+	})
+
+	t.Run("caregivers can add webhooks but viewers and strangers can't", func(t *testing.T) {
+		caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+		c, rec := createEchoContext(ctx, "POST", "/api/webhooks", WebhookRequest{
+			BabyID: ctx.Baby.ID.String(),
+			URL:    "https://example.com/caregiver",
+		})
+		asUser(c, caregiver)
+		require.NoError(t, CreateWebhook(c))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		viewer := addTestMember(t, ctx, models.BabyRoleViewer)
+		c, _ = createEchoContext(ctx, "POST", "/api/webhooks", WebhookRequest{
+			BabyID: ctx.Baby.ID.String(),
+			URL:    "https://example.com/viewer",
+		})
+		asUser(c, viewer)
+		err := CreateWebhook(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+
+		stranger := createTestUser(t, ctx.DB)
+		c, _ = createEchoContext(ctx, "POST", "/api/webhooks", WebhookRequest{
+			BabyID: ctx.Baby.ID.String(),
+			URL:    "https://example.com/stranger",
+		})
+		asUser(c, stranger)
+		err = CreateWebhook(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+
+		// Nor can they see someone else's webhook
+		c, _ = createEchoContext(ctx, "GET", "/api/webhooks/"+hook.ID+"/deliveries", nil)
+		c.SetParamNames("id")
+		c.SetParamValues(hook.ID)
+		asUser(c, viewer)
+		err = GetWebhookDeliveries(c)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		active := false
+		types := []string{"diaper"}
+		c, rec := createEchoContext(ctx, "PUT", "/api/webhooks/"+hook.ID, UpdateWebhookRequest{
+			ActivityTypes: &types,
+			Active:        &active,
+		})
+		c.SetParamNames("id")
+		c.SetParamValues(hook.ID)
+		require.NoError(t, UpdateWebhook(c))
+
+		var updated WebhookResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, []string{"diaper"}, updated.ActivityTypes)
+		assert.False(t, updated.Active)
+		assert.Equal(t, hook.URL, updated.URL)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "DELETE", "/api/webhooks/"+hook.ID, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(hook.ID)
+		require.NoError(t, DeleteWebhook(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var count int64
+		ctx.DB.Model(&models.Webhook{}).Where("id = ?", hook.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	ctx := setupTestContext(t)
+	defer ctx.Cleanup()
+
+	// The receiver listens on loopback
+	ctx.Config.OutboundAllowedNetworks = "127.0.0.1"
+	receiver := newWebhookReceiver(t)
+	hook := createWebhook(t, ctx, WebhookRequest{
+		URL:           receiver.URL,
+		ActivityTypes: []string{"sleep"},
+	})
+	dispatcher := webhooks.NewDispatcher(ctx.DB, ctx.Config)
+
+	logDiaper := func() {
+		c, _ := createEchoContext(ctx, "POST", "/api/activities", ActivityRequest{
+			Type:       "diaper",
+			StartTime:  time.Now(),
+			DiaperData: &DiaperData{Wet: true},
+		})
+		require.NoError(t, CreateActivity(c))
+	}
+
+	// makeDue lets pending deliveries be retried straight away
+	makeDue := func() {
+		require.NoError(t, ctx.DB.Model(&models.WebhookDelivery{}).
+			Where("status = ?", models.WebhookDeliveryPending).
+			UpdateColumn("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	}
+
+	t.Run("matching events are delivered and signed", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "POST", "/api/activities/timer/start", TimerStartRequest{Type: "sleep"})
+		require.NoError(t, StartActivityTimer(c))
+		var timer ActivityResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &timer))
+
+		// Other types of activity are filtered out
+		logDiaper()
+
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		require.Equal(t, 1, receiver.received())
+
+		req, body := receiver.requests[0], receiver.bodies[0]
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, events.TimerStarted, req.Header.Get(webhooks.EventHeader))
+		assert.NotEmpty(t, req.Header.Get(webhooks.DeliveryHeader))
+		assert.True(t, webhooks.Verify(hook.Secret, body, req.Header.Get(webhooks.SignatureHeader)))
+
+		var payload webhooks.Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, events.TimerStarted, payload.Type)
+		assert.Equal(t, ctx.Baby.ID, payload.BabyID)
+		var data ActivityResponse
+		require.NoError(t, json.Unmarshal(payload.Data, &data))
+		assert.Equal(t, timer.ID, data.ID)
+
+		deliveries := getWebhookDeliveries(t, ctx, hook.ID)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.WebhookDeliveryDelivered), deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+		assert.Nil(t, deliveries[0].NextAttemptAt)
+	})
+
+	t.Run("failed deliveries are retried with backoff", func(t *testing.T) {
+		receiver.respondWith(http.StatusServiceUnavailable)
+		c, _ := createEchoContext(ctx, "POST", "/api/activities/timer/start", TimerStartRequest{Type: "sleep"})
+		require.NoError(t, StartActivityTimer(c))
+
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		require.Equal(t, 2, receiver.received())
+
+		deliveries := getWebhookDeliveries(t, ctx, hook.ID)
+		retry := deliveries[0]
+		assert.Equal(t, string(models.WebhookDeliveryPending), retry.Status)
+		assert.Equal(t, http.StatusServiceUnavailable, retry.ResponseStatus)
+		assert.Equal(t, "unexpected status 503", retry.LastError)
+		require.NotNil(t, retry.NextAttemptAt)
+		assert.True(t, retry.NextAttemptAt.After(time.Now().Add(20*time.Second)))
+
+		// Nothing is sent again before it is due
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		assert.Equal(t, 2, receiver.received())
+
+		receiver.respondWith(http.StatusOK)
+		makeDue()
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		assert.Equal(t, 3, receiver.received())
+
+		// A retry is the same delivery with the same signed body
+		assert.Equal(t, receiver.bodies[1], receiver.bodies[2])
+		assert.Equal(t, receiver.requests[1].Header.Get(webhooks.DeliveryHeader), receiver.requests[2].Header.Get(webhooks.DeliveryHeader))
+
+		deliveries = getWebhookDeliveries(t, ctx, hook.ID)
+		assert.Equal(t, string(models.WebhookDeliveryDelivered), deliveries[0].Status)
+		assert.Equal(t, 2, deliveries[0].Attempts)
+	})
+
+	t.Run("deliveries are given up on after too many attempts", func(t *testing.T) {
+		receiver.respondWith(http.StatusInternalServerError)
+		c, _ := createEchoContext(ctx, "POST", "/api/activities/timer/start", TimerStartRequest{Type: "sleep"})
+		require.NoError(t, StartActivityTimer(c))
+
+		for i := 0; i < webhooks.MaxAttempts+2; i++ {
+			makeDue()
+			require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		}
+
+		deliveries := getWebhookDeliveries(t, ctx, hook.ID)
+		assert.Equal(t, string(models.WebhookDeliveryFailed), deliveries[0].Status)
+		assert.Equal(t, webhooks.MaxAttempts, deliveries[0].Attempts)
+	})
+
+	t.Run("the delivery log can be filtered by status", func(t *testing.T) {
+		c, rec := createEchoContext(ctx, "GET", "/api/webhooks/"+hook.ID+"/deliveries?status=delivered", nil)
+		c.SetParamNames("id")
+		c.SetParamValues(hook.ID)
+		require.NoError(t, GetWebhookDeliveries(c))
+
+		var response WebhookDeliveryListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, int64(2), response.Total)
+	})
+
+	t.Run("addresses that aren't allowed are never connected to", func(t *testing.T) {
+		receiver.respondWith(http.StatusNoContent)
+		before := receiver.received()
+		c, _ := createEchoContext(ctx, "POST", "/api/activities/timer/start", TimerStartRequest{Type: "sleep"})
+		require.NoError(t, StartActivityTimer(c))
+
+		strict := webhooks.NewDispatcher(ctx.DB, &config.Config{})
+		require.NoError(t, strict.DeliverDue(context.Background()))
+		assert.Equal(t, before, receiver.received())
+
+		deliveries := getWebhookDeliveries(t, ctx, hook.ID)
+		assert.Equal(t, string(models.WebhookDeliveryPending), deliveries[0].Status)
+		assert.Contains(t, deliveries[0].LastError, "not allowed")
+
+		makeDue()
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		assert.Equal(t, before+1, receiver.received())
+	})
+
+	t.Run("members who lose access stop getting events", func(t *testing.T) {
+		caregiver := addTestMember(t, ctx, models.BabyRoleCaregiver)
+		c, _ := createEchoContext(ctx, "POST", "/api/webhooks", WebhookRequest{URL: receiver.URL})
+		asUser(c, caregiver)
+		require.NoError(t, CreateWebhook(c))
+
+		require.NoError(t, ctx.DB.Where("baby_id = ? AND user_id = ?", ctx.Baby.ID, caregiver.ID).
+			Delete(&models.BabyMember{}).Error)
+		logDiaper()
+
+		var count int64
+		ctx.DB.Model(&models.WebhookDelivery{}).Where("event_type = ?", events.ActivityCreated).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
@@ -1,14 +1,29 @@
 package middleware
 
 import (
+	"errors"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"gorm.io/gorm"
 
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
 	"github.com/engineervix/bambino/internal/utils"
 )
 
+var (
+	// ErrInvalidAPIToken is returned for unknown or expired bearer tokens
+	ErrInvalidAPIToken = errors.New("invalid API token")
+	// ErrInvalidProxyUser is returned when a trusted proxy names a user that
+	// can't be used or created
+	ErrInvalidProxyUser = errors.New("invalid proxy user")
+)
+
 // AuthConfig defines the configuration for auth middleware
 type AuthConfig struct {
 	// Skipper defines a function to skip middleware
@@ -48,6 +63,37 @@ func RequireAuthWithConfig(config AuthConfig) echo.MiddlewareFunc {
 				return next(c)
 			}
 
+			// Personal API tokens are accepted as an alternative to the session cookie
+			if header := c.Request().Header.Get(echo.HeaderAuthorization); header != "" {
+				token, err := authenticateAPIToken(c, header)
+				if err != nil {
+					return config.ErrorHandler(c, err)
+				}
+
+				// Read-only tokens may only make safe requests
+				if token.Scope == models.APITokenScopeRead && !isSafeMethod(c.Request().Method) {
+					return echo.NewHTTPError(http.StatusForbidden, "API token is read-only")
+				}
+
+				c.Set("user_id", token.UserID.String())
+				c.Set("username", token.User.Username)
+				c.Set("api_token", token)
+
+				return next(c)
+			}
+
+			// Behind a trusted SSO proxy, its user header logs the user in
+			user, err := authenticateProxyUser(c)
+			if err != nil {
+				return config.ErrorHandler(c, err)
+			}
+			if user != nil {
+				c.Set("user_id", user.ID.String())
+				c.Set("username", user.Username)
+
+				return next(c)
+			}
+
 			// Check if user is authenticated
 			userID, username, err := utils.GetUserSession(c)
 			if err != nil {
@@ -63,6 +109,119 @@ func RequireAuthWithConfig(config AuthConfig) echo.MiddlewareFunc {
 	}
 }
 
+// authenticateAPIToken validates an "Authorization: Bearer <token>" header and
+// returns the matching token with its user loaded
+func authenticateAPIToken(c echo.Context, header string) (*models.APIToken, error) {
+	scheme, raw, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || raw == "" {
+		return nil, ErrInvalidAPIToken
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return nil, errors.New("database connection error")
+	}
+
+	var token models.APIToken
+	if err := db.Preload("User").
+		Where("token_hash = ?", utils.HashToken(strings.TrimSpace(raw))).
+		First(&token).Error; err != nil {
+		return nil, ErrInvalidAPIToken
+	}
+
+	now := time.Now()
+	if token.IsExpired(now) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	// Recording usage is best effort and must not fail the request
+	db.Model(&token).UpdateColumn("last_used_at", now)
+
+	return &token, nil
+}
+
+// authenticateProxyUser returns the user named in the forward-auth header, or
+// nil if forward auth is off or doesn't apply to the request. The header is
+// only trusted on connections from a configured proxy, since anyone else could
+// set it. Unknown users are created if auto-provisioning is on.
+func authenticateProxyUser(c echo.Context) (*models.User, error) {
+	cfg, ok := c.Get("config").(*config.Config)
+	if !ok || cfg.AuthProxyHeader == "" {
+		return nil, nil
+	}
+
+	username := strings.TrimSpace(c.Request().Header.Get(cfg.AuthProxyHeader))
+	if username == "" || !fromTrustedProxy(c.Request(), cfg) {
+		return nil, nil
+	}
+
+	if len(username) > 50 {
+		return nil, ErrInvalidProxyUser
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return nil, errors.New("database connection error")
+	}
+
+	var user models.User
+	err := db.Where("username = ?", username).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) || !cfg.AuthProxyAutoProvision {
+		return nil, ErrInvalidProxyUser
+	}
+
+	// The proxy handles logins
+	hash, err := utils.NewUnusablePasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	user = models.User{Username: username, PasswordHash: hash}
+	if err := db.Create(&user).Error; err != nil {
+		// Another request may have created the user first
+		if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+			return nil, ErrInvalidProxyUser
+		}
+	}
+
+	return &user, nil
+}
+
+// fromTrustedProxy reports whether the request's direct peer is a trusted proxy
+func fromTrustedProxy(r *http.Request, cfg *config.Config) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	networks, err := cfg.AuthProxyNetworks()
+	if err != nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeMethod reports whether an HTTP method does not modify state
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
 // RequireAuthJSON returns auth middleware that always returns JSON errors
 func RequireAuthJSON() echo.MiddlewareFunc {
 	return RequireAuthWithConfig(AuthConfig{
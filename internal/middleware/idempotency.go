@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a request without repeating it
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed for a retry
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize limits the request bodies read to hash them,
+	// leaving room for a full sync batch
+	maxIdempotentBodySize = 2 << 20
+)
+
+// Idempotency returns middleware that makes requests carrying an
+// Idempotency-Key header safe to retry. The response to the first request is
+// stored, and a retry with the same key gets it again without the handler
+// running. It must run after authentication, as keys belong to a user.
+func Idempotency() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" || isSafeMethod(c.Request().Method) {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, "idempotency key is too long")
+			}
+
+			db, ok := c.Get("db").(*gorm.DB)
+			if !ok {
+				return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+			}
+			authUserID, _ := c.Get("user_id").(string)
+			userID, err := uuid.Parse(authUserID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "user not found in context")
+			}
+
+			// A key may only be reused for the same request
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxIdempotentBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body is too large")
+				}
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+			hash := sha256.New()
+			hash.Write([]byte(c.Request().Method + " " + c.Request().URL.RequestURI() + "\n"))
+			hash.Write(body)
+			requestHash := hex.EncodeToString(hash.Sum(nil))
+
+			// Forget an expired use of the key, then claim it
+			if err := db.Where("user_id = ? AND idempotency_key = ? AND created_at < ?",
+				userID, key, time.Now().Add(-utils.IdempotencyKeyRetention)).
+				Delete(&models.IdempotencyKey{}).Error; err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+			}
+			record := models.IdempotencyKey{
+				UserID:      userID,
+				Key:         key,
+				RequestHash: requestHash,
+			}
+			result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+			if result.Error != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+			}
+
+			// The key has been used before, so this is a retry
+			if result.RowsAffected == 0 {
+				var existing models.IdempotencyKey
+				if err := db.Where("user_id = ? AND idempotency_key = ?", userID, key).
+					First(&existing).Error; err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+				}
+				if existing.RequestHash != requestHash {
+					return echo.NewHTTPError(http.StatusUnprocessableEntity, "idempotency key was used for a different request")
+				}
+				if existing.StatusCode == 0 {
+					return echo.NewHTTPError(http.StatusConflict, "a request with this idempotency key is still in progress")
+				}
+				c.Response().Header().Set(IdempotentReplayedHeader, "true")
+				if existing.ETag != "" {
+					c.Response().Header().Set("ETag", existing.ETag)
+				}
+				return c.Blob(existing.StatusCode, existing.ContentType, []byte(existing.Response))
+			}
+
+			// Handle the request, keeping a copy of the response. Server
+			// errors may be temporary, so after one (or a panic) the key is
+			// released and a retry runs again.
+			stored := false
+			defer func() {
+				if stored {
+					return
+				}
+				if err := db.Where("id = ?", record.ID).Delete(&models.IdempotencyKey{}).Error; err != nil {
+					c.Logger().Errorf("failed to release idempotency key: %v", err)
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			if c.Response().Status >= http.StatusInternalServerError {
+				return nil
+			}
+
+			if err := db.Model(&record).Updates(map[string]interface{}{
+				"status_code":  c.Response().Status,
+				"content_type": c.Response().Header().Get(echo.HeaderContentType),
+				"etag":         c.Response().Header().Get("ETag"),
+				"response":     recorder.body.String(),
+			}).Error; err != nil {
+				c.Logger().Errorf("failed to store idempotent response: %v", err)
+				return nil
+			}
+			stored = true
+			return nil
+		}
+	}
+}
+
+// responseRecorder copies a response as it is written
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// RequireShareToken returns middleware for the public share routes. It
+// validates the signed token in the :token path param, checks that the link
+// has not been revoked or expired, records the access and puts the link (with
+// its baby) into the context. It does not require a session.
+func RequireShareToken() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			db, ok := c.Get("db").(*gorm.DB)
+			if !ok {
+				return echo.NewHTTPError(http.StatusInternalServerError, "database connection error")
+			}
+
+			cfg, ok := c.Get("config").(*config.Config)
+			if !ok {
+				return echo.NewHTTPError(http.StatusInternalServerError, "configuration error")
+			}
+
+			value, err := utils.VerifySignedTokenWithKeys(cfg.SigningKeys(), c.Param("token"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "share link not found",
+				})
+			}
+
+			linkID, err := uuid.Parse(value)
+			if err != nil {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "share link not found",
+				})
+			}
+
+			var link models.ShareLink
+			if err := db.Preload("Baby").First(&link, "id = ?", linkID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return c.JSON(http.StatusNotFound, map[string]string{
+						"error": "share link not found",
+					})
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, "database error")
+			}
+
+			if !link.IsActive(time.Now()) {
+				return c.JSON(http.StatusGone, map[string]string{
+					"error": "share link has expired or been revoked",
+				})
+			}
+
+			access := models.ShareAccessLog{
+				ShareLinkID: link.ID,
+				IPAddress:   utils.TruncateString(c.RealIP(), 45),
+				UserAgent:   utils.TruncateString(c.Request().UserAgent(), 255),
+				Path:        utils.TruncateString(c.Request().URL.Path, 255),
+			}
+			if err := db.Create(&access).Error; err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to record access")
+			}
+
+			c.Set("share_link", &link)
+
+			return next(c)
+		}
+	}
+}
@@ -30,15 +30,25 @@ func (a ActivityType) Value() (driver.Value, error) {
 }
 
 type Activity struct {
-	ID                uuid.UUID    `gorm:"type:varchar(36);primary_key"`
-	BabyID            uuid.UUID    `gorm:"type:varchar(36);not null;index"`
-	Type              ActivityType `gorm:"type:varchar(20);not null"`
-	StartTime         time.Time    `gorm:"not null"`
-	EndTime           *time.Time
-	Notes             string `gorm:"type:text"`
-	CreatedAt         time.Time
-	UpdatedAt         time.Time
+	ID          uuid.UUID    `gorm:"type:varchar(36);primary_key"`
+	BabyID      uuid.UUID    `gorm:"type:varchar(36);not null;index"`
+	Type        ActivityType `gorm:"type:varchar(20);not null"`
+	StartTime   time.Time    `gorm:"not null"`
+	EndTime     *time.Time
+	Notes       string     `gorm:"type:text"`
+	CreatedByID *uuid.UUID `gorm:"type:varchar(36);index"`
+	UpdatedByID *uuid.UUID `gorm:"type:varchar(36)"`
+	// Version goes up with every change, so that concurrent edits can be
+	// told apart
+	Version   int `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt moves an activity to the trash. GORM leaves trashed rows out
+	// of queries unless they are made Unscoped.
+	DeletedAt         gorm.DeletedAt     `gorm:"index"`
 	Baby              Baby               `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	CreatedBy         *User              `gorm:"foreignKey:CreatedByID;constraint:OnDelete:SET NULL"`
+	UpdatedBy         *User              `gorm:"foreignKey:UpdatedByID;constraint:OnDelete:SET NULL"`
 	FeedActivity      *FeedActivity      `gorm:"foreignKey:ActivityID;constraint:OnDelete:CASCADE"`
 	DiaperActivity    *DiaperActivity    `gorm:"foreignKey:ActivityID;constraint:OnDelete:CASCADE"`
 	SleepActivity     *SleepActivity     `gorm:"foreignKey:ActivityID;constraint:OnDelete:CASCADE"`
@@ -52,6 +62,9 @@ func (a *Activity) BeforeCreate(tx *gorm.DB) error {
 	if a.ID == uuid.Nil {
 		a.ID = uuid.New()
 	}
+	if a.Version == 0 {
+		a.Version = 1
+	}
 	return nil
 }
 
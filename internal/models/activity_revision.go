@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RevisionAction is the kind of change an activity revision records
+type RevisionAction string
+
+const (
+	RevisionActionCreate    RevisionAction = "create"
+	RevisionActionUpdate    RevisionAction = "update"
+	RevisionActionDelete    RevisionAction = "delete"
+	RevisionActionRestore   RevisionAction = "restore"
+	RevisionActionTimerStop RevisionAction = "timer_stop"
+)
+
+// ErrRevisionAppendOnly is returned when trying to change or remove a revision
+var ErrRevisionAppendOnly = errors.New("activity revisions are append-only")
+
+// ActivityRevision is an entry in the append-only log of changes to
+// activities. Before and After hold JSON snapshots of the activity as the API
+// returns it; Before is empty for creations and After for deletions.
+type ActivityRevision struct {
+	ID uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	// ActivityID has no foreign key so the history outlives the activity
+	// when it is purged from the trash
+	ActivityID uuid.UUID      `gorm:"type:varchar(36);not null;index"`
+	BabyID     uuid.UUID      `gorm:"type:varchar(36);not null;index"`
+	UserID     *uuid.UUID     `gorm:"type:varchar(36);index"`
+	Action     RevisionAction `gorm:"type:varchar(20);not null"`
+	Before     string         `gorm:"column:before_state;type:text"`
+	After      string         `gorm:"column:after_state;type:text"`
+	CreatedAt  time.Time      `gorm:"index"`
+	Baby       Baby           `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	User       *User          `gorm:"foreignKey:UserID;constraint:OnDelete:SET NULL"`
+}
+
+func (r *ActivityRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeUpdate keeps revisions from being rewritten
+func (r *ActivityRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrRevisionAppendOnly
+}
+
+// BeforeDelete keeps revisions from being removed
+func (r *ActivityRevision) BeforeDelete(tx *gorm.DB) error {
+	return ErrRevisionAppendOnly
+}
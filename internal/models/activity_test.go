@@ -252,8 +252,8 @@ func TestActivity_CascadeDelete(t *testing.T) {
 	err = testDB.DB.Create(feedActivity).Error
 	require.NoError(t, err)
 
-	// Delete activity
-	err = testDB.DB.Delete(&activity).Error
+	// Permanently delete activity, as when the trash is purged
+	err = testDB.DB.Unscoped().Delete(&activity).Error
 	require.NoError(t, err)
 
 	// Feed activity should be deleted
@@ -262,3 +262,65 @@ func TestActivity_CascadeDelete(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(0), count)
 }
+
+func TestActivity_SoftDelete(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Cleanup()
+
+	user := createTestUser(t, testDB.DB)
+	baby := createTestBaby(t, testDB.DB, user.ID)
+
+	activity := &models.Activity{
+		BabyID:    baby.ID,
+		Type:      models.ActivityTypeFeed,
+		StartTime: time.Now(),
+	}
+	require.NoError(t, testDB.DB.Create(activity).Error)
+	require.NoError(t, testDB.DB.Create(&models.FeedActivity{
+		ActivityID: activity.ID,
+		FeedType:   models.FeedTypeBottle,
+	}).Error)
+
+	// Deleting moves the activity to the trash
+	require.NoError(t, testDB.DB.Delete(activity).Error)
+
+	var count int64
+	testDB.DB.Model(&models.Activity{}).Where("id = ?", activity.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	var trashed models.Activity
+	require.NoError(t, testDB.DB.Unscoped().First(&trashed, "id = ?", activity.ID).Error)
+	assert.True(t, trashed.DeletedAt.Valid)
+
+	// Its details are kept so it can be restored
+	testDB.DB.Model(&models.FeedActivity{}).Where("activity_id = ?", activity.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestActivityRevision_AppendOnly(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Cleanup()
+
+	user := createTestUser(t, testDB.DB)
+	baby := createTestBaby(t, testDB.DB, user.ID)
+
+	revision := &models.ActivityRevision{
+		ActivityID: uuid.New(),
+		BabyID:     baby.ID,
+		UserID:     &user.ID,
+		Action:     models.RevisionActionCreate,
+		After:      `{"type":"feed"}`,
+	}
+	require.NoError(t, testDB.DB.Create(revision).Error)
+	assert.NotEqual(t, uuid.Nil, revision.ID)
+
+	err := testDB.DB.Model(revision).Update("action", models.RevisionActionDelete).Error
+	assert.ErrorIs(t, err, models.ErrRevisionAppendOnly)
+
+	err = testDB.DB.Delete(revision).Error
+	assert.ErrorIs(t, err, models.ErrRevisionAppendOnly)
+
+	var stored models.ActivityRevision
+	require.NoError(t, testDB.DB.First(&stored, "id = ?", revision.ID).Error)
+	assert.Equal(t, models.RevisionActionCreate, stored.Action)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APITokenScope limits what an API token may do
+type APITokenScope string
+
+const (
+	// APITokenScopeRead only allows safe (GET/HEAD) requests
+	APITokenScopeRead APITokenScope = "read"
+	// APITokenScopeWrite allows everything the user's baby roles allow
+	APITokenScopeWrite APITokenScope = "write"
+)
+
+// Valid reports whether s is a known scope
+func (s APITokenScope) Valid() bool {
+	return s == APITokenScopeRead || s == APITokenScopeWrite
+}
+
+// APIToken is a personal access token used by scripts and home automation
+// instead of a session cookie. Only a hash of the token is stored.
+type APIToken struct {
+	ID         uuid.UUID     `gorm:"type:varchar(36);primary_key"`
+	UserID     uuid.UUID     `gorm:"type:varchar(36);not null;index"`
+	Name       string        `gorm:"type:varchar(100);not null"`
+	TokenHash  string        `gorm:"type:varchar(64);unique;not null"`
+	Prefix     string        `gorm:"type:varchar(16);not null"`
+	Scope      APITokenScope `gorm:"type:varchar(10);not null"`
+	BabyID     *uuid.UUID    `gorm:"type:varchar(36)"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+	User       User  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Baby       *Baby `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+}
+
+func (t *APIToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave hook to validate required fields
+func (t *APIToken) BeforeSave(tx *gorm.DB) error {
+	if t.UserID == uuid.Nil || t.Name == "" || t.TokenHash == "" {
+		return gorm.ErrInvalidField
+	}
+	if !t.Scope.Valid() {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
+
+// IsExpired reports whether the token has an expiry that has passed
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
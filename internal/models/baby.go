@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Baby struct {
@@ -15,19 +16,39 @@ type Baby struct {
 	TrackSleep  bool      `gorm:"type:boolean;default:true;not null"`
 	BirthWeight *float64  `gorm:"type:decimal(5,2)"`
 	BirthHeight *float64  `gorm:"type:decimal(5,2)"`
+	ArchivedAt  *time.Time
+	Version     int `gorm:"not null;default:1"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
-	Activities  []Activity `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	User        User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Activities  []Activity   `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	Members     []BabyMember `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
 }
 
 func (b *Baby) BeforeCreate(tx *gorm.DB) error {
 	if b.ID == uuid.Nil {
 		b.ID = uuid.New()
 	}
+	if b.Version == 0 {
+		b.Version = 1
+	}
 	return nil
 }
 
+// AfterCreate hook to make the creating user the baby's owner. GORM also runs
+// this when upserting a preloaded baby association, so an existing membership
+// is left untouched.
+func (b *Baby) AfterCreate(tx *gorm.DB) error {
+	member := BabyMember{
+		BabyID: b.ID,
+		UserID: b.UserID,
+		Role:   BabyRoleOwner,
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&member).Error
+}
+
 // BeforeSave hook to validate required fields
 func (b *Baby) BeforeSave(tx *gorm.DB) error {
 	if b.UserID == uuid.Nil {
@@ -38,3 +59,8 @@ func (b *Baby) BeforeSave(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// IsArchived reports whether the baby profile has been archived
+func (b *Baby) IsArchived() bool {
+	return b.ArchivedAt != nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BabyRole is the level of access a user has to a baby
+type BabyRole string
+
+const (
+	// BabyRoleOwner can manage the baby profile, its members and delete history
+	BabyRoleOwner BabyRole = "owner"
+	// BabyRoleCaregiver can log and edit activities
+	BabyRoleCaregiver BabyRole = "caregiver"
+	// BabyRoleViewer has read-only access
+	BabyRoleViewer BabyRole = "viewer"
+)
+
+var babyRoleRanks = map[BabyRole]int{
+	BabyRoleViewer:    1,
+	BabyRoleCaregiver: 2,
+	BabyRoleOwner:     3,
+}
+
+// Valid reports whether r is a known role
+func (r BabyRole) Valid() bool {
+	_, ok := babyRoleRanks[r]
+	return ok
+}
+
+// Includes reports whether r grants at least the permissions of other
+func (r BabyRole) Includes(other BabyRole) bool {
+	return r.Valid() && babyRoleRanks[r] >= babyRoleRanks[other]
+}
+
+// BabyMember links a user to a baby they have access to
+type BabyMember struct {
+	BabyID uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	UserID uuid.UUID `gorm:"type:varchar(36);primary_key;index"`
+	Role   BabyRole  `gorm:"type:varchar(20);not null"`
+	// ManagedByOIDC is set on memberships granted by the OpenID Connect role
+	// mapping, whose role then follows the provider. It is cleared when an
+	// owner sets the role themselves.
+	ManagedByOIDC bool `gorm:"column:managed_by_oidc;not null;default:false"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Baby          Baby `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	User          User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// BeforeSave hook to validate required fields
+func (m *BabyMember) BeforeSave(tx *gorm.DB) error {
+	if m.BabyID == uuid.Nil || m.UserID == uuid.Nil {
+		return gorm.ErrInvalidField
+	}
+	if !m.Role.Valid() {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
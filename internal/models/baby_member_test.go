@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+func TestBabyRole_Includes(t *testing.T) {
+	assert.True(t, models.BabyRoleOwner.Includes(models.BabyRoleCaregiver))
+	assert.True(t, models.BabyRoleCaregiver.Includes(models.BabyRoleViewer))
+	assert.True(t, models.BabyRoleViewer.Includes(models.BabyRoleViewer))
+	assert.False(t, models.BabyRoleViewer.Includes(models.BabyRoleCaregiver))
+	assert.False(t, models.BabyRoleCaregiver.Includes(models.BabyRoleOwner))
+	assert.False(t, models.BabyRole("admin").Includes(models.BabyRoleViewer))
+}
+
+func TestBabyMember_OwnerCreatedWithBaby(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Cleanup()
+
+	user := createTestUser(t, testDB.DB)
+	baby := createTestBaby(t, testDB.DB, user.ID)
+
+	var member models.BabyMember
+	err := testDB.DB.Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).First(&member).Error
+	require.NoError(t, err)
+	assert.Equal(t, models.BabyRoleOwner, member.Role)
+
+	// Deleting the baby removes its memberships
+	err = testDB.DB.Delete(baby).Error
+	require.NoError(t, err)
+
+	var count int64
+	err = testDB.DB.Model(&models.BabyMember{}).Where("baby_id = ?", baby.ID).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestBabyMember_InvalidRole(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Cleanup()
+
+	owner := createTestUser(t, testDB.DB)
+	other := createTestUser(t, testDB.DB)
+	baby := createTestBaby(t, testDB.DB, owner.ID)
+
+	member := models.BabyMember{BabyID: baby.ID, UserID: other.ID, Role: "admin"}
+	err := testDB.DB.Create(&member).Error
+	assert.Error(t, err)
+}
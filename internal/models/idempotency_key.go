@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header, so that a retry of it gets the same response
+// instead of repeating the change. StatusCode is 0 while the first request
+// is still being handled.
+type IdempotencyKey struct {
+	ID          uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	UserID      uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_idempotency_keys_user_key"`
+	Key         string    `gorm:"column:idempotency_key;type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_user_key"`
+	RequestHash string    `gorm:"type:varchar(64);not null"`
+	StatusCode  int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"type:varchar(100)"`
+	ETag        string    `gorm:"column:etag;type:varchar(100)"`
+	Response    string    `gorm:"type:text"`
+	CreatedAt   time.Time `gorm:"index"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
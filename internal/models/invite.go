@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Invite is a single-use token that lets a new user join a baby with a given role
+type Invite struct {
+	ID          uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	BabyID      uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	CreatedByID uuid.UUID `gorm:"type:varchar(36);not null"`
+	TokenHash   string    `gorm:"type:varchar(64);unique;not null"`
+	Role        BabyRole  `gorm:"type:varchar(20);not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	UsedAt      *time.Time
+	UsedByID    *uuid.UUID `gorm:"type:varchar(36)"`
+	CreatedAt   time.Time
+	Baby        Baby  `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	CreatedBy   User  `gorm:"foreignKey:CreatedByID;constraint:OnDelete:CASCADE"`
+	UsedBy      *User `gorm:"foreignKey:UsedByID;constraint:OnDelete:SET NULL"`
+}
+
+func (i *Invite) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave hook to validate required fields
+func (i *Invite) BeforeSave(tx *gorm.DB) error {
+	if i.BabyID == uuid.Nil || i.CreatedByID == uuid.Nil {
+		return gorm.ErrInvalidField
+	}
+	if i.TokenHash == "" || !i.Role.Valid() {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
+
+// IsRedeemable reports whether the invite is unused and has not expired
+func (i *Invite) IsRedeemable(now time.Time) bool {
+	return i.UsedAt == nil && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginResult records the outcome of a login attempt
+type LoginResult string
+
+const (
+	LoginResultSuccess            LoginResult = "success"
+	LoginResultInvalidCredentials LoginResult = "invalid_credentials"
+	LoginResultThrottled          LoginResult = "throttled"
+	// LoginResultTwoFactorRequired is a correct password from a user who
+	// still has to give a TOTP or recovery code
+	LoginResultTwoFactorRequired LoginResult = "two_factor_required"
+)
+
+// LoginAttempt is an entry in the login history. UserID is set whenever the
+// username matched an account, so users can see failed attempts against them.
+type LoginAttempt struct {
+	ID        uuid.UUID   `gorm:"type:varchar(36);primary_key"`
+	UserID    *uuid.UUID  `gorm:"type:varchar(36);index"`
+	Username  string      `gorm:"type:varchar(50);not null;index"`
+	IPAddress string      `gorm:"type:varchar(45);index"`
+	UserAgent string      `gorm:"type:varchar(255)"`
+	Result    LoginResult `gorm:"type:varchar(20);not null"`
+	CreatedAt time.Time   `gorm:"index"`
+	User      *User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (a *LoginAttempt) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationChannelType is a way of sending notifications
+type NotificationChannelType string
+
+const (
+	NotificationChannelWebhook NotificationChannelType = "webhook"
+	NotificationChannelNtfy    NotificationChannelType = "ntfy"
+	NotificationChannelGotify  NotificationChannelType = "gotify"
+	NotificationChannelEmail   NotificationChannelType = "email"
+	NotificationChannelWebPush NotificationChannelType = "webpush"
+)
+
+// NotificationChannel is somewhere a user wants to be sent notifications,
+// such as an email address or a browser subscribed to Web Push
+type NotificationChannel struct {
+	ID     uuid.UUID               `gorm:"type:varchar(36);primary_key"`
+	UserID uuid.UUID               `gorm:"type:varchar(36);not null;index"`
+	Type   NotificationChannelType `gorm:"type:varchar(20);not null"`
+	Name   string                  `gorm:"type:varchar(100);not null"`
+	// Settings holds the channel's settings as JSON, in the form its type
+	// expects
+	Settings  string `gorm:"type:text;not null"`
+	Enabled   bool   `gorm:"not null;default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	User      User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (n *NotificationChannel) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave hook to validate required fields
+func (n *NotificationChannel) BeforeSave(tx *gorm.DB) error {
+	if n.UserID == uuid.Nil || n.Type == "" || n.Settings == "" {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OIDCIdentity links an account at an OpenID Connect provider, identified by
+// its issuer and subject, to a user
+type OIDCIdentity struct {
+	ID          uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	UserID      uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	Issuer      string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_oidc_identities_issuer_subject"`
+	Subject     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_oidc_identities_issuer_subject"`
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+	User        User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName keeps GORM from splitting the OIDC initialism
+func (OIDCIdentity) TableName() string {
+	return "oidc_identities"
+}
+
+func (i *OIDCIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
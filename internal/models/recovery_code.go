@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time code that can stand in for a TOTP code when a
+// user has lost their authenticator. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	UserID    uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	CodeHash  string    `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	User      User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (r *RecoveryCode) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reminder notifies a user about a baby, either on a cron schedule or a
+// while after the latest activity of a type, such as three hours after the
+// last feed started
+type Reminder struct {
+	ID     uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	UserID uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	BabyID uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	Title  string    `gorm:"type:varchar(100);not null"`
+	Notes  string    `gorm:"type:text"`
+	// Schedule is a cron expression, read in TimeZone. It is empty for
+	// reminders that follow an activity.
+	Schedule string `gorm:"type:varchar(100)"`
+	TimeZone string `gorm:"type:varchar(64);not null;default:'UTC'"`
+	// ActivityType is the activity a reminder follows, if any. Reminders
+	// with one are paused while a timer of that type is running.
+	ActivityType ActivityType `gorm:"type:varchar(20)"`
+	// DelayMinutes is how long after the latest activity starts that a
+	// reminder without a schedule falls due
+	DelayMinutes int  `gorm:"not null;default:0"`
+	Enabled      bool `gorm:"not null;default:true"`
+	// EnabledAt is when the reminder was created or last switched back on.
+	// Activities that started before it don't set the reminder off.
+	EnabledAt time.Time
+	// NextRunAt is when a scheduled reminder next falls due
+	NextRunAt    *time.Time `gorm:"index"`
+	SnoozedUntil *time.Time
+	LastSentAt   *time.Time
+	// SentCount goes up every time the reminder is sent, so that only one
+	// instance sends it
+	SentCount      int `gorm:"not null;default:0"`
+	AcknowledgedAt *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	User           User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Baby           Baby `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+}
+
+func (r *Reminder) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.EnabledAt.IsZero() {
+		r.EnabledAt = time.Now()
+	}
+	return nil
+}
+
+// BeforeSave hook to validate required fields
+func (r *Reminder) BeforeSave(tx *gorm.DB) error {
+	if r.UserID == uuid.Nil || r.BabyID == uuid.Nil || r.Title == "" {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
+
+// FollowsActivity reports whether the reminder falls due a while after the
+// latest activity, rather than on a schedule
+func (r *Reminder) FollowsActivity() bool {
+	return r.Schedule == ""
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is a server-side login session. The browser only holds a signed
+// cookie with a random key; the hash of that key identifies the row.
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:varchar(36);primary_key"`
+	TokenHash  string     `gorm:"type:varchar(64);unique;not null"`
+	UserID     *uuid.UUID `gorm:"type:varchar(36);index"`
+	Data       string     `gorm:"type:text"`
+	UserAgent  string     `gorm:"type:varchar(255)"`
+	IPAddress  string     `gorm:"type:varchar(45)"`
+	CreatedAt  time.Time
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null;index"`
+	User       *User     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
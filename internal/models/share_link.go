@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ShareLink grants anonymous, read-only access to a baby's data within a date range
+type ShareLink struct {
+	ID          uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	BabyID      uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	CreatedByID uuid.UUID `gorm:"type:varchar(36);not null"`
+	Label       string    `gorm:"type:varchar(100)"`
+	StartDate   time.Time `gorm:"not null"`
+	EndDate     time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+	Baby        Baby             `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+	CreatedBy   User             `gorm:"foreignKey:CreatedByID;constraint:OnDelete:CASCADE"`
+	Accesses    []ShareAccessLog `gorm:"foreignKey:ShareLinkID;constraint:OnDelete:CASCADE"`
+}
+
+func (s *ShareLink) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave hook to validate required fields
+func (s *ShareLink) BeforeSave(tx *gorm.DB) error {
+	if s.BabyID == uuid.Nil || s.CreatedByID == uuid.Nil {
+		return gorm.ErrInvalidField
+	}
+	if s.EndDate.Before(s.StartDate) {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
+
+// IsActive reports whether the link has not been revoked and has not expired
+func (s *ShareLink) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// ShareAccessLog records a single request made through a share link
+type ShareAccessLog struct {
+	ID          uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	ShareLinkID uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	IPAddress   string    `gorm:"type:varchar(45)"`
+	UserAgent   string    `gorm:"type:varchar(255)"`
+	Path        string    `gorm:"type:varchar(255)"`
+	CreatedAt   time.Time
+}
+
+func (l *ShareAccessLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
@@ -11,11 +11,23 @@ type User struct {
 	ID           uuid.UUID `gorm:"type:varchar(36);primary_key"`
 	Username     string    `gorm:"type:varchar(50);unique;not null"`
 	PasswordHash string    `gorm:"type:varchar(255);not null"`
+	// TOTPSecret is set while enrolling in two-factor authentication, which
+	// is only enforced once TOTPEnabledAt is set
+	TOTPSecret    string     `gorm:"column:totp_secret;type:varchar(64)"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at"`
+	// TOTPLastStep is the time step of the last accepted code, so a code
+	// can't be used twice
+	TOTPLastStep int64 `gorm:"column:totp_last_step;not null;default:0"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	Babies       []Baby `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
 
+// TOTPEnabled reports whether the user must give a TOTP code to log in
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != ""
+}
+
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook sends the events for a baby's activities to a URL of the user's
+// choosing. Requests are signed with Secret so the receiver can check where
+// they came from.
+type Webhook struct {
+	ID     uuid.UUID `gorm:"type:varchar(36);primary_key"`
+	UserID uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	BabyID uuid.UUID `gorm:"type:varchar(36);not null;index"`
+	URL    string    `gorm:"type:varchar(2048);not null"`
+	Secret string    `gorm:"type:varchar(100);not null"`
+	// ActivityTypes is a comma-separated list of the activity types to send
+	// events for; empty sends all of them
+	ActivityTypes string `gorm:"type:varchar(200)"`
+	Active        bool   `gorm:"not null;default:true"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	User          User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Baby          Baby `gorm:"foreignKey:BabyID;constraint:OnDelete:CASCADE"`
+}
+
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave hook to validate required fields
+func (w *Webhook) BeforeSave(tx *gorm.DB) error {
+	if w.UserID == uuid.Nil || w.BabyID == uuid.Nil || w.URL == "" || w.Secret == "" {
+		return gorm.ErrInvalidField
+	}
+	return nil
+}
+
+// Types returns the activity types the webhook is limited to, if any
+func (w *Webhook) Types() []ActivityType {
+	var types []ActivityType
+	for _, t := range strings.Split(w.ActivityTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, ActivityType(t))
+		}
+	}
+	return types
+}
+
+// Matches reports whether the webhook wants events for an activity type
+func (w *Webhook) Matches(activityType ActivityType) bool {
+	types := w.Types()
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == activityType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus is where a webhook delivery is up to
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are waiting for their next attempt
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliveryDelivered deliveries were accepted by the receiver
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryFailed deliveries were given up on
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event queued for, or sent to, a webhook. Pending
+// deliveries are retried at NextAttemptAt until they succeed or run out of
+// attempts, and the finished ones are kept as a log.
+type WebhookDelivery struct {
+	ID            uuid.UUID             `gorm:"type:varchar(36);primary_key"`
+	WebhookID     uuid.UUID             `gorm:"type:varchar(36);not null;index"`
+	EventID       string                `gorm:"type:varchar(36);not null"`
+	EventType     string                `gorm:"type:varchar(50);not null"`
+	Payload       string                `gorm:"type:text;not null"`
+	Status        WebhookDeliveryStatus `gorm:"type:varchar(20);not null;index:idx_webhook_deliveries_due"`
+	Attempts      int                   `gorm:"not null;default:0"`
+	NextAttemptAt time.Time             `gorm:"index:idx_webhook_deliveries_due"`
+	LastAttemptAt *time.Time
+	// ResponseStatus is the HTTP status of the last attempt, or 0 if the
+	// receiver couldn't be reached
+	ResponseStatus int
+	LastError      string    `gorm:"type:text"`
+	CreatedAt      time.Time `gorm:"index"`
+	UpdatedAt      time.Time
+	Webhook        Webhook `gorm:"foreignKey:WebhookID;constraint:OnDelete:CASCADE"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+// Package mqtt publishes the state of babies to an MQTT broker for home
+// automation systems such as Home Assistant, and starts and stops timers
+// when told to over MQTT.
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/handlers"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+const (
+	// qos is the quality of service for everything sent and received
+	qos = 1
+	// publishTimeout is how long to wait for the broker to take a message
+	publishTimeout = 10 * time.Second
+	// commandBuffer is how many commands may wait to be carried out
+	commandBuffer = 16
+
+	payloadOn     = "ON"
+	payloadOff    = "OFF"
+	statusOnline  = "online"
+	statusOffline = "offline"
+)
+
+// timerTypes are the activities that can be timed from MQTT
+var timerTypes = []models.ActivityType{
+	models.ActivityTypeFeed,
+	models.ActivityTypePump,
+	models.ActivityTypeSleep,
+}
+
+// Bridge publishes the state of a user's babies to MQTT and carries out the
+// commands it receives as that user
+type Bridge struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	broker *events.Broker
+	echo   *echo.Echo
+
+	// RefreshInterval is how often every baby is published again, which
+	// keeps values like the hours since the last feed current and picks up
+	// babies the user has been given access to
+	RefreshInterval time.Duration
+
+	// The rest is only used by the goroutine running the bridge
+	client paho.Client
+	user   models.User
+	// published maps the babies published to the names they were
+	// announced to Home Assistant under
+	published map[uuid.UUID]string
+}
+
+// New creates a Bridge for the babies of cfg.MQTTUser, hearing about
+// changes to them from broker
+func New(db *gorm.DB, cfg *config.Config, broker *events.Broker) *Bridge {
+	return &Bridge{
+		db:              db,
+		cfg:             cfg,
+		broker:          broker,
+		echo:            echo.New(),
+		RefreshInterval: time.Minute,
+		published:       make(map[uuid.UUID]string),
+	}
+}
+
+// Run connects to the MQTT broker and keeps it up to date until ctx is
+// done. Lost connections are retried in the background.
+func (b *Bridge) Run(ctx context.Context) error {
+	if err := b.db.Where("username = ?", b.cfg.MQTTUser).First(&b.user).Error; err != nil {
+		return fmt.Errorf("failed to find MQTT user %q: %w", b.cfg.MQTTUser, err)
+	}
+
+	connected := make(chan struct{}, 1)
+	commands := make(chan paho.Message, commandBuffer)
+
+	opts := paho.NewClientOptions().
+		AddBroker(b.cfg.MQTTBrokerURL).
+		SetClientID(b.cfg.MQTTClientID).
+		SetUsername(b.cfg.MQTTUsername).
+		SetPassword(b.cfg.MQTTPassword).
+		SetWill(b.statusTopic(), statusOffline, qos, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Messages are handed over to Run rather than handled in order
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(paho.Client) {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}).
+		SetDefaultPublishHandler(func(_ paho.Client, msg paho.Message) {
+			select {
+			case commands <- msg:
+			case <-ctx.Done():
+			}
+		})
+	b.client = paho.NewClient(opts)
+	b.client.Connect()
+	defer b.client.Disconnect(250)
+
+	sub := b.broker.SubscribeAll()
+	defer func() { sub.Close() }()
+
+	ticker := time.NewTicker(b.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.publish(b.statusTopic(), statusOffline)
+			return nil
+
+		case <-connected:
+			// Subscriptions don't outlast the connection, so they are made
+			// again whenever it is
+			token := b.client.Subscribe(b.cfg.MQTTTopicPrefix+"/+/timer/+/set", qos, nil)
+			if !token.WaitTimeout(publishTimeout) || token.Error() != nil {
+				log.Printf("Failed to subscribe to MQTT commands: %v", token.Error())
+			}
+			b.publish(b.statusTopic(), statusOnline)
+			b.refresh(true)
+
+		case msg := <-commands:
+			b.handleCommand(ctx, msg)
+
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Events were missed, so everything is published again
+				sub = b.broker.SubscribeAll()
+				b.refresh(false)
+				continue
+			}
+
+			// Changes often come in bursts, such as when a client syncs,
+			// so each baby is only published once for the events waiting
+			changed := map[uuid.UUID]bool{event.BabyID: true}
+			for waiting := len(sub.Events); waiting > 0; waiting-- {
+				changed[(<-sub.Events).BabyID] = true
+			}
+			for babyID := range changed {
+				// Babies the user has just been given are published at the
+				// next refresh
+				if _, ok := b.published[babyID]; ok {
+					b.publishState(babyID)
+				}
+			}
+
+		case <-ticker.C:
+			b.refresh(false)
+		}
+	}
+}
+
+// refresh publishes every baby the user has access to and removes the ones
+// they have lost. Babies are announced to Home Assistant when first seen or
+// renamed, or every time if announce is set.
+func (b *Bridge) refresh(announce bool) {
+	var babies []models.Baby
+	if err := b.db.Where("archived_at IS NULL").
+		Where("id IN (?)", b.db.Model(&models.BabyMember{}).Select("baby_id").Where("user_id = ?", b.user.ID)).
+		Order("created_at ASC").
+		Find(&babies).Error; err != nil {
+		log.Printf("Failed to list babies for MQTT: %v", err)
+		return
+	}
+
+	current := make(map[uuid.UUID]string, len(babies))
+	for i := range babies {
+		baby := &babies[i]
+		current[baby.ID] = baby.Name
+		if name, ok := b.published[baby.ID]; announce || !ok || name != baby.Name {
+			b.publishDiscovery(baby)
+		}
+		b.publishState(baby.ID)
+	}
+
+	for babyID := range b.published {
+		if _, ok := current[babyID]; !ok {
+			b.unpublish(babyID)
+		}
+	}
+	b.published = current
+}
+
+// publishState publishes a baby's stats and timers
+func (b *Bridge) publishState(babyID uuid.UUID) {
+	b.publishJSON(b.babyTopic(babyID, "recent"), handlers.RecentStats(b.db, babyID))
+
+	// Days follow the server's time zone
+	year, month, day := time.Now().Date()
+	daily, err := handlers.DailyStats(b.db, babyID, time.Date(year, month, day, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		log.Printf("Failed to get daily stats for MQTT: %v", err)
+	} else {
+		b.publishJSON(b.babyTopic(babyID, "daily"), daily)
+	}
+
+	running, err := b.runningTimers(babyID)
+	if err != nil {
+		log.Printf("Failed to get timers for MQTT: %v", err)
+		return
+	}
+	for _, activityType := range timerTypes {
+		state := payloadOff
+		if len(running[activityType]) > 0 {
+			state = payloadOn
+		}
+		b.publish(b.timerTopic(babyID, activityType), state)
+	}
+}
+
+// unpublish clears the retained messages for a baby the user no longer has
+func (b *Bridge) unpublish(babyID uuid.UUID) {
+	if b.cfg.MQTTDiscovery {
+		for _, entity := range entities {
+			b.publish(b.discoveryTopic(babyID, entity), "")
+		}
+	}
+	b.publish(b.babyTopic(babyID, "recent"), "")
+	b.publish(b.babyTopic(babyID, "daily"), "")
+	for _, activityType := range timerTypes {
+		b.publish(b.timerTopic(babyID, activityType), "")
+	}
+}
+
+// handleCommand carries out an ON or OFF sent to a timer's command topic
+func (b *Bridge) handleCommand(ctx context.Context, msg paho.Message) {
+	babyID, activityType, ok := b.parseCommandTopic(msg.Topic())
+	if !ok {
+		return
+	}
+
+	var err error
+	switch command := strings.ToUpper(strings.TrimSpace(string(msg.Payload()))); command {
+	case payloadOn:
+		err = b.startTimer(ctx, babyID, activityType)
+	case payloadOff:
+		err = b.stopTimer(ctx, babyID, activityType)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		log.Printf("Failed to carry out MQTT command on %s: %v", msg.Topic(), err)
+	}
+
+	// Publish the timers even if nothing changed, so that a switch flipped
+	// in vain goes back
+	if _, ok := b.published[babyID]; ok {
+		b.publishState(babyID)
+	}
+}
+
+// parseCommandTopic gets the baby and timer from a command topic
+func (b *Bridge) parseCommandTopic(topic string) (uuid.UUID, models.ActivityType, bool) {
+	parts := strings.Split(strings.TrimPrefix(topic, b.cfg.MQTTTopicPrefix+"/"), "/")
+	if len(parts) != 4 || parts[1] != "timer" || parts[3] != "set" {
+		return uuid.Nil, "", false
+	}
+
+	babyID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	for _, activityType := range timerTypes {
+		if string(activityType) == parts[2] {
+			return babyID, activityType, true
+		}
+	}
+	return uuid.Nil, "", false
+}
+
+// startTimer starts a timer for the baby, unless one is already running
+func (b *Bridge) startTimer(ctx context.Context, babyID uuid.UUID, activityType models.ActivityType) error {
+	running, err := b.runningTimers(babyID)
+	if err != nil {
+		return err
+	}
+	if len(running[activityType]) > 0 {
+		return nil
+	}
+
+	req := handlers.TimerStartRequest{Type: string(activityType)}
+	return b.call(ctx, handlers.StartActivityTimer, req, "baby_id", babyID.String())
+}
+
+// stopTimer stops the baby's running timers of a type
+func (b *Bridge) stopTimer(ctx context.Context, babyID uuid.UUID, activityType models.ActivityType) error {
+	running, err := b.runningTimers(babyID)
+	if err != nil {
+		return err
+	}
+
+	for _, activity := range running[activityType] {
+		err := b.call(ctx, handlers.StopActivityTimer, handlers.TimerStopRequest{},
+			"baby_id", babyID.String(), "id", activity.ID.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runningTimers returns the baby's running timers by activity type
+func (b *Bridge) runningTimers(babyID uuid.UUID) (map[models.ActivityType][]models.Activity, error) {
+	var activities []models.Activity
+	if err := b.db.Where("baby_id = ? AND type IN ? AND end_time IS NULL", babyID, timerTypes).
+		Order("start_time ASC").
+		Find(&activities).Error; err != nil {
+		return nil, err
+	}
+
+	running := make(map[models.ActivityType][]models.Activity)
+	for _, activity := range activities {
+		running[activity.Type] = append(running[activity.Type], activity)
+	}
+	return running, nil
+}
+
+// call runs an API handler as the MQTT user, so that commands are checked,
+// recorded and announced just like API requests. params are the path
+// parameters as name, value pairs.
+func (b *Bridge) call(ctx context.Context, handler echo.HandlerFunc, body interface{}, params ...string) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	c := b.echo.NewContext(req, &discardResponse{header: make(http.Header)})
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	c.Set("db", b.db)
+	c.Set("config", b.cfg)
+	c.Set("events", b.broker)
+	c.Set("user_id", b.user.ID.String())
+	c.Set("username", b.user.Username)
+
+	return handler(c)
+}
+
+// publishJSON publishes a value encoded as JSON
+func (b *Bridge) publishJSON(topic string, value interface{}) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to encode MQTT message for %s: %v", topic, err)
+		return
+	}
+	b.publish(topic, string(payload))
+}
+
+// publish sends a retained message. Nothing is sent while disconnected, as
+// everything is published again on reconnecting.
+func (b *Bridge) publish(topic, payload string) {
+	if !b.client.IsConnectionOpen() {
+		return
+	}
+
+	token := b.client.Publish(topic, qos, true, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		log.Printf("Timed out publishing MQTT message to %s", topic)
+	} else if err := token.Error(); err != nil {
+		log.Printf("Failed to publish MQTT message to %s: %v", topic, err)
+	}
+}
+
+func (b *Bridge) statusTopic() string {
+	return b.cfg.MQTTTopicPrefix + "/status"
+}
+
+func (b *Bridge) babyTopic(babyID uuid.UUID, name string) string {
+	return b.cfg.MQTTTopicPrefix + "/" + babyID.String() + "/" + name
+}
+
+func (b *Bridge) timerTopic(babyID uuid.UUID, activityType models.ActivityType) string {
+	return b.babyTopic(babyID, "timer/"+string(activityType))
+}
+
+// discardResponse is where the responses to commands go, as only the error
+// returned by a handler is of interest
+type discardResponse struct {
+	header http.Header
+}
+
+func (r *discardResponse) Header() http.Header         { return r.header }
+func (r *discardResponse) Write(p []byte) (int, error) { return len(p), nil }
+func (r *discardResponse) WriteHeader(int)             {}
@@ -0,0 +1,191 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/testutil"
+)
+
+// retained keeps the last message seen on each topic of an embedded broker
+type retained struct {
+	mu       sync.Mutex
+	messages map[string]string
+}
+
+func (r *retained) get(topic string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payload, ok := r.messages[topic]
+	return payload, ok
+}
+
+// waitFor waits until the message on a topic satisfies check
+func (r *retained) waitFor(t *testing.T, topic string, check func(payload string) bool) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		payload, ok := r.get(topic)
+		return ok && check(payload)
+	}, 5*time.Second, 10*time.Millisecond, "no matching message on %s", topic)
+}
+
+// startBroker runs an embedded MQTT broker, returning its URL
+func startBroker(t *testing.T) (*mochi.Server, string, *retained) {
+	t.Helper()
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	require.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	require.NoError(t, server.AddListener(listener))
+	require.NoError(t, server.Serve())
+	t.Cleanup(func() { server.Close() })
+
+	seen := &retained{messages: make(map[string]string)}
+	require.NoError(t, server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		seen.mu.Lock()
+		defer seen.mu.Unlock()
+		seen.messages[pk.TopicName] = string(pk.Payload)
+	}))
+
+	return server, "tcp://" + listener.Address(), seen
+}
+
+func TestBridge(t *testing.T) {
+	server, url, seen := startBroker(t)
+	db, user, baby := testutil.SetupDB(t)
+	broker := events.NewBroker()
+
+	cfg := &config.Config{
+		MQTTBrokerURL:       url,
+		MQTTClientID:        "bambino-test",
+		MQTTTopicPrefix:     "bambino",
+		MQTTDiscovery:       true,
+		MQTTDiscoveryPrefix: "homeassistant",
+		MQTTUser:            user.Username,
+	}
+	bridge := New(db, cfg, broker)
+	bridge.RefreshInterval = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- bridge.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	topic := "bambino/" + baby.ID.String()
+	is := func(expected string) func(string) bool {
+		return func(payload string) bool { return payload == expected }
+	}
+
+	t.Run("publishes the baby's state", func(t *testing.T) {
+		seen.waitFor(t, "bambino/status", is(statusOnline))
+		seen.waitFor(t, topic+"/timer/sleep", is(payloadOff))
+		seen.waitFor(t, topic+"/recent", func(payload string) bool {
+			return json.Valid([]byte(payload))
+		})
+		seen.waitFor(t, topic+"/daily", func(payload string) bool {
+			return json.Valid([]byte(payload))
+		})
+	})
+
+	t.Run("announces the baby to Home Assistant", func(t *testing.T) {
+		discoveryTopic := "homeassistant/switch/bambino_" + baby.ID.String() + "/sleep_timer/config"
+		seen.waitFor(t, discoveryTopic, func(payload string) bool { return payload != "" })
+
+		payload, _ := seen.get(discoveryTopic)
+		var config discoveryConfig
+		require.NoError(t, json.Unmarshal([]byte(payload), &config))
+		assert.Equal(t, topic+"/timer/sleep", config.StateTopic)
+		assert.Equal(t, topic+"/timer/sleep/set", config.CommandTopic)
+		assert.Equal(t, "bambino/status", config.AvailabilityTopic)
+		assert.Equal(t, "Test Baby", config.Device.Name)
+	})
+
+	t.Run("starts and stops timers", func(t *testing.T) {
+		require.NoError(t, server.Publish(topic+"/timer/sleep/set", []byte("ON"), false, 1))
+		seen.waitFor(t, topic+"/timer/sleep", is(payloadOn))
+		seen.waitFor(t, topic+"/recent", func(payload string) bool {
+			var recent struct {
+				CurrentlySleeping bool `json:"currently_sleeping"`
+			}
+			return json.Unmarshal([]byte(payload), &recent) == nil && recent.CurrentlySleeping
+		})
+
+		// A second ON doesn't start another timer
+		require.NoError(t, server.Publish(topic+"/timer/sleep/set", []byte("ON"), false, 1))
+		time.Sleep(100 * time.Millisecond)
+
+		var running []models.Activity
+		require.NoError(t, db.Where("baby_id = ? AND end_time IS NULL", baby.ID).Find(&running).Error)
+		require.Len(t, running, 1)
+		assert.Equal(t, models.ActivityTypeSleep, running[0].Type)
+		assert.Equal(t, user.ID, *running[0].CreatedByID)
+
+		require.NoError(t, server.Publish(topic+"/timer/sleep/set", []byte("OFF"), false, 1))
+		seen.waitFor(t, topic+"/timer/sleep", is(payloadOff))
+
+		var stopped models.Activity
+		require.NoError(t, db.First(&stopped, "id = ?", running[0].ID).Error)
+		assert.NotNil(t, stopped.EndTime)
+	})
+
+	t.Run("publishes changes as they happen", func(t *testing.T) {
+		diaper := models.Activity{BabyID: baby.ID, Type: models.ActivityTypeDiaper, StartTime: time.Now()}
+		require.NoError(t, db.Create(&diaper).Error)
+		require.NoError(t, db.Create(&models.DiaperActivity{ActivityID: diaper.ID, Wet: true}).Error)
+
+		event, err := events.New(baby.ID, events.ActivityCreated, nil)
+		require.NoError(t, err)
+		require.NoError(t, broker.Publish(event))
+
+		seen.waitFor(t, topic+"/daily", func(payload string) bool {
+			var daily struct {
+				Counts map[string]int `json:"counts"`
+			}
+			return json.Unmarshal([]byte(payload), &daily) == nil && daily.Counts["diaper"] == 1
+		})
+	})
+
+	t.Run("ignores commands for other babies", func(t *testing.T) {
+		stranger := models.User{ID: uuid.New(), Username: "stranger", PasswordHash: "$2a$10$test.hash"}
+		require.NoError(t, db.Create(&stranger).Error)
+		other := models.Baby{UserID: stranger.ID, Name: "Other", BirthDate: time.Now()}
+		require.NoError(t, db.Create(&other).Error)
+
+		require.NoError(t, server.Publish("bambino/"+other.ID.String()+"/timer/feed/set", []byte("ON"), false, 1))
+		time.Sleep(100 * time.Millisecond)
+
+		var count int64
+		require.NoError(t, db.Model(&models.Activity{}).Where("baby_id = ?", other.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("removes babies the user loses", func(t *testing.T) {
+		require.NoError(t, db.Model(baby).Update("archived_at", time.Now()).Error)
+
+		seen.waitFor(t, "homeassistant/switch/bambino_"+baby.ID.String()+"/sleep_timer/config", is(""))
+		seen.waitFor(t, topic+"/timer/sleep", is(""))
+	})
+}
@@ -0,0 +1,113 @@
+package mqtt
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// entity is something about a baby shown in Home Assistant
+type entity struct {
+	component string // sensor, binary_sensor or switch
+	object    string
+	name      string
+	// topic is the baby's state topic the value is taken from
+	topic         string
+	valueTemplate string
+	deviceClass   string
+	unit          string
+	icon          string
+	// timer is set for the switches that start and stop timers
+	timer models.ActivityType
+}
+
+// entities are announced to Home Assistant for every baby
+var entities = []entity{
+	{component: "sensor", object: "last_feed", name: "Last feed", topic: "recent",
+		valueTemplate: "{{ value_json.last_feed.time if value_json.last_feed else None }}", deviceClass: "timestamp", icon: "mdi:baby-bottle"},
+	{component: "sensor", object: "last_diaper", name: "Last diaper change", topic: "recent",
+		valueTemplate: "{{ value_json.last_diaper.time if value_json.last_diaper else None }}", deviceClass: "timestamp", icon: "mdi:paper-roll"},
+	{component: "sensor", object: "last_sleep", name: "Last woke up", topic: "recent",
+		valueTemplate: "{{ value_json.last_sleep.ended if value_json.last_sleep else None }}", deviceClass: "timestamp", icon: "mdi:sleep-off"},
+	{component: "binary_sensor", object: "sleeping", name: "Sleeping", topic: "recent",
+		valueTemplate: "{{ 'ON' if value_json.currently_sleeping else 'OFF' }}", icon: "mdi:sleep"},
+	{component: "sensor", object: "feeds_today", name: "Feeds today", topic: "daily",
+		valueTemplate: "{{ value_json.counts.feed | default(0) }}", icon: "mdi:baby-bottle"},
+	{component: "sensor", object: "feed_amount_today", name: "Fed today", topic: "daily",
+		valueTemplate: "{{ value_json.totals.feed_amount_ml | default(0) | round(0) }}", unit: "mL", icon: "mdi:baby-bottle"},
+	{component: "sensor", object: "diapers_today", name: "Diapers today", topic: "daily",
+		valueTemplate: "{{ value_json.counts.diaper | default(0) }}", icon: "mdi:paper-roll"},
+	{component: "sensor", object: "wet_diapers_today", name: "Wet diapers today", topic: "daily",
+		valueTemplate: "{{ value_json.diaper_breakdown.wet if value_json.diaper_breakdown else 0 }}", icon: "mdi:water"},
+	{component: "sensor", object: "dirty_diapers_today", name: "Dirty diapers today", topic: "daily",
+		valueTemplate: "{{ value_json.diaper_breakdown.dirty if value_json.diaper_breakdown else 0 }}", icon: "mdi:emoticon-poop"},
+	{component: "sensor", object: "sleep_today", name: "Sleep today", topic: "daily",
+		valueTemplate: "{{ value_json.totals.sleep_hours | default(0) | round(1) }}", deviceClass: "duration", unit: "h", icon: "mdi:sleep"},
+	{component: "switch", object: "feed_timer", name: "Feed timer", timer: models.ActivityTypeFeed, icon: "mdi:baby-bottle"},
+	{component: "switch", object: "pump_timer", name: "Pump timer", timer: models.ActivityTypePump, icon: "mdi:timer-outline"},
+	{component: "switch", object: "sleep_timer", name: "Sleep timer", timer: models.ActivityTypeSleep, icon: "mdi:sleep"},
+}
+
+// discoveryConfig is the message that announces an entity to Home Assistant
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	CommandTopic      string          `json:"command_topic,omitempty"`
+	ValueTemplate     string          `json:"value_template,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+// discoveryDevice groups a baby's entities in Home Assistant
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// publishDiscovery announces a baby's entities to Home Assistant, if
+// discovery is enabled
+func (b *Bridge) publishDiscovery(baby *models.Baby) {
+	if !b.cfg.MQTTDiscovery {
+		return
+	}
+
+	device := discoveryDevice{
+		Identifiers:  []string{nodeID(baby.ID)},
+		Name:         baby.Name,
+		Manufacturer: "Bambino",
+	}
+	for _, entity := range entities {
+		config := discoveryConfig{
+			Name:              entity.name,
+			UniqueID:          nodeID(baby.ID) + "_" + entity.object,
+			ValueTemplate:     entity.valueTemplate,
+			DeviceClass:       entity.deviceClass,
+			UnitOfMeasurement: entity.unit,
+			Icon:              entity.icon,
+			AvailabilityTopic: b.statusTopic(),
+			Device:            device,
+		}
+		if entity.timer != "" {
+			config.StateTopic = b.timerTopic(baby.ID, entity.timer)
+			config.CommandTopic = config.StateTopic + "/set"
+		} else {
+			config.StateTopic = b.babyTopic(baby.ID, entity.topic)
+		}
+		b.publishJSON(b.discoveryTopic(baby.ID, entity), config)
+	}
+}
+
+// discoveryTopic is where an entity of a baby is announced
+func (b *Bridge) discoveryTopic(babyID uuid.UUID, entity entity) string {
+	return b.cfg.MQTTDiscoveryPrefix + "/" + entity.component + "/" + nodeID(babyID) + "/" + entity.object + "/config"
+}
+
+// nodeID identifies a baby to Home Assistant
+func nodeID(babyID uuid.UUID) string {
+	return "bambino_" + babyID.String()
+}
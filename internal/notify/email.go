@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/engineervix/bambino/internal/config"
+)
+
+// implicitTLSPort is the SMTP port that expects TLS from the start rather
+// than after STARTTLS
+const implicitTLSPort = "465"
+
+// EmailSettings sends notifications to an email address
+type EmailSettings struct {
+	Address string `json:"address"`
+}
+
+type emailChannel struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func newEmailChannel(cfg *config.Config) *emailChannel {
+	return &emailChannel{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     cfg.SMTPFrom,
+	}
+}
+
+func (e *emailChannel) Validate(settings json.RawMessage) error {
+	var s EmailSettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+	if _, err := mail.ParseAddress(s.Address); err != nil {
+		return errors.New("address must be a valid email address")
+	}
+	return nil
+}
+
+func (e *emailChannel) Send(ctx context.Context, settings json.RawMessage, msg Message) error {
+	var s EmailSettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(s.Address)
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(e.from)
+	if err != nil {
+		return err
+	}
+
+	message, err := composeEmail(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && e.port != implicitTLSPort {
+		if err := client.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		// net/smtp refuses to send the password unencrypted, except to
+		// localhost
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the SMTP server, giving up when ctx is done
+func (e *emailChannel) dial(ctx context.Context) (*smtp.Client, error) {
+	address := net.JoinHostPort(e.host, e.port)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if e.port == implicitTLSPort {
+		conn = tls.Client(conn, &tls.Config{ServerName: e.host})
+	}
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// composeEmail builds a plain text email for a message
+func composeEmail(from, to *mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	text := msg.Body
+	if msg.URL != "" {
+		text += "\r\n\r\n" + msg.URL
+	}
+	if _, err := body.Write([]byte(text + "\r\n")); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,181 @@
+// Package notify sends notifications to users through the channels they
+// have set up, such as email or push notifications.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+const (
+	// sendTimeout limits how long sending a notification may take
+	sendTimeout = 15 * time.Second
+)
+
+var (
+	// ErrUnsupported is returned for channel types the server isn't set up
+	// to send through
+	ErrUnsupported = errors.New("notification channel type is not supported on this server")
+	// ErrChannelGone is returned when a channel can never be sent to
+	// again, such as a browser that has unsubscribed from Web Push
+	ErrChannelGone = errors.New("notification channel no longer exists")
+)
+
+// Message is a notification
+type Message struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// URL is opened when the notification is clicked, if set
+	URL string `json:"url,omitempty"`
+}
+
+// Channel sends notifications one way, such as by email
+type Channel interface {
+	// Validate checks the settings a user has given for a channel
+	Validate(settings json.RawMessage) error
+	// Send delivers a message using a channel's settings
+	Send(ctx context.Context, settings json.RawMessage, msg Message) error
+}
+
+// Notifier sends notifications through the channels the server supports
+type Notifier struct {
+	db       *gorm.DB
+	channels map[models.NotificationChannelType]Channel
+}
+
+// New creates a Notifier with every channel the configuration allows.
+// Email and Web Push are only available once they are set up. Channels on
+// the server's own network are only sent to if the configuration allows it.
+func New(db *gorm.DB, cfg *config.Config) *Notifier {
+	// Checked when the configuration was loaded
+	networks, _ := cfg.OutboundNetworks()
+	policy := utils.NewOutboundPolicy(networks)
+	client := policy.Client(sendTimeout)
+
+	channels := map[models.NotificationChannelType]Channel{
+		models.NotificationChannelWebhook: &webhookChannel{client: client, policy: policy},
+		models.NotificationChannelNtfy:    &ntfyChannel{client: client, policy: policy},
+		models.NotificationChannelGotify:  &gotifyChannel{client: client, policy: policy},
+	}
+	if cfg.SMTPEnabled() {
+		channels[models.NotificationChannelEmail] = newEmailChannel(cfg)
+	}
+	if cfg.WebPushEnabled() {
+		channels[models.NotificationChannelWebPush] = newWebPushChannel(cfg, client)
+	}
+
+	return &Notifier{db: db, channels: channels}
+}
+
+// Types returns the channel types that notifications can be sent through
+func (n *Notifier) Types() []models.NotificationChannelType {
+	types := make([]models.NotificationChannelType, 0, len(n.channels))
+	for t := range n.channels {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// Validate checks the settings for a channel of the given type
+func (n *Notifier) Validate(channelType models.NotificationChannelType, settings json.RawMessage) error {
+	channel, ok := n.channels[channelType]
+	if !ok {
+		return ErrUnsupported
+	}
+	return channel.Validate(settings)
+}
+
+// Send delivers a message through one of a user's channels, enabled or not
+func (n *Notifier) Send(ctx context.Context, channel *models.NotificationChannel, msg Message) error {
+	sender, ok := n.channels[channel.Type]
+	if !ok {
+		return ErrUnsupported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return sender.Send(ctx, json.RawMessage(channel.Settings), msg)
+}
+
+// NotifyUser delivers a message through each of a user's enabled channels.
+// Channels that no longer exist are disabled. The errors from any channels
+// that failed are returned together.
+func (n *Notifier) NotifyUser(ctx context.Context, userID uuid.UUID, msg Message) error {
+	var channels []models.NotificationChannel
+	if err := n.db.Where("user_id = ? AND enabled = ?", userID, true).
+		Order("created_at ASC").
+		Find(&channels).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range channels {
+		channel := &channels[i]
+		err := n.Send(ctx, channel, msg)
+		if errors.Is(err, ErrChannelGone) {
+			err = n.db.Model(channel).Update("enabled", false).Error
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s channel %q: %w", channel.Type, channel.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeSettings reads a channel's settings, rejecting unknown fields
+func decodeSettings(settings json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(settings))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	return nil
+}
+
+// isHTTPURL reports whether a URL can be sent requests
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// checkAddress refuses URLs on the server's own network that the policy
+// doesn't allow
+func checkAddress(policy *utils.OutboundPolicy, raw string) error {
+	if err := policy.CheckURL(raw); err != nil {
+		return errors.New("url must not point to a loopback, private or link-local address")
+	}
+	return nil
+}
+
+// do sends a request, failing on any response other than a 2xx. The
+// response body isn't reported, as it could be from any service the URL
+// points to.
+func do(client *http.Client, req *http.Request) error {
+	req.Header.Set("User-Agent", "Bambino-Notify")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+	"github.com/engineervix/bambino/internal/webhooks"
+)
+
+var testMessage = Message{Title: "Feed due 🍼", Body: "It's been 3 hours since the last feed", URL: "https://bambino.example.com"}
+
+// receivedRequest is a request made to a test server
+type receivedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// receiver starts a server that answers with status and passes on the
+// requests it gets
+func receiver(t *testing.T, status int) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+	requests := make(chan receivedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- receivedRequest{path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func settings(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestTypes(t *testing.T) {
+	notifier := New(nil, &config.Config{})
+	assert.Equal(t, []models.NotificationChannelType{"gotify", "ntfy", "webhook"}, notifier.Types())
+
+	notifier = New(nil, &config.Config{
+		SMTPHost:        "localhost",
+		SMTPFrom:        "bambino@example.com",
+		VAPIDPublicKey:  "public",
+		VAPIDPrivateKey: "private",
+	})
+	assert.Equal(t, []models.NotificationChannelType{"email", "gotify", "ntfy", "webhook", "webpush"}, notifier.Types())
+
+	err := New(nil, &config.Config{}).Validate(models.NotificationChannelEmail, json.RawMessage(`{"address":"a@example.com"}`))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestWebhookChannel(t *testing.T) {
+	server, requests := receiver(t, http.StatusNoContent)
+	channel := &webhookChannel{client: server.Client(), policy: utils.NewOutboundPolicy(nil)}
+
+	assert.Error(t, channel.Validate(json.RawMessage(`{"url":"ftp://example.com"}`)))
+	assert.Error(t, channel.Validate(json.RawMessage(`{"url":"https://example.com","colour":"red"}`)))
+	assert.NoError(t, channel.Validate(json.RawMessage(`{"url":"https://example.com"}`)))
+	assert.ErrorContains(t, channel.Validate(json.RawMessage(`{"url":"http://169.254.169.254/latest"}`)), "private")
+
+	require.NoError(t, channel.Send(context.Background(), settings(t, WebhookSettings{URL: server.URL, Secret: "secret"}), testMessage))
+
+	req := <-requests
+	var payload webhookPayload
+	require.NoError(t, json.Unmarshal(req.body, &payload))
+	assert.Equal(t, testMessage, payload.Message)
+	assert.False(t, payload.SentAt.IsZero())
+	assert.Equal(t, "notification", req.header.Get(webhooks.EventHeader))
+	assert.True(t, webhooks.Verify("secret", req.body, req.header.Get(webhooks.SignatureHeader)))
+
+	t.Run("failures are reported", func(t *testing.T) {
+		failing, _ := receiver(t, http.StatusInternalServerError)
+		err := channel.Send(context.Background(), settings(t, WebhookSettings{URL: failing.URL}), testMessage)
+		assert.EqualError(t, err, "unexpected status 500")
+	})
+}
+
+func TestNtfyChannel(t *testing.T) {
+	server, requests := receiver(t, http.StatusOK)
+	channel := &ntfyChannel{client: server.Client(), policy: utils.NewOutboundPolicy(nil)}
+
+	assert.Error(t, channel.Validate(json.RawMessage(`{"url":"https://ntfy.sh/baby","priority":6}`)))
+	assert.NoError(t, channel.Validate(json.RawMessage(`{"url":"https://ntfy.sh/baby","priority":4}`)))
+
+	require.NoError(t, channel.Send(context.Background(), settings(t, NtfySettings{URL: server.URL + "/baby", Token: "tk_123", Priority: 4}), testMessage))
+
+	req := <-requests
+	assert.Equal(t, "/baby", req.path)
+	assert.Equal(t, testMessage.Body, string(req.body))
+	title, err := new(mime.WordDecoder).DecodeHeader(req.header.Get("Title"))
+	require.NoError(t, err)
+	assert.Equal(t, testMessage.Title, title)
+	assert.Equal(t, testMessage.URL, req.header.Get("Click"))
+	assert.Equal(t, "4", req.header.Get("Priority"))
+	assert.Equal(t, "Bearer tk_123", req.header.Get("Authorization"))
+}
+
+func TestGotifyChannel(t *testing.T) {
+	server, requests := receiver(t, http.StatusOK)
+	channel := &gotifyChannel{client: server.Client(), policy: utils.NewOutboundPolicy(nil)}
+
+	assert.Error(t, channel.Validate(json.RawMessage(`{"url":"https://gotify.example.com"}`)))
+	assert.NoError(t, channel.Validate(json.RawMessage(`{"url":"https://gotify.example.com","token":"app"}`)))
+
+	require.NoError(t, channel.Send(context.Background(), settings(t, GotifySettings{URL: server.URL + "/", Token: "app", Priority: 5}), testMessage))
+
+	req := <-requests
+	assert.Equal(t, "/message", req.path)
+	assert.Equal(t, "app", req.header.Get("X-Gotify-Key"))
+	assert.JSONEq(t, `{
+		"title": "Feed due 🍼",
+		"message": "It's been 3 hours since the last feed",
+		"priority": 5,
+		"extras": {"client::notification": {"click": {"url": "https://bambino.example.com"}}}
+	}`, string(req.body))
+}
+
+// smtpSink accepts mail on a local port, passing on each message's data
+func smtpSink(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+				reply("220 sink ready")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch command := strings.ToUpper(strings.TrimSpace(line)); {
+					case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+						reply("250 sink")
+					case command == "DATA":
+						reply("354 go ahead")
+						var data strings.Builder
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+							data.WriteString(line)
+						}
+						messages <- data.String()
+						reply("250 queued")
+					case command == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func TestEmailChannel(t *testing.T) {
+	address, messages := smtpSink(t)
+	host, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+
+	notifier := New(nil, &config.Config{SMTPHost: host, SMTPPort: port, SMTPFrom: "Bambino <bambino@example.com>"})
+	channel := &models.NotificationChannel{
+		Type:     models.NotificationChannelEmail,
+		Settings: `{"address":"parent@example.com"}`,
+	}
+
+	assert.Error(t, notifier.Validate(models.NotificationChannelEmail, json.RawMessage(`{"address":"not an address"}`)))
+	assert.NoError(t, notifier.Validate(models.NotificationChannelEmail, json.RawMessage(channel.Settings)))
+
+	require.NoError(t, notifier.Send(context.Background(), channel, testMessage))
+
+	message := <-messages
+	assert.Contains(t, message, "From: \"Bambino\" <bambino@example.com>\r\n")
+	assert.Contains(t, message, "To: <parent@example.com>\r\n")
+	assert.Contains(t, message, "Subject: =?utf-8?q?Feed_due_=F0=9F=8D=BC?=\r\n")
+	assert.Contains(t, message, "It's been 3 hours since the last feed")
+	assert.Contains(t, message, "https://bambino.example.com")
+}
+
+func TestWebPushChannel(t *testing.T) {
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	// The keys a browser would give for its subscription
+	browserKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	_, err = rand.Read(auth)
+	require.NoError(t, err)
+
+	server, requests := receiver(t, http.StatusCreated)
+	channel := newWebPushChannel(&config.Config{
+		VAPIDPublicKey:  publicKey,
+		VAPIDPrivateKey: privateKey,
+		VAPIDSubject:    "mailto:admin@example.com",
+	}, server.Client())
+
+	subscription := WebPushSettings{Endpoint: server.URL + "/push/abc"}
+	subscription.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+	subscription.Keys.P256dh = base64.RawURLEncoding.EncodeToString(browserKey.PublicKey().Bytes())
+
+	assert.Error(t, channel.Validate(settings(t, subscription)), "push endpoints must use https")
+	secure := subscription
+	secure.Endpoint = "https://push.example.com/abc"
+	assert.NoError(t, channel.Validate(settings(t, secure)))
+
+	require.NoError(t, channel.Send(context.Background(), settings(t, subscription), testMessage))
+
+	req := <-requests
+	assert.Equal(t, "/push/abc", req.path)
+	assert.Equal(t, "aes128gcm", req.header.Get("Content-Encoding"))
+	assert.Contains(t, req.header.Get("Authorization"), "vapid t=")
+	assert.Contains(t, req.header.Get("Authorization"), "k="+publicKey)
+	assert.NotContains(t, string(req.body), testMessage.Body, "the payload is encrypted")
+
+	t.Run("expired subscriptions are gone", func(t *testing.T) {
+		expired, _ := receiver(t, http.StatusGone)
+		subscription.Endpoint = expired.URL
+		err := channel.Send(context.Background(), settings(t, subscription), testMessage)
+		assert.ErrorIs(t, err, ErrChannelGone)
+	})
+}
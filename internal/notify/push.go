@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+// NtfySettings sends notifications to an ntfy topic, given by its full URL
+// such as https://ntfy.sh/my-topic. Token is needed for protected topics.
+type NtfySettings struct {
+	URL      string `json:"url"`
+	Token    string `json:"token,omitempty"`
+	Priority int    `json:"priority,omitempty"` // 1 to 5
+}
+
+type ntfyChannel struct {
+	client *http.Client
+	policy *utils.OutboundPolicy
+}
+
+func (n *ntfyChannel) Validate(settings json.RawMessage) error {
+	var s NtfySettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+	if !isHTTPURL(s.URL) {
+		return errors.New("url must be the http or https URL of an ntfy topic")
+	}
+	if err := checkAddress(n.policy, s.URL); err != nil {
+		return err
+	}
+	if s.Priority < 0 || s.Priority > 5 {
+		return errors.New("priority must be between 1 and 5")
+	}
+	return nil
+}
+
+func (n *ntfyChannel) Send(ctx context.Context, settings json.RawMessage, msg Message) error {
+	var s NtfySettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	// ntfy decodes RFC 2047 headers, which keeps non-ASCII titles intact
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", msg.Title))
+	if msg.URL != "" {
+		req.Header.Set("Click", msg.URL)
+	}
+	if s.Priority != 0 {
+		req.Header.Set("Priority", strconv.Itoa(s.Priority))
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	return do(n.client, req)
+}
+
+// GotifySettings sends notifications to a Gotify server, using the token
+// of an application created on it
+type GotifySettings struct {
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// gotifyMessage is the body Gotify's message API expects
+type gotifyMessage struct {
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority,omitempty"`
+	Extras   map[string]interface{} `json:"extras,omitempty"`
+}
+
+type gotifyChannel struct {
+	client *http.Client
+	policy *utils.OutboundPolicy
+}
+
+func (g *gotifyChannel) Validate(settings json.RawMessage) error {
+	var s GotifySettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+	if !isHTTPURL(s.URL) {
+		return errors.New("url must be the http or https URL of a Gotify server")
+	}
+	if err := checkAddress(g.policy, s.URL); err != nil {
+		return err
+	}
+	if s.Token == "" {
+		return errors.New("token is required")
+	}
+	if s.Priority < 0 || s.Priority > 10 {
+		return errors.New("priority must be between 0 and 10")
+	}
+	return nil
+}
+
+func (g *gotifyChannel) Send(ctx context.Context, settings json.RawMessage, msg Message) error {
+	var s GotifySettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+
+	message := gotifyMessage{Title: msg.Title, Message: msg.Body, Priority: s.Priority}
+	if msg.URL != "" {
+		message.Extras = map[string]interface{}{
+			"client::notification": map[string]interface{}{
+				"click": map[string]string{"url": msg.URL},
+			},
+		}
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.Token)
+
+	return do(g.client, req)
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/engineervix/bambino/internal/utils"
+	"github.com/engineervix/bambino/internal/webhooks"
+)
+
+// notificationEvent is the event header value of notification webhooks
+const notificationEvent = "notification"
+
+// WebhookSettings sends notifications as JSON to a URL. If Secret is set,
+// requests are signed with it like activity webhooks.
+type WebhookSettings struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// webhookPayload is the body sent to a notification webhook
+type webhookPayload struct {
+	Message
+	SentAt time.Time `json:"sent_at"`
+}
+
+type webhookChannel struct {
+	client *http.Client
+	policy *utils.OutboundPolicy
+}
+
+func (w *webhookChannel) Validate(settings json.RawMessage) error {
+	var s WebhookSettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+	if !isHTTPURL(s.URL) {
+		return errors.New("url must be an http or https URL")
+	}
+	if err := checkAddress(w.policy, s.URL); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *webhookChannel) Send(ctx context.Context, settings json.RawMessage, msg Message) error {
+	var s WebhookSettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookPayload{Message: msg, SentAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.EventHeader, notificationEvent)
+	if s.Secret != "" {
+		req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(s.Secret, body))
+	}
+
+	return do(w.client, req)
+}
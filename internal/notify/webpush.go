@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SherClockHolmes/webpush-go"
+
+	"github.com/engineervix/bambino/internal/config"
+)
+
+// webPushTTL is how long, in seconds, a push service keeps a notification
+// for a browser that is offline
+const webPushTTL = 24 * 60 * 60
+
+// WebPushSettings is a browser's push subscription, as returned by
+// PushSubscription.toJSON()
+type WebPushSettings struct {
+	Endpoint       string       `json:"endpoint"`
+	ExpirationTime *int64       `json:"expirationTime,omitempty"`
+	Keys           webpush.Keys `json:"keys"`
+}
+
+type webPushChannel struct {
+	client     *http.Client
+	publicKey  string
+	privateKey string
+	subscriber string
+}
+
+func newWebPushChannel(cfg *config.Config, client *http.Client) *webPushChannel {
+	return &webPushChannel{
+		client:     client,
+		publicKey:  cfg.VAPIDPublicKey,
+		privateKey: cfg.VAPIDPrivateKey,
+		// The library adds the mailto: back for anything other than a URL
+		subscriber: strings.TrimPrefix(cfg.VAPIDSubject, "mailto:"),
+	}
+}
+
+func (w *webPushChannel) Validate(settings json.RawMessage) error {
+	var s WebPushSettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+	if u, err := url.Parse(s.Endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("endpoint must be an https URL")
+	}
+	if s.Keys.Auth == "" || s.Keys.P256dh == "" {
+		return errors.New("keys.auth and keys.p256dh are required")
+	}
+	return nil
+}
+
+func (w *webPushChannel) Send(ctx context.Context, settings json.RawMessage, msg Message) error {
+	var s WebPushSettings
+	if err := decodeSettings(settings, &s); err != nil {
+		return err
+	}
+
+	// The service worker shows the message from this JSON
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{Endpoint: s.Endpoint, Keys: s.Keys}, &webpush.Options{
+		HTTPClient:      w.client,
+		Subscriber:      w.subscriber,
+		TTL:             webPushTTL,
+		VAPIDPublicKey:  w.publicKey,
+		VAPIDPrivateKey: w.privateKey,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrChannelGone
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GenerateVAPIDKeys creates a key pair for VAPID_PUBLIC_KEY and
+// VAPID_PRIVATE_KEY
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	privateKey, publicKey, err = webpush.GenerateVAPIDKeys()
+	return publicKey, privateKey, err
+}
@@ -0,0 +1,290 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	// Time zones are looked up from the binary, as the server's image
+	// doesn't ship with them
+	_ "time/tzdata"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/notify"
+)
+
+const (
+	// DefaultSnooze is how long a reminder is snoozed for when no time is
+	// given
+	DefaultSnooze = 15 * time.Minute
+	// MaxSnooze is the longest a reminder can be snoozed for
+	MaxSnooze = 24 * time.Hour
+)
+
+// timerTypes are the activities that can have a running timer
+var timerTypes = []models.ActivityType{
+	models.ActivityTypeFeed,
+	models.ActivityTypePump,
+	models.ActivityTypeSleep,
+}
+
+// ParseSchedule reads a standard five-field cron expression, or a
+// descriptor such as @daily, in the named time zone
+func ParseSchedule(spec, timeZone string) (cron.Schedule, error) {
+	if strings.Contains(spec, "TZ=") {
+		return nil, errors.New("set the time zone separately from the schedule")
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", timeZone)
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	if spec, ok := schedule.(*cron.SpecSchedule); ok {
+		spec.Location = location
+	}
+	return schedule, nil
+}
+
+// NextRun works out when a scheduled reminder next falls due after a time.
+// It is nil for reminders that follow an activity.
+func NextRun(r *models.Reminder, after time.Time) (*time.Time, error) {
+	if r.FollowsActivity() {
+		return nil, nil
+	}
+	schedule, err := ParseSchedule(r.Schedule, r.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	next := schedule.Next(after)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// DueAt works out when a reminder is next due, or nil if it is waiting for
+// an activity. Reminders that follow an activity are sent once for each
+// one that started after the reminder was switched on, and a snooze stands
+// in for the rule until it is sent, unless a newer activity has since moved
+// the reminder on.
+func DueAt(db *gorm.DB, r *models.Reminder) (*time.Time, error) {
+	if !r.Enabled {
+		return nil, nil
+	}
+	if !r.FollowsActivity() {
+		if r.SnoozedUntil != nil {
+			return r.SnoozedUntil, nil
+		}
+		return r.NextRunAt, nil
+	}
+
+	started, err := latestStart(db, r.BabyID, r.ActivityType)
+	if err != nil || started == nil || started.Before(r.EnabledAt) {
+		return nil, err
+	}
+	due := started.Add(time.Duration(r.DelayMinutes) * time.Minute)
+	if r.SnoozedUntil != nil && (r.LastSentAt == nil || !due.After(*r.LastSentAt)) {
+		return r.SnoozedUntil, nil
+	}
+	if r.LastSentAt != nil && !r.LastSentAt.Before(due) {
+		return nil, nil
+	}
+	return &due, nil
+}
+
+// Paused reports whether a timer is running for the reminder's activity
+func Paused(db *gorm.DB, r *models.Reminder) (bool, error) {
+	if !isTimerType(r.ActivityType) {
+		return false, nil
+	}
+	var count int64
+	err := db.Model(&models.Activity{}).
+		Where("baby_id = ? AND type = ? AND end_time IS NULL", r.BabyID, r.ActivityType).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Scheduler sends reminders as they fall due. Everything it needs is kept
+// in the database, so reminders carry on across restarts, and ones that
+// fell due while the server was down are sent when it comes back.
+type Scheduler struct {
+	db       *gorm.DB
+	notifier *notify.Notifier
+	// PollInterval is how often reminders are checked
+	PollInterval time.Duration
+}
+
+// NewScheduler creates a Scheduler for the reminders in db
+func NewScheduler(db *gorm.DB, notifier *notify.Notifier) *Scheduler {
+	return &Scheduler{
+		db:           db,
+		notifier:     notifier,
+		PollInterval: 30 * time.Second,
+	}
+}
+
+// Run sends reminders as they fall due until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.SendDue(ctx); err != nil {
+			log.Printf("Failed to send reminders: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends every reminder that is due and not paused. Reminders whose
+// owners have lost access to the baby are skipped.
+func (s *Scheduler) SendDue(ctx context.Context) error {
+	now := time.Now()
+
+	var candidates []models.Reminder
+	if err := s.db.Preload("Baby").
+		Where("enabled = ?", true).
+		Where("schedule = '' OR schedule IS NULL OR next_run_at <= ? OR snoozed_until <= ?", now, now).
+		Where("EXISTS (?)", s.db.Model(&models.BabyMember{}).Select("1").
+			Where("baby_members.baby_id = reminders.baby_id AND baby_members.user_id = reminders.user_id")).
+		Find(&candidates).Error; err != nil {
+		return err
+	}
+
+	for i := range candidates {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r := &candidates[i]
+
+		due, err := DueAt(s.db, r)
+		if err != nil {
+			return err
+		}
+		if due == nil || due.After(now) {
+			continue
+		}
+
+		// A reminder about a feed that is under way would only get in the
+		// way, so it waits for the timer to stop
+		paused, err := Paused(s.db, r)
+		if err != nil {
+			return err
+		}
+		if paused {
+			continue
+		}
+
+		if err := s.send(ctx, r, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send records that a reminder was sent, then notifies its owner
+func (s *Scheduler) send(ctx context.Context, r *models.Reminder, now time.Time) error {
+	next, err := NextRun(r, now)
+	if err != nil {
+		// The schedule was checked when it was saved, so this only happens
+		// if something has changed underneath it
+		log.Printf("Reminder %s has an invalid schedule: %v", r.ID, err)
+	}
+
+	// Claim the reminder, so that other instances don't send it as well
+	claim := s.db.Model(r).
+		Where("sent_count = ?", r.SentCount).
+		Updates(map[string]interface{}{
+			"sent_count":      r.SentCount + 1,
+			"last_sent_at":    now,
+			"next_run_at":     next,
+			"snoozed_until":   nil,
+			"acknowledged_at": nil,
+		})
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil
+	}
+
+	msg, err := s.message(r, now)
+	if err != nil {
+		return err
+	}
+	// A reminder that couldn't be delivered isn't retried, as it would
+	// arrive late and be repeated on every channel that did work
+	if err := s.notifier.NotifyUser(ctx, r.UserID, msg); err != nil {
+		log.Printf("Failed to send reminder %s: %v", r.ID, err)
+	}
+	return nil
+}
+
+// message describes a reminder for its notification
+func (s *Scheduler) message(r *models.Reminder, now time.Time) (notify.Message, error) {
+	body := "Reminder for " + r.Baby.Name
+	if r.FollowsActivity() {
+		started, err := latestStart(s.db, r.BabyID, r.ActivityType)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		if started != nil {
+			body = fmt.Sprintf("It's been %s since %s's last %s started", formatDuration(now.Sub(*started)), r.Baby.Name, r.ActivityType)
+		}
+	}
+	if r.Notes != "" {
+		body += "\n\n" + r.Notes
+	}
+	return notify.Message{Title: r.Title, Body: body}, nil
+}
+
+// latestStart returns when the baby's latest activity of a type started, or
+// nil if there are none
+func latestStart(db *gorm.DB, babyID uuid.UUID, activityType models.ActivityType) (*time.Time, error) {
+	var latest models.Activity
+	err := db.Select("start_time").
+		Where("baby_id = ? AND type = ?", babyID, activityType).
+		Order("start_time DESC").
+		First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &latest.StartTime, nil
+}
+
+func isTimerType(activityType models.ActivityType) bool {
+	for _, t := range timerTypes {
+		if t == activityType {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDuration writes a duration in hours and minutes, such as "2h 30m"
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	}
+}
@@ -0,0 +1,259 @@
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/notify"
+	"github.com/engineervix/bambino/internal/testutil"
+)
+
+// inbox adds a webhook notification channel for the user, passing on the
+// messages sent to it
+func inbox(t *testing.T, db *gorm.DB, user *models.User) <-chan notify.Message {
+	t.Helper()
+	messages := make(chan notify.Message, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		messages <- msg
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	require.NoError(t, db.Create(&models.NotificationChannel{
+		UserID:   user.ID,
+		Type:     models.NotificationChannelWebhook,
+		Name:     "test",
+		Settings: `{"url":"` + server.URL + `"}`,
+	}).Error)
+	return messages
+}
+
+func received(messages <-chan notify.Message) []notify.Message {
+	var list []notify.Message
+	for {
+		select {
+		case msg := <-messages:
+			list = append(list, msg)
+		default:
+			return list
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	schedule, err := ParseSchedule("0 9 * * *", "Africa/Lusaka")
+	require.NoError(t, err)
+	next := schedule.Next(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 3, 2, 7, 0, 0, 0, time.UTC), next.UTC())
+
+	_, err = ParseSchedule("@daily", "UTC")
+	assert.NoError(t, err)
+
+	for _, tc := range []struct{ spec, timeZone string }{
+		{"0 9 * * *", "Mars/Olympus_Mons"},
+		{"CRON_TZ=Europe/London 0 9 * * *", "UTC"},
+		{"every morning", "UTC"},
+		{"0 0 9 * * *", "UTC"},
+	} {
+		_, err := ParseSchedule(tc.spec, tc.timeZone)
+		assert.Error(t, err, tc.spec)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	db, user, baby := testutil.SetupDB(t)
+	messages := inbox(t, db, user)
+	scheduler := NewScheduler(db, notify.New(db, &config.Config{OutboundAllowedNetworks: "127.0.0.1"}))
+	ctx := context.Background()
+
+	addFeed := func(started time.Time, running bool) *models.Activity {
+		activity := &models.Activity{BabyID: baby.ID, Type: models.ActivityTypeFeed, StartTime: started}
+		if !running {
+			ended := started.Add(20 * time.Minute)
+			activity.EndTime = &ended
+		}
+		require.NoError(t, db.Create(activity).Error)
+		return activity
+	}
+	reload := func(r *models.Reminder) {
+		id := r.ID
+		*r = models.Reminder{}
+		require.NoError(t, db.First(r, "id = ?", id).Error)
+	}
+
+	t.Run("scheduled reminders are sent once and rescheduled", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		reminder := &models.Reminder{
+			UserID:    user.ID,
+			BabyID:    baby.ID,
+			Title:     "Vitamin D",
+			Notes:     "One drop",
+			Schedule:  "0 9 * * *",
+			TimeZone:  "UTC",
+			Enabled:   true,
+			NextRunAt: &past,
+		}
+		require.NoError(t, db.Create(reminder).Error)
+		defer db.Delete(reminder)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		require.NoError(t, scheduler.SendDue(ctx))
+
+		sent := received(messages)
+		require.Len(t, sent, 1)
+		assert.Equal(t, "Vitamin D", sent[0].Title)
+		assert.Equal(t, "Reminder for Test Baby\n\nOne drop", sent[0].Body)
+
+		reload(reminder)
+		assert.Equal(t, 1, reminder.SentCount)
+		require.NotNil(t, reminder.NextRunAt)
+		assert.True(t, reminder.NextRunAt.After(time.Now()))
+		assert.Equal(t, 9, reminder.NextRunAt.UTC().Hour())
+	})
+
+	t.Run("activities from before a reminder was switched on are ignored", func(t *testing.T) {
+		earlier := addFeed(time.Now().Add(-4*time.Hour), false)
+		defer db.Delete(earlier)
+
+		reminder := &models.Reminder{
+			UserID:       user.ID,
+			BabyID:       baby.ID,
+			Title:        "Feed due",
+			ActivityType: models.ActivityTypeFeed,
+			DelayMinutes: 180,
+			Enabled:      true,
+		}
+		require.NoError(t, db.Create(reminder).Error)
+		defer db.Delete(reminder)
+
+		due, err := DueAt(db, reminder)
+		require.NoError(t, err)
+		assert.Nil(t, due)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Empty(t, received(messages))
+	})
+
+	// Switched on before any of the feeds below
+	reminder := &models.Reminder{
+		UserID:       user.ID,
+		BabyID:       baby.ID,
+		Title:        "Feed due",
+		ActivityType: models.ActivityTypeFeed,
+		DelayMinutes: 180,
+		Enabled:      true,
+		EnabledAt:    time.Now().Add(-24 * time.Hour),
+	}
+	require.NoError(t, db.Create(reminder).Error)
+
+	t.Run("reminders wait for an activity", func(t *testing.T) {
+		due, err := DueAt(db, reminder)
+		require.NoError(t, err)
+		assert.Nil(t, due)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Empty(t, received(messages))
+	})
+
+	t.Run("reminders follow the latest activity", func(t *testing.T) {
+		addFeed(time.Now().Add(-5*time.Hour), false)
+		recent := addFeed(time.Now().Add(-time.Hour), false)
+
+		due, err := DueAt(db, reminder)
+		require.NoError(t, err)
+		require.NotNil(t, due)
+		assert.WithinDuration(t, recent.StartTime.Add(3*time.Hour), *due, time.Second)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Empty(t, received(messages))
+	})
+
+	t.Run("reminders are paused while a timer runs", func(t *testing.T) {
+		running := addFeed(time.Now().Add(-4*time.Hour), true)
+
+		paused, err := Paused(db, reminder)
+		require.NoError(t, err)
+		assert.True(t, paused)
+
+		// Move the latest feed back so the reminder is overdue
+		require.NoError(t, db.Where("baby_id = ? AND start_time > ?", baby.ID, running.StartTime).Delete(&models.Activity{}).Error)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Empty(t, received(messages))
+
+		ended := time.Now()
+		require.NoError(t, db.Model(running).Update("end_time", ended).Error)
+		require.NoError(t, scheduler.SendDue(ctx))
+
+		sent := received(messages)
+		require.Len(t, sent, 1)
+		assert.Equal(t, "It's been 4h since Test Baby's last feed started", sent[0].Body)
+	})
+
+	t.Run("reminders are sent once for each activity", func(t *testing.T) {
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Empty(t, received(messages))
+
+		reload(reminder)
+		due, err := DueAt(db, reminder)
+		require.NoError(t, err)
+		assert.Nil(t, due)
+	})
+
+	t.Run("snoozed reminders are sent again", func(t *testing.T) {
+		snoozed := time.Now().Add(-time.Second)
+		require.NoError(t, db.Model(reminder).Updates(map[string]interface{}{
+			"snoozed_until":   snoozed,
+			"acknowledged_at": nil,
+		}).Error)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Len(t, received(messages), 1)
+
+		reload(reminder)
+		assert.Nil(t, reminder.SnoozedUntil)
+		assert.Equal(t, 2, reminder.SentCount)
+	})
+
+	t.Run("a newer activity replaces a snooze", func(t *testing.T) {
+		snoozed := time.Now().Add(-time.Second)
+		require.NoError(t, db.Model(reminder).Update("snoozed_until", snoozed).Error)
+		reload(reminder)
+
+		// Logged after the reminder was sent, but started before it
+		recent := addFeed(reminder.LastSentAt.Add(-time.Minute), false)
+
+		due, err := DueAt(db, reminder)
+		require.NoError(t, err)
+		require.NotNil(t, due)
+		assert.WithinDuration(t, recent.StartTime.Add(3*time.Hour), *due, time.Second)
+	})
+
+	t.Run("reminders stop when access to the baby is lost", func(t *testing.T) {
+		require.NoError(t, db.Model(reminder).Update("snoozed_until", nil).Error)
+		require.NoError(t, db.Where("baby_id = ?", baby.ID).Delete(&models.Activity{}).Error)
+		addFeed(time.Now().Add(-4*time.Hour), false)
+		require.NoError(t, db.Where("baby_id = ? AND user_id = ?", baby.ID, user.ID).Delete(&models.BabyMember{}).Error)
+
+		require.NoError(t, scheduler.SendDue(ctx))
+		assert.Empty(t, received(messages))
+	})
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "45m", formatDuration(45*time.Minute))
+	assert.Equal(t, "3h", formatDuration(3*time.Hour))
+	assert.Equal(t, "2h 30m", formatDuration(150*time.Minute+10*time.Second))
+}
@@ -0,0 +1,53 @@
+// Package testutil holds fixtures shared by the tests of packages that work
+// on the database directly
+package testutil
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/database"
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// SetupDB migrates a throwaway SQLite database and adds a user with a baby,
+// all of which are removed when the test finishes
+func SetupDB(t *testing.T) (*gorm.DB, *models.User, *models.Baby) {
+	t.Helper()
+
+	tmpfile, err := os.CreateTemp("", "test-*.db")
+	require.NoError(t, err)
+	tmpfile.Close()
+	t.Cleanup(func() { os.Remove(tmpfile.Name()) })
+
+	db, err := gorm.Open(sqlite.Open(tmpfile.Name()), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	require.NoError(t, database.RunMigrations(db, &config.Config{DBType: "sqlite", Env: "test"}))
+
+	user := &models.User{ID: uuid.New(), Username: "parent", PasswordHash: "$2a$10$test.hash"}
+	require.NoError(t, db.Create(user).Error)
+
+	baby := &models.Baby{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      "Test Baby",
+		BirthDate: time.Now().AddDate(0, 0, -30),
+	}
+	require.NoError(t, db.Create(baby).Error)
+
+	return db, user, baby
+}
@@ -34,11 +34,38 @@ var DefaultArgon2Params = &Argon2Params{
 	KeyLength:   32,
 }
 
+// NewArgon2Params returns the default parameters with the given costs.
+// Zero values keep the defaults.
+func NewArgon2Params(memory, iterations uint32, parallelism uint8) *Argon2Params {
+	params := *DefaultArgon2Params
+	if memory != 0 {
+		params.Memory = memory
+	}
+	if iterations != 0 {
+		params.Iterations = iterations
+	}
+	if parallelism != 0 {
+		params.Parallelism = parallelism
+	}
+	return &params
+}
+
 // HashPassword creates an Argon2id hash of the password
 func HashPassword(password string) (string, error) {
 	return HashPasswordWithParams(password, DefaultArgon2Params)
 }
 
+// NewUnusablePasswordHash hashes a random password nobody knows, for users
+// whose logins are handled elsewhere, such as by an SSO proxy or an
+// OpenID Connect provider
+func NewUnusablePasswordHash() (string, error) {
+	password, err := GenerateToken(DefaultTokenLength)
+	if err != nil {
+		return "", err
+	}
+	return HashPassword(password)
+}
+
 // HashPasswordWithParams creates an Argon2id hash with custom parameters
 func HashPasswordWithParams(password string, params *Argon2Params) (string, error) {
 	// Generate random salt
@@ -75,6 +102,22 @@ func VerifyPassword(password, hash string) (bool, error) {
 	return subtle.ConstantTimeCompare(key, otherHash) == 1, nil
 }
 
+// NeedsRehash reports whether a hash was made with weaker parameters than
+// params, and so should be replaced next time the password is known. Hashes
+// that can't be decoded also need replacing.
+func NeedsRehash(hash string, params *Argon2Params) bool {
+	current, _, _, err := decodeHash(hash)
+	if err != nil {
+		return true
+	}
+
+	return current.Memory < params.Memory ||
+		current.Iterations < params.Iterations ||
+		current.Parallelism < params.Parallelism ||
+		current.SaltLength < params.SaltLength ||
+		current.KeyLength < params.KeyLength
+}
+
 // decodeHash extracts the parameters, salt and key from the encoded hash
 func decodeHash(encoded string) (*Argon2Params, []byte, []byte, error) {
 	parts := strings.Split(encoded, "$")
@@ -51,6 +51,20 @@ func TestVerifyPassword_InvalidHash(t *testing.T) {
 	assert.Equal(t, ErrInvalidHash, err)
 }
 
+func TestNewUnusablePasswordHash(t *testing.T) {
+	hash, err := NewUnusablePasswordHash()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+
+	valid, err := VerifyPassword("", hash)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	other, err := NewUnusablePasswordHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
 func TestHashPasswordWithParams(t *testing.T) {
 	password := "testpassword123"
 
@@ -155,3 +169,40 @@ func BenchmarkVerifyPassword(b *testing.B) {
 		}
 	}
 }
+
+func TestNeedsRehash(t *testing.T) {
+	weak := &Argon2Params{
+		Memory:      8 * 1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+
+	weakHash, err := HashPasswordWithParams("testpassword123", weak)
+	require.NoError(t, err)
+	assert.True(t, NeedsRehash(weakHash, DefaultArgon2Params))
+	assert.False(t, NeedsRehash(weakHash, weak))
+
+	// Raising any single cost makes an old hash weaker than the policy
+	stronger := NewArgon2Params(weak.Memory, 2, weak.Parallelism)
+	assert.True(t, NeedsRehash(weakHash, stronger))
+
+	// Hashes stronger than the policy are left alone
+	strongHash, err := HashPasswordWithParams("testpassword123", NewArgon2Params(128*1024, 4, 4))
+	require.NoError(t, err)
+	assert.False(t, NeedsRehash(strongHash, DefaultArgon2Params))
+
+	assert.True(t, NeedsRehash("not-a-hash", DefaultArgon2Params))
+}
+
+func TestNewArgon2Params(t *testing.T) {
+	params := NewArgon2Params(128*1024, 0, 0)
+	assert.Equal(t, uint32(128*1024), params.Memory)
+	assert.Equal(t, DefaultArgon2Params.Iterations, params.Iterations)
+	assert.Equal(t, DefaultArgon2Params.Parallelism, params.Parallelism)
+	assert.Equal(t, DefaultArgon2Params.KeyLength, params.KeyLength)
+
+	// The defaults themselves are not modified
+	assert.Equal(t, uint32(64*1024), DefaultArgon2Params.Memory)
+}
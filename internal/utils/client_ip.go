@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ClientIPExtractor returns the IP extractor for the server. The headers
+// proxies put the client's IP in (CF-Connecting-IP, X-Real-IP and
+// X-Forwarded-For) are only believed on requests from a trusted proxy,
+// since any client can send them. Proxies on loopback, link-local and
+// private addresses are trusted, such as Traefik on a Docker network, as
+// well as those in the given networks.
+func ClientIPExtractor(trusted []*net.IPNet) echo.IPExtractor {
+	options := []echo.TrustOption{
+		echo.TrustLoopback(true),
+		echo.TrustLinkLocal(true),
+		echo.TrustPrivateNet(true),
+	}
+	for _, network := range trusted {
+		options = append(options, echo.TrustIPRange(network))
+	}
+	fromXFF := echo.ExtractIPFromXFFHeader(options...)
+	direct := echo.ExtractIPDirect()
+
+	isTrusted := func(ip net.IP) bool {
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsPrivate() {
+			return true
+		}
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(req *http.Request) string {
+		peer := direct(req)
+		if ip := net.ParseIP(peer); ip == nil || !isTrusted(ip) {
+			return peer
+		}
+
+		// Cloudflare sends the client's IP in CF-Connecting-IP
+		if cfIP := req.Header.Get("CF-Connecting-IP"); cfIP != "" {
+			return cfIP
+		}
+		if realIP := req.Header.Get(echo.HeaderXRealIP); realIP != "" {
+			return realIP
+		}
+		return fromXFF(req)
+	}
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// IdempotencyKeyRetention is how long the response to a request with an
+// Idempotency-Key header is kept for retries
+const IdempotencyKeyRetention = 24 * time.Hour
+
+// PurgeIdempotencyKeys deletes idempotency keys created before before
+func PurgeIdempotencyKeys(db *gorm.DB, before time.Time) error {
+	return db.Where("created_at < ?", before).Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// LoginThrottle slows down password guessing. Once a username or IP address
+// has too many recent failed logins, each further attempt has to wait for an
+// exponentially growing delay after the last failure.
+type LoginThrottle struct {
+	// Window is how far back failed attempts are counted
+	Window time.Duration
+	// MaxUsernameFailures and MaxIPFailures are the failures allowed
+	// before delays kick in
+	MaxUsernameFailures int
+	MaxIPFailures       int
+	// BaseDelay is the first delay, doubled for each further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultLoginThrottle provides the login throttling policy used by the server
+var DefaultLoginThrottle = &LoginThrottle{
+	Window:              time.Hour,
+	MaxUsernameFailures: 5,
+	MaxIPFailures:       20,
+	BaseDelay:           30 * time.Second,
+	MaxDelay:            15 * time.Minute,
+}
+
+// RetryAfter returns how long the client must wait before it may try to log
+// in as username from ip again, or zero if it may try now. A successful login
+// resets the count for the username but not for the IP address.
+func (t *LoginThrottle) RetryAfter(db *gorm.DB, username, ip string, now time.Time) (time.Duration, error) {
+	since := now.Add(-t.Window)
+
+	var lastSuccess models.LoginAttempt
+	err := db.Where("username = ? AND result = ? AND created_at > ?", username, models.LoginResultSuccess, since).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&lastSuccess).Error
+	if err != nil {
+		return 0, err
+	}
+	usernameSince := since
+	if lastSuccess.CreatedAt.After(since) {
+		usernameSince = lastSuccess.CreatedAt
+	}
+
+	wait, err := t.retryAfter(db.Where("username = ?", username), t.MaxUsernameFailures, usernameSince, now)
+	if err != nil || ip == "" {
+		return wait, err
+	}
+
+	ipWait, err := t.retryAfter(db.Where("ip_address = ?", ip), t.MaxIPFailures, since, now)
+	if err != nil {
+		return 0, err
+	}
+	if ipWait > wait {
+		wait = ipWait
+	}
+	return wait, nil
+}
+
+// retryAfter applies the policy to the failures matched by scope
+func (t *LoginThrottle) retryAfter(scope *gorm.DB, limit int, since, now time.Time) (time.Duration, error) {
+	var failures []models.LoginAttempt
+	if err := scope.Where("result = ? AND created_at > ?", models.LoginResultInvalidCredentials, since).
+		Order("created_at DESC").
+		Find(&failures).Error; err != nil {
+		return 0, err
+	}
+
+	delay := t.delay(len(failures), limit)
+	if delay == 0 {
+		return 0, nil
+	}
+
+	if wait := failures[0].CreatedAt.Add(delay).Sub(now); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// delay returns the wait imposed after the given number of failures
+func (t *LoginThrottle) delay(failures, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+
+	delay := t.BaseDelay
+	for i := limit; i < failures && delay < t.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	return delay
+}
+
+// PurgeLoginAttempts deletes login history older than before
+func PurgeLoginAttempts(db *gorm.DB, before time.Time) error {
+	return db.Where("created_at < ?", before).Delete(&models.LoginAttempt{}).Error
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := &LoginThrottle{
+		BaseDelay: 30 * time.Second,
+		MaxDelay:  5 * time.Minute,
+	}
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, 30 * time.Second},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{8, 4 * time.Minute},
+		{9, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, throttle.delay(tt.failures, 5), "failures: %d", tt.failures)
+	}
+}
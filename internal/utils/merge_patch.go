@@ -0,0 +1,37 @@
+package utils
+
+import "encoding/json"
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to a JSON document. Members
+// of the patch replace those of the document, objects are merged recursively
+// and null removes a member.
+func MergePatch(document, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+	if err := json.Unmarshal(document, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatchValue(target, changes))
+}
+
+func mergePatchValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatchValue(targetObject[key], value)
+	}
+	return targetObject
+}
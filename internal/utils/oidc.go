@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// OIDCStateCookieName holds an OpenID Connect login in progress. It is
+	// separate from the session cookie, which is SameSite=Strict and so isn't
+	// sent when the identity provider redirects back.
+	OIDCStateCookieName = "bambino-oidc"
+
+	// OIDCLoginTimeout is how long a user has to log in at the identity provider
+	OIDCLoginTimeout = 10 * time.Minute
+)
+
+// OIDCLoginState is remembered between sending the user to the identity
+// provider and the callback
+type OIDCLoginState struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier
+	// LinkUserID is set when a logged in user is linking their account
+	LinkUserID string
+	ExpiresAt  int64
+}
+
+// SetOIDCLoginState stores state in a signed and encrypted cookie
+func SetOIDCLoginState(c echo.Context, keyPairs [][]byte, secure bool, state *OIDCLoginState) error {
+	state.ExpiresAt = time.Now().Add(OIDCLoginTimeout).Unix()
+
+	encoded, err := securecookie.EncodeMulti(OIDCStateCookieName, state, oidcStateCodecs(keyPairs)...)
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    encoded,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(OIDCLoginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// PopOIDCLoginState reads and clears the login state cookie, so it can only
+// be used once
+func PopOIDCLoginState(c echo.Context, keyPairs [][]byte) (*OIDCLoginState, error) {
+	cookie, err := c.Cookie(OIDCStateCookieName)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     OIDCStateCookieName,
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	var state OIDCLoginState
+	if err := securecookie.DecodeMulti(OIDCStateCookieName, cookie.Value, &state, oidcStateCodecs(keyPairs)...); err != nil {
+		return nil, ErrSessionInvalid
+	}
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, ErrSessionInvalid
+	}
+	return &state, nil
+}
+
+func oidcStateCodecs(keyPairs [][]byte) []securecookie.Codec {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(int(OIDCLoginTimeout.Seconds()))
+		}
+	}
+	return codecs
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for requests to addresses on the server's
+// own network that haven't been allowed
+var ErrPrivateAddress = errors.New("loopback, private and link-local addresses are not allowed")
+
+// OutboundPolicy limits where requests to URLs chosen by users, such as
+// webhooks and notification channels, may go. Loopback, private,
+// link-local and unspecified addresses are refused unless they are in one
+// of the allowed networks, so that users can't reach services on the
+// server's network.
+type OutboundPolicy struct {
+	allowed []*net.IPNet
+}
+
+// NewOutboundPolicy creates an OutboundPolicy that lets requests through to
+// the allowed networks even if they are private
+func NewOutboundPolicy(allowed []*net.IPNet) *OutboundPolicy {
+	return &OutboundPolicy{allowed: allowed}
+}
+
+// Allows reports whether requests may be sent to an address
+func (p *OutboundPolicy) Allows(ip net.IP) bool {
+	for _, network := range p.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsUnspecified()
+}
+
+// CheckURL refuses a URL whose host is, or resolves to, an address the
+// policy doesn't allow. Hosts that can't be resolved yet are let through,
+// as the client checks the address again when it connects.
+func (p *OutboundPolicy) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !p.Allows(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// Client returns an HTTP client that only connects to addresses the policy
+// allows. The address is checked on every connection, so redirects and
+// hosts that resolve differently later are covered too.
+func (p *OutboundPolicy) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !p.Allows(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Requests go straight to their destination, so that it is the
+	// destination's address that is checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboundPolicy(t *testing.T) {
+	_, lan, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+	policy := NewOutboundPolicy([]*net.IPNet{lan})
+
+	for address, allowed := range map[string]bool{
+		"93.184.215.14":   true,
+		"2606:4700::1111": true,
+		"192.168.1.20":    true,
+		"192.168.2.20":    false,
+		"10.0.0.1":        false,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"fe80::1":         false,
+	} {
+		assert.Equal(t, allowed, policy.Allows(net.ParseIP(address)), address)
+	}
+
+	assert.ErrorIs(t, policy.CheckURL("http://127.0.0.1:8080/hook"), ErrPrivateAddress)
+	assert.ErrorIs(t, policy.CheckURL("http://[::1]/hook"), ErrPrivateAddress)
+	assert.NoError(t, policy.CheckURL("http://192.168.1.20/hook"))
+}
+
+func TestOutboundPolicyClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	_, err := NewOutboundPolicy(nil).Client(time.Second).Get(server.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPrivateAddress))
+
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+	resp, err := NewOutboundPolicy([]*net.IPNet{loopback}).Client(time.Second).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
@@ -8,32 +8,61 @@ import (
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
 )
 
 const (
 	SessionName = "bambino-session"
 	UserIDKey   = "user_id"
 	UsernameKey = "username"
+
+	// TwoFactorUserIDKey and TwoFactorExpiresKey hold a login that has passed
+	// the password check but still needs a second factor
+	TwoFactorUserIDKey  = "2fa_user_id"
+	TwoFactorExpiresKey = "2fa_expires"
+
+	// TwoFactorLoginTimeout is how long a user has to enter their code after
+	// giving their password
+	TwoFactorLoginTimeout = 5 * time.Minute
 )
 
 // SessionConfig holds session configuration
 type SessionConfig struct {
-	Secret   string
+	// KeyPairs are alternating signing and encryption keys, newest first.
+	// Cookies are written with the first pair and read with any of them.
+	KeyPairs [][]byte
 	MaxAge   int // in seconds
 	HttpOnly bool
 	Secure   bool // set to true in production with HTTPS
+
+	// DB stores sessions server-side. Without it, session data is kept in the cookie.
+	DB *gorm.DB
+	// IPExtractor records the client IP of database-backed sessions
+	IPExtractor func(*http.Request) string
 }
 
 // CreateSessionStore creates a new session store
 func CreateSessionStore(config SessionConfig) sessions.Store {
-	store := sessions.NewCookieStore([]byte(config.Secret))
-	store.Options = &sessions.Options{
+	options := &sessions.Options{
 		Path:     "/",
 		MaxAge:   config.MaxAge,
 		HttpOnly: config.HttpOnly,
 		Secure:   config.Secure,
 		SameSite: http.SameSiteStrictMode,
 	}
+
+	if config.DB != nil {
+		store := NewDBStore(config.DB, config.KeyPairs...)
+		store.Options = options
+		store.IPExtractor = config.IPExtractor
+		return store
+	}
+
+	store := sessions.NewCookieStore(config.KeyPairs...)
+	store.Options = options
+	store.MaxAge(config.MaxAge)
 	return store
 }
 
@@ -44,12 +73,60 @@ func CreateUserSession(c echo.Context, userID uuid.UUID, username string) error
 		return err
 	}
 
+	// Start a fresh server-side session on login so an earlier session key
+	// can't be reused
+	if store, ok := sess.Store().(*DBStore); ok && sess.ID != "" {
+		if err := store.DB.Where("token_hash = ?", HashToken(sess.ID)).
+			Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+	}
+	sess.ID = ""
+
+	delete(sess.Values, TwoFactorUserIDKey)
+	delete(sess.Values, TwoFactorExpiresKey)
 	sess.Values[UserIDKey] = userID.String()
 	sess.Values[UsernameKey] = username
 
 	return sess.Save(c.Request(), c.Response())
 }
 
+// CreatePendingTwoFactorSession remembers that the user has given the right
+// password, without logging them in until they also give a second factor
+func CreatePendingTwoFactorSession(c echo.Context, userID uuid.UUID) error {
+	sess, err := session.Get(SessionName, c)
+	if err != nil {
+		return err
+	}
+
+	delete(sess.Values, UserIDKey)
+	delete(sess.Values, UsernameKey)
+	sess.Values[TwoFactorUserIDKey] = userID.String()
+	sess.Values[TwoFactorExpiresKey] = time.Now().Add(TwoFactorLoginTimeout).Unix()
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// GetPendingTwoFactorUser returns the user waiting to give a second factor
+func GetPendingTwoFactorUser(c echo.Context) (uuid.UUID, error) {
+	sess, err := session.Get(SessionName, c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	userIDStr, ok := sess.Values[TwoFactorUserIDKey].(string)
+	if !ok {
+		return uuid.Nil, ErrSessionNotFound
+	}
+
+	expires, ok := sess.Values[TwoFactorExpiresKey].(int64)
+	if !ok || time.Now().Unix() > expires {
+		return uuid.Nil, ErrSessionInvalid
+	}
+
+	return uuid.Parse(userIDStr)
+}
+
 // GetUserSession retrieves user information from session
 func GetUserSession(c echo.Context) (uuid.UUID, string, error) {
 	sess, err := session.Get(SessionName, c)
@@ -90,6 +167,26 @@ func DestroyUserSession(c echo.Context) error {
 	return sess.Save(c.Request(), c.Response())
 }
 
+// CurrentSessionKey returns the key of the request's server-side session, or
+// an empty string if there is none
+func CurrentSessionKey(c echo.Context) string {
+	sess, err := session.Get(SessionName, c)
+	if err != nil {
+		return ""
+	}
+	return sess.ID
+}
+
+// DeleteUserSessions revokes all of a user's server-side sessions except the
+// one with exceptKey, which may be empty to revoke them all
+func DeleteUserSessions(db *gorm.DB, userID uuid.UUID, exceptKey string) error {
+	query := db.Where("user_id = ?", userID)
+	if exceptKey != "" {
+		query = query.Where("token_hash <> ?", HashToken(exceptKey))
+	}
+	return query.Delete(&models.Session{}).Error
+}
+
 // IsAuthenticated checks if the user is authenticated
 func IsAuthenticated(c echo.Context) bool {
 	_, _, err := GetUserSession(c)
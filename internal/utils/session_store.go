@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+const (
+	// lastSeenInterval limits how often a session's last-seen time is written
+	lastSeenInterval = time.Minute
+	// browserSessionLifetime is how long sessions with a MaxAge of 0, which
+	// end when the browser closes, are kept on the server
+	browserSessionLifetime = 24 * time.Hour
+)
+
+// DBStore is a gorilla sessions.Store that keeps session data in the database.
+// The cookie only carries a signed, random session key, so sessions can be
+// listed per user and revoked from the server.
+type DBStore struct {
+	DB      *gorm.DB
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+
+	// IPExtractor returns the client IP of a request. It should match the
+	// extractor configured on the echo instance so proxies are handled.
+	IPExtractor func(*http.Request) string
+}
+
+// NewDBStore creates a database-backed session store. keyPairs are passed to
+// securecookie.CodecsFromPairs.
+func NewDBStore(db *gorm.DB, keyPairs ...[]byte) *DBStore {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	for _, codec := range codecs {
+		// Expiry is tracked in the database rather than in the encoded values
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(0)
+		}
+	}
+
+	return &DBStore{
+		DB:     db,
+		Codecs: codecs,
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+}
+
+// Get returns a cached session for the request, loading it if necessary
+func (s *DBStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session referenced by the request's cookie, or returns a new
+// empty session if there is none or it has expired or been revoked
+func (s *DBStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var key string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &key, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	var record models.Session
+	if err := s.DB.Where("token_hash = ? AND expires_at > ?", HashToken(key), time.Now()).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, nil
+		}
+		return session, err
+	}
+
+	if err := securecookie.DecodeMulti(name, record.Data, &session.Values, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	session.ID = key
+	session.IsNew = false
+
+	// Track the device, throttled so every request doesn't write
+	if time.Since(record.LastSeenAt) > lastSeenInterval {
+		s.DB.Model(&record).UpdateColumns(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip_address":   s.clientIP(r),
+			"user_agent":   TruncateString(r.UserAgent(), 255),
+		})
+	}
+
+	return session, nil
+}
+
+// Save persists the session and writes its cookie. A negative MaxAge deletes
+// the session.
+func (s *DBStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.DB.Where("token_hash = ?", HashToken(session.ID)).
+				Delete(&models.Session{}).Error; err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	var userID *uuid.UUID
+	if value, ok := session.Values[UserIDKey].(string); ok {
+		if id, err := uuid.Parse(value); err == nil {
+			userID = &id
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(browserSessionLifetime)
+	if session.Options.MaxAge > 0 {
+		expiresAt = now.Add(time.Duration(session.Options.MaxAge) * time.Second)
+	}
+
+	if session.ID == "" {
+		key, err := GenerateToken(DefaultTokenLength)
+		if err != nil {
+			return err
+		}
+
+		record := models.Session{
+			TokenHash:  HashToken(key),
+			UserID:     userID,
+			Data:       data,
+			UserAgent:  TruncateString(r.UserAgent(), 255),
+			IPAddress:  s.clientIP(r),
+			LastSeenAt: now,
+			ExpiresAt:  expiresAt,
+		}
+		if err := s.DB.Create(&record).Error; err != nil {
+			return err
+		}
+		session.ID = key
+	} else {
+		if err := s.DB.Model(&models.Session{}).
+			Where("token_hash = ?", HashToken(session.ID)).
+			UpdateColumns(map[string]interface{}{
+				"user_id":      userID,
+				"data":         data,
+				"last_seen_at": now,
+				"expires_at":   expiresAt,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// PurgeExpired deletes sessions that have passed their expiry time
+func (s *DBStore) PurgeExpired() error {
+	return s.DB.Where("expires_at <= ?", time.Now()).Delete(&models.Session{}).Error
+}
+
+// clientIP returns the client IP using the configured extractor, falling back
+// to the connection's remote address
+func (s *DBStore) clientIP(r *http.Request) string {
+	if s.IPExtractor != nil {
+		return TruncateString(s.IPExtractor(r), 45)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return TruncateString(r.RemoteAddr, 45)
+	}
+	return host
+}
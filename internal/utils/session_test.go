@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldSessionKeys = [][]byte{[]byte("old-signing-key-0123456789abcdef"), []byte("old-encryption-key-0123456789abc")}
+	newSessionKeys = [][]byte{[]byte("new-signing-key-0123456789abcdef"), []byte("new-encryption-key-0123456789abc")}
+)
+
+// sessionCookieFor logs a user in through a cookie store with the given keys
+func sessionCookieFor(t *testing.T, keyPairs [][]byte, userID uuid.UUID, username string) *http.Cookie {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("_session_store", CreateSessionStore(SessionConfig{KeyPairs: keyPairs, MaxAge: 3600}))
+
+	require.NoError(t, CreateUserSession(c, userID, username))
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionName {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie set")
+	return nil
+}
+
+// readSession reads the user back from a cookie through a store with the given keys
+func readSession(keyPairs [][]byte, cookie *http.Cookie) (uuid.UUID, string, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.Set("_session_store", CreateSessionStore(SessionConfig{KeyPairs: keyPairs, MaxAge: 3600}))
+	return GetUserSession(c)
+}
+
+func TestSessionKeyRotation(t *testing.T) {
+	userID := uuid.New()
+	cookie := sessionCookieFor(t, oldSessionKeys, userID, "parent")
+
+	t.Run("cookies are encrypted", func(t *testing.T) {
+		raw, err := base64.URLEncoding.DecodeString(cookie.Value)
+		require.NoError(t, err)
+		assert.NotContains(t, string(raw), "parent")
+		assert.NotContains(t, string(raw), userID.String())
+	})
+
+	t.Run("old keys still read existing cookies after rotation", func(t *testing.T) {
+		rotated := append(append([][]byte{}, newSessionKeys...), oldSessionKeys...)
+
+		id, username, err := readSession(rotated, cookie)
+		require.NoError(t, err)
+		assert.Equal(t, userID, id)
+		assert.Equal(t, "parent", username)
+
+		// New cookies are written with the newest keys
+		fresh := sessionCookieFor(t, rotated, userID, "parent")
+		_, _, err = readSession(newSessionKeys, fresh)
+		assert.NoError(t, err)
+	})
+
+	t.Run("cookies are rejected once their keys are removed", func(t *testing.T) {
+		_, _, err := readSession(newSessionKeys, cookie)
+		assert.Error(t, err)
+	})
+
+	t.Run("cookies can't be read with only the signing key", func(t *testing.T) {
+		_, _, err := readSession([][]byte{oldSessionKeys[0]}, cookie)
+		assert.Error(t, err)
+	})
+}
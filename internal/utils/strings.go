@@ -0,0 +1,10 @@
+package utils
+
+// TruncateString shortens s to at most n bytes, so that values taken from a
+// request fit the column they are stored in
+func TruncateString(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// DefaultTokenLength is the number of random bytes in a generated token
+const DefaultTokenLength = 32
+
+// GenerateToken returns a random URL-safe token built from n random bytes
+func GenerateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 digest of a token. Tokens are
+// high-entropy random values, so a fast hash is sufficient for storing them.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrInvalidSignature is returned when a signed token fails verification
+var ErrInvalidSignature = errors.New("invalid token signature")
+
+// SignToken returns value followed by a URL-safe HMAC-SHA256 signature of it,
+// so that the value can be handed out and later verified without a lookup
+func SignToken(secret []byte, value string) string {
+	return value + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, value))
+}
+
+// VerifySignedToken checks a token produced by SignToken and returns the signed value
+func VerifySignedToken(secret []byte, token string) (string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", ErrInvalidSignature
+	}
+
+	value := token[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	if !hmac.Equal(signature, tokenMAC(secret, value)) {
+		return "", ErrInvalidSignature
+	}
+
+	return value, nil
+}
+
+// VerifySignedTokenWithKeys checks a token against each secret in turn, so
+// tokens signed with a previous secret stay valid after rotation
+func VerifySignedTokenWithKeys(secrets [][]byte, token string) (string, error) {
+	for _, secret := range secrets {
+		if value, err := VerifySignedToken(secret, token); err == nil {
+			return value, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+func tokenMAC(secret []byte, value string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// APITokenPrefix marks personal API tokens so they are recognisable in configs and logs
+const APITokenPrefix = "bmb_"
+
+// GenerateAPIToken returns a new personal API token
+func GenerateAPIToken() (string, error) {
+	token, err := GenerateToken(DefaultTokenLength)
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + token, nil
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token1, err := GenerateToken(DefaultTokenLength)
+	require.NoError(t, err)
+	token2, err := GenerateToken(DefaultTokenLength)
+	require.NoError(t, err)
+
+	// 32 bytes encode to 43 unpadded base64 characters
+	assert.Len(t, token1, 43)
+	assert.NotEqual(t, token1, token2)
+	assert.NotContains(t, token1, "+")
+	assert.NotContains(t, token1, "/")
+}
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("secret-token")
+
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashToken("secret-token"))
+	assert.NotEqual(t, hash, HashToken("other-token"))
+}
+
+func TestSignedToken(t *testing.T) {
+	secret := []byte("test-secret")
+	token := SignToken(secret, "share-link-id")
+
+	value, err := VerifySignedToken(secret, token)
+	require.NoError(t, err)
+	assert.Equal(t, "share-link-id", value)
+
+	// Wrong secret
+	_, err = VerifySignedToken([]byte("other-secret"), token)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// Tampered value
+	_, err = VerifySignedToken(secret, "other-id"+token[len("share-link-id"):])
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// Malformed tokens
+	_, err = VerifySignedToken(secret, "no-signature")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = VerifySignedToken(secret, "value.!!!")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerifySignedTokenWithKeys(t *testing.T) {
+	oldKey := []byte("old-secret")
+	newKey := []byte("new-secret")
+
+	// Tokens signed before a rotation still verify
+	value, err := VerifySignedTokenWithKeys([][]byte{newKey, oldKey}, SignToken(oldKey, "share-link-id"))
+	require.NoError(t, err)
+	assert.Equal(t, "share-link-id", value)
+
+	// Until the old key is dropped
+	_, err = VerifySignedTokenWithKeys([][]byte{newKey}, SignToken(oldKey, "share-link-id"))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = VerifySignedTokenWithKeys(nil, SignToken(oldKey, "share-link-id"))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
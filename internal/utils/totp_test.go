@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"encoding/base32"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA-1, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, code, "time: %d", tt.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := TOTPCode(secret, TOTPStep(now))
+	require.NoError(t, err)
+
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	// Clock drift of one period is tolerated
+	_, ok = ValidateTOTP(secret, code, now.Add(TOTPPeriod*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(3*TOTPPeriod*time.Second), 0)
+	assert.False(t, ok)
+
+	// A used step can't be replayed
+	_, ok = ValidateTOTP(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("JBSWY3DPEHPK3PXP", "jane doe")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Bambino:jane%20doe?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Bambino")
+	assert.Contains(t, uri, "digits=6")
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`), code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	assert.Equal(t, "abcdefghij", NormalizeRecoveryCode(" ABCDE-fghij "))
+}
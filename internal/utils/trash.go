@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/models"
+)
+
+// activityDetailModels are the type-specific tables hanging off activities
+var activityDetailModels = []interface{}{
+	&models.FeedActivity{},
+	&models.PumpActivity{},
+	&models.DiaperActivity{},
+	&models.SleepActivity{},
+	&models.GrowthMeasurement{},
+	&models.HealthRecord{},
+	&models.Milestone{},
+}
+
+// PurgeTrashedActivities permanently deletes activities that were moved to
+// the trash before the given time, along with their type-specific records
+func PurgeTrashedActivities(db *gorm.DB, before time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		trashed := tx.Unscoped().Model(&models.Activity{}).
+			Select("id").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+
+		// Don't rely on the foreign key cascade, which SQLite only applies
+		// when foreign keys are switched on
+		for _, model := range activityDetailModels {
+			if err := tx.Where("activity_id IN (?)", trashed).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Delete(&models.Activity{}).Error
+	})
+}
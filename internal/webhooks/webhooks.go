@@ -0,0 +1,266 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/engineervix/bambino/internal/config"
+	"github.com/engineervix/bambino/internal/events"
+	"github.com/engineervix/bambino/internal/models"
+	"github.com/engineervix/bambino/internal/utils"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the request body, keyed
+	// with the webhook's secret, as "sha256=<hex>"
+	SignatureHeader = "X-Bambino-Signature-256"
+	// EventHeader carries the event type
+	EventHeader = "X-Bambino-Event"
+	// DeliveryHeader carries the delivery ID, which stays the same across
+	// retries of a delivery
+	DeliveryHeader = "X-Bambino-Delivery"
+
+	// MaxAttempts is how many times a delivery is tried before giving up
+	MaxAttempts = 10
+	// DeliveryRetention is how long finished deliveries are kept in the log
+	DeliveryRetention = 30 * 24 * time.Hour
+
+	// firstRetryDelay is the wait before the first retry, doubling after
+	// each failure up to maxRetryDelay
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 6 * time.Hour
+	// claimTimeout is how long an attempt may take before another worker
+	// may try the delivery again
+	claimTimeout = time.Minute
+	// batchSize is how many due deliveries are picked up at a time
+	batchSize = 20
+)
+
+// errWebhookDisabled fails the deliveries left for a disabled webhook
+var errWebhookDisabled = errors.New("webhook is disabled")
+
+// Payload is the JSON body sent to a webhook
+type Payload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	BabyID    uuid.UUID       `json:"baby_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enqueue queues an event about an activity of the given type for delivery
+// to the baby's webhooks that want it. Webhooks whose owners have lost
+// access to the baby are skipped.
+func Enqueue(db *gorm.DB, event events.Event, activityType models.ActivityType) error {
+	var hooks []models.Webhook
+	if err := db.Where("baby_id = ? AND active = ?", event.BabyID, true).
+		Where("user_id IN (?)", db.Model(&models.BabyMember{}).Select("user_id").Where("baby_id = ?", event.BabyID)).
+		Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, hook := range hooks {
+		if !hook.Matches(activityType) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(Payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		BabyID:    event.BabyID,
+		CreatedAt: now,
+		Data:      event.Data,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range deliveries {
+		deliveries[i].Payload = string(payload)
+	}
+
+	return db.Create(&deliveries).Error
+}
+
+// Sign returns the signature header value for a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether a signature header value is right for a body
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, body)))
+}
+
+// retryDelay returns how long to wait after a delivery's nth failed attempt
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// Dispatcher sends queued deliveries to their webhooks
+type Dispatcher struct {
+	db     *gorm.DB
+	client *http.Client
+	// PollInterval is how often the queue is checked for due deliveries
+	PollInterval time.Duration
+}
+
+// NewDispatcher creates a Dispatcher for the queue in db. Webhooks on the
+// server's own network are only sent to if the configuration allows it.
+func NewDispatcher(db *gorm.DB, cfg *config.Config) *Dispatcher {
+	// Checked when the configuration was loaded
+	networks, _ := cfg.OutboundNetworks()
+	return &Dispatcher{
+		db:           db,
+		client:       utils.NewOutboundPolicy(networks).Client(10 * time.Second),
+		PollInterval: 5 * time.Second,
+	}
+}
+
+// Run sends deliveries as they fall due until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.DeliverDue(ctx); err != nil {
+			log.Printf("Failed to send webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes an attempt at every delivery that is due
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	for {
+		var due []models.WebhookDelivery
+		if err := d.db.Preload("Webhook").
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(batchSize).
+			Find(&due).Error; err != nil {
+			return err
+		}
+
+		for i := range due {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := d.attempt(ctx, &due[i]); err != nil {
+				return err
+			}
+		}
+		if len(due) < batchSize {
+			return nil
+		}
+	}
+}
+
+// attempt sends a delivery once and records how it went
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	// Claim the attempt, so that other instances leave the delivery alone
+	// while it is under way
+	now := time.Now()
+	claim := d.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, models.WebhookDeliveryPending, delivery.Attempts).
+		Updates(map[string]interface{}{
+			"attempts":        delivery.Attempts + 1,
+			"next_attempt_at": now.Add(claimTimeout),
+			"last_attempt_at": now,
+		})
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil
+	}
+	delivery.Attempts++
+
+	status, err := 0, errWebhookDisabled
+	if delivery.Webhook.Active {
+		status, err = d.send(ctx, delivery)
+	}
+
+	updates := map[string]interface{}{"response_status": status, "last_error": ""}
+	switch {
+	case err == nil:
+		updates["status"] = models.WebhookDeliveryDelivered
+	case err == errWebhookDisabled || delivery.Attempts >= MaxAttempts:
+		updates["status"] = models.WebhookDeliveryFailed
+		updates["last_error"] = err.Error()
+	default:
+		updates["next_attempt_at"] = time.Now().Add(retryDelay(delivery.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	return d.db.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error
+}
+
+// send posts a delivery's payload, returning the response status
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Bambino-Webhook")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(delivery.Webhook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The response body isn't kept, as the delivery log would otherwise
+	// show whatever the URL returned
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// PurgeDeliveries deletes finished deliveries made before the given time
+func PurgeDeliveries(db *gorm.DB, before time.Time) error {
+	return db.Where("status <> ? AND created_at < ?", models.WebhookDeliveryPending, before).
+		Delete(&models.WebhookDelivery{}).Error
+}
@@ -0,0 +1,28 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"timer.started"}`)
+
+	// echo -n '{"type":"timer.started"}' | openssl dgst -sha256 -hmac secret
+	signature := Sign("secret", body)
+	assert.Equal(t, "sha256=78030109545808111c791b12796404375a2a562a4e240c00c454b7cc7c004f40", signature)
+
+	assert.True(t, Verify("secret", body, signature))
+	assert.False(t, Verify("other", body, signature))
+	assert.False(t, Verify("secret", []byte(`{"type":"timer.stopped"}`), signature))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryDelay(1))
+	assert.Equal(t, time.Minute, retryDelay(2))
+	assert.Equal(t, 2*time.Minute, retryDelay(3))
+	assert.Equal(t, 4*time.Hour+16*time.Minute, retryDelay(10))
+	assert.Equal(t, maxRetryDelay, retryDelay(100))
+}